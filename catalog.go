@@ -0,0 +1,172 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CatalogReader reads the metadata of a source database into the schema
+// model. Tables returns the tables of a schema without their details, which
+// Columns, Constraints and Indexes read table by table; Columns gives the
+// identity columns their generator.
+type CatalogReader interface {
+	Schemas() ([]string, error)
+	Tables(schemaName string) ([]*table, error)
+	Columns(t *table) ([]*column, error)
+	Constraints(t *table) ([]*constraint, error)
+	Indexes(t *table) ([]*index, error)
+	Sequences(schemaName string) ([]*sequence, error)
+	Views(schemaName string) ([]*view, error)
+}
+
+type sequence struct {
+	schemaName string
+	seqName    string
+	nextValue  int64
+	increment  int64
+	minValue   int64
+	maxValue   int64
+	cycle      bool
+	cacheSize  int64
+	generated  bool
+}
+
+// identity is the generator of an identity column: H2 1.4 AUTO_INCREMENT and
+// IDENTITY columns, backed by a generated sequence, and H2 2.x GENERATED AS
+// IDENTITY columns. nextValue is the value the next insert would get.
+type identity struct {
+	sequence
+	always bool //  GENERATED ALWAYS, else BY DEFAULT
+}
+
+type view struct {
+	schemaName string
+	viewName   string
+	definition string
+}
+
+func collectNames(rs IResultSet) (result []string, err error) {
+	for rs.Next() {
+		var name string
+		if err = rs.Scan(&name); err != nil {
+			return
+		}
+		result = append(result, name)
+	}
+	return
+}
+
+func collectTableNames(rs IResultSet) (result []*table, err error) {
+	for rs.Next() {
+		var t = new(table)
+		if err = rs.Scan(&t.schemaName, &t.tableName); err != nil {
+			return
+		}
+		result = append(result, t)
+	}
+	return
+}
+
+func collectSequences(rs IResultSet) (result []*sequence, err error) {
+	for rs.Next() {
+		var s = new(sequence)
+		err = rs.Scan(
+			&s.schemaName,
+			&s.seqName,
+			&s.nextValue,
+			&s.increment,
+			&s.minValue,
+			&s.maxValue,
+			&s.cycle,
+			&s.cacheSize,
+			&s.generated,
+		)
+		if err != nil {
+			return
+		}
+		result = append(result, s)
+	}
+	return
+}
+
+func collectIdentities(rs IResultSet) (result map[string]*identity, err error) {
+	result = make(map[string]*identity)
+	for rs.Next() {
+		var i = new(identity)
+		var columnName, generation string
+		err = rs.Scan(
+			&columnName,
+			&generation,
+			&i.nextValue,
+			&i.increment,
+			&i.minValue,
+			&i.maxValue,
+			&i.cycle,
+			&i.cacheSize,
+		)
+		if err != nil {
+			return
+		}
+		i.always = generation == "ALWAYS"
+		result[columnName] = i
+	}
+	return
+}
+
+func collectViews(rs IResultSet) (result []*view, err error) {
+	for rs.Next() {
+		var v = new(view)
+		if err = rs.Scan(&v.schemaName, &v.viewName, &v.definition); err != nil {
+			return
+		}
+		result = append(result, v)
+	}
+	return
+}
+
+// readSchema reads a schema with its tables, their columns, constraints and
+// indexes, and its sequences and views.
+func readSchema(r CatalogReader, schemaName string) (s *schema, err error) {
+	s = &schema{
+		schemaName: schemaName,
+	}
+	if s.tables, err = r.Tables(schemaName); err != nil {
+		return
+	}
+	for _, t := range s.tables {
+		if t.columns, err = r.Columns(t); err != nil {
+			return
+		}
+		if t.cons, err = r.Constraints(t); err != nil {
+			return
+		}
+		if t.indexes, err = r.Indexes(t); err != nil {
+			return
+		}
+	}
+	if s.sequences, err = r.Sequences(schemaName); err != nil {
+		return
+	}
+	s.views, err = r.Views(schemaName)
+	return
+}
+
+func (h2 *db) catalogReader() (result CatalogReader, err error) {
+	if h2.conn == nil {
+		err = errors.Errorf("H2 database %s is not connected", h2.path)
+		return
+	}
+	r, err := newH2Reader(h2.conn)
+	if err != nil {
+		return
+	}
+	result = r
+	return
+}
+
+func (h2 *db) grabSchema(schemaName string) (s *schema, err error) {
+	r, err := h2.catalogReader()
+	if err != nil {
+		return
+	}
+	return readSchema(r, schemaName)
+}
@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// rowCursor is the result of a query over the rows of a source table, as
+// *sql.Rows gives it.
+type rowCursor interface {
+	IResultSet
+	Err() error
+	Close() error
+}
+
+// rowSource reads the rows of the tables to copy.
+type rowSource interface {
+	Rows(ctx context.Context, t *table) (rowCursor, error)
+}
+
+// copyTarget runs a COPY ... FROM STDIN statement on PostgreSQL reading the
+// data from r, and returns the number of rows copied. It is the shape of
+// CopyFrom of *pgconn.PgConn, up to the command tag.
+type copyTarget interface {
+	CopyFrom(ctx context.Context, r io.Reader, statement string) (rows int64, err error)
+}
+
+// Rows selects all the columns of the table, in the order of t.columns.
+func (h2 *db) Rows(ctx context.Context, t *table) (result rowCursor, err error) {
+	var names []string
+	for _, c := range t.columns {
+		names = append(names, quoteIdent(c.columnName))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s",
+		strings.Join(names, ","),
+		quoteQualified(t.schemaName, t.tableName),
+	)
+	var rows *sql.Rows
+	if rows, err = h2.conn.QueryContext(ctx, query); err != nil {
+		err = errors.Wrapf(err, "could not read the rows of %s.%s", t.schemaName, t.tableName)
+		return
+	}
+	result = rows
+	return
+}
+
+type copyFormat int
+
+const (
+	copyText   copyFormat = iota // The default text format of COPY
+	copyBinary                   // FORMAT binary, for the types binaryEncoders has
+)
+
+type copyOption func(e *copyEngine)
+
+// copyBatchSize sets the number of rows sent by a COPY statement.
+func copyBatchSize(n int) copyOption {
+	return func(e *copyEngine) {
+		e.batchSize = n
+	}
+}
+
+// copyParallelism sets the number of tables copied at the same time.
+func copyParallelism(n int) copyOption {
+	return func(e *copyEngine) {
+		e.parallelism = n
+	}
+}
+
+// copyInFormat sets the format of the COPY data, copyText by default.
+func copyInFormat(f copyFormat) copyOption {
+	return func(e *copyEngine) {
+		e.format = f
+	}
+}
+
+// copyTypes sets the type map the target tables were created with.
+func copyTypes(types *typeMap) copyOption {
+	return func(e *copyEngine) {
+		e.types = types
+	}
+}
+
+// copyEngine copies the rows of tables from a source to PostgreSQL by COPY
+// FROM STDIN, in batches of batchSize rows. The values are converted by the
+// PostgreSQL type of their column, as mapped by types.
+type copyEngine struct {
+	source      rowSource
+	target      copyTarget
+	batchSize   int
+	parallelism int
+	format      copyFormat
+	types       *typeMap
+}
+
+type tableCopySummary struct {
+	schemaName  string
+	tableName   string
+	rowsRead    int64
+	rowsWritten int64
+	duration    time.Duration
+	err         error
+}
+
+func newCopyEngine(source rowSource, target copyTarget, options ...copyOption) (result *copyEngine) {
+	result = &copyEngine{
+		source:      source,
+		target:      target,
+		batchSize:   10000,
+		parallelism: 1,
+	}
+	for _, f := range options {
+		f(result)
+	}
+	if result.batchSize < 1 {
+		result.batchSize = 1
+	}
+	if result.parallelism < 1 {
+		result.parallelism = 1
+	}
+	return
+}
+
+// copyTables copies the tables, parallelism of them at a time, and returns
+// their summaries in the order of tables. A table that fails does not stop
+// the others; the error returned names the failed tables, whose summaries
+// tell the rows copied before the failure.
+func (e *copyEngine) copyTables(ctx context.Context, tables []*table) (result []*tableCopySummary, err error) {
+	result = make([]*tableCopySummary, len(tables))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < e.parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range next {
+				result[k] = e.copyTable(ctx, tables[k])
+			}
+		}()
+	}
+	for k := range tables {
+		next <- k
+	}
+	close(next)
+	wg.Wait()
+
+	var failed []string
+	for _, s := range result {
+		if s.err != nil {
+			failed = append(failed, s.schemaName+"."+s.tableName)
+		}
+	}
+	if len(failed) != 0 {
+		err = errors.Errorf("%d of %d tables failed to copy: %s", len(failed), len(tables), strings.Join(failed, ", "))
+	}
+	return
+}
+
+func (e *copyEngine) copyTable(ctx context.Context, t *table) (result *tableCopySummary) {
+	result = &tableCopySummary{
+		schemaName: t.schemaName,
+		tableName:  t.tableName,
+	}
+	start := time.Now()
+	result.err = e.copyRows(ctx, t, result)
+	result.duration = time.Since(start)
+	return
+}
+
+func (e *copyEngine) copyRows(ctx context.Context, t *table, summary *tableCopySummary) (err error) {
+	enc, err := e.newRowEncoder(t)
+	if err != nil {
+		return
+	}
+	statement := fmt.Sprintf("copy %s (%s) from stdin", quoteQualified(t.schemaName, t.tableName), quoteColumnList(enc.columns))
+	if e.format == copyBinary {
+		statement += " with (format binary)"
+	}
+	rows, err := e.source.Rows(ctx, t)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(t.columns))
+	dest := make([]interface{}, len(t.columns))
+	for k := range values {
+		dest[k] = &values[k]
+	}
+	var buf bytes.Buffer
+	var batch int64
+	flush := func() (err error) {
+		enc.end(&buf)
+		var n int64
+		if n, err = e.target.CopyFrom(ctx, &buf, statement); err != nil {
+			err = errors.Wrapf(err, "could not copy %d rows into %s.%s", batch, t.schemaName, t.tableName)
+			return
+		}
+		summary.rowsWritten += n
+		if n != batch {
+			err = errors.Errorf("%d rows sent to %s.%s, %d copied", batch, t.schemaName, t.tableName, n)
+			return
+		}
+		buf.Reset()
+		batch = 0
+		return
+	}
+	for rows.Next() {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = rows.Scan(dest...); err != nil {
+			err = errors.Wrapf(err, "could not read row %d of %s.%s", summary.rowsRead+1, t.schemaName, t.tableName)
+			return
+		}
+		summary.rowsRead++
+		if batch == 0 {
+			enc.begin(&buf)
+		}
+		if err = enc.row(&buf, values); err != nil {
+			err = errors.Wrapf(err, "row %d of %s.%s", summary.rowsRead, t.schemaName, t.tableName)
+			return
+		}
+		if batch++; batch == int64(e.batchSize) {
+			if err = flush(); err != nil {
+				return
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		err = errors.Wrapf(err, "could not read the rows of %s.%s", t.schemaName, t.tableName)
+		return
+	}
+	if batch != 0 {
+		err = flush()
+	}
+	return
+}
+
+// valueEncoder appends a non-NULL value in the format of a column type.
+type valueEncoder func(buf *bytes.Buffer, v interface{}) error
+
+// rowEncoder writes rows in the COPY format of the engine.
+type rowEncoder struct {
+	format   copyFormat
+	columns  []string
+	encoders []valueEncoder
+}
+
+func (e *copyEngine) newRowEncoder(t *table) (result *rowEncoder, err error) {
+	result = &rowEncoder{format: e.format}
+	for _, c := range t.columns {
+		var pgType string
+		if pgType, err = e.types.pgType(c); err != nil {
+			return
+		}
+		base := pgBaseType(pgType)
+		var enc valueEncoder
+		if e.format == copyBinary {
+			var ok bool
+			if enc, ok = binaryEncoders[base]; !ok {
+				err = errors.Errorf("column %s.%s.%s of type %s cannot be copied in the binary format",
+					c.schemaName, c.tableName, c.columnName, pgType)
+				return
+			}
+		} else {
+			enc = textEncoderOf(base)
+		}
+		result.columns = append(result.columns, c.columnName)
+		result.encoders = append(result.encoders, enc)
+	}
+	if len(result.columns) == 0 {
+		err = errors.Errorf("table %s.%s has no columns to copy", t.schemaName, t.tableName)
+	}
+	return
+}
+
+// pgBaseType returns a PostgreSQL type without its modifier:
+// "timestamp(3) with time zone" is a "timestamp with time zone".
+func pgBaseType(pgType string) string {
+	if k := strings.Index(pgType, "("); k >= 0 {
+		if l := strings.Index(pgType[k:], ")"); l >= 0 {
+			pgType = pgType[:k] + pgType[k+l+1:]
+		}
+	}
+	return strings.TrimSpace(pgType)
+}
+
+// The signature, flags and header extension length of the binary format.
+var pgCopySignature = []byte("PGCOPY\n\377\r\n\000\000\000\000\000\000\000\000\000")
+
+func (enc *rowEncoder) begin(buf *bytes.Buffer) {
+	if enc.format == copyBinary {
+		buf.Write(pgCopySignature)
+	}
+}
+
+func (enc *rowEncoder) end(buf *bytes.Buffer) {
+	if enc.format == copyBinary {
+		binary.Write(buf, binary.BigEndian, int16(-1))
+	}
+}
+
+func (enc *rowEncoder) row(buf *bytes.Buffer, values []interface{}) (err error) {
+	if enc.format == copyBinary {
+		binary.Write(buf, binary.BigEndian, int16(len(values)))
+	}
+	for k, v := range values {
+		if enc.format == copyText && k > 0 {
+			buf.WriteByte('\t')
+		}
+		if v == nil {
+			if enc.format == copyBinary {
+				binary.Write(buf, binary.BigEndian, int32(-1))
+			} else {
+				buf.WriteString(`\N`)
+			}
+			continue
+		}
+		at := buf.Len()
+		if enc.format == copyBinary {
+			buf.Write([]byte{0, 0, 0, 0}) //  Length, set once written
+		}
+		if err = enc.encoders[k](buf, v); err != nil {
+			err = errors.Wrapf(err, "column %s", enc.columns[k])
+			return
+		}
+		if enc.format == copyBinary {
+			binary.BigEndian.PutUint32(buf.Bytes()[at:], uint32(buf.Len()-at-4))
+		}
+	}
+	if enc.format == copyText {
+		buf.WriteByte('\n')
+	}
+	return
+}
+
+// textEncoderOf returns the text format encoder of a PostgreSQL type. The
+// value is written as PostgreSQL reads a literal of the type, escaped for
+// COPY.
+func textEncoderOf(pgType string) valueEncoder {
+	var format func(v interface{}) string
+	switch pgType {
+	case "bytea":
+		format = func(v interface{}) string {
+			return `\x` + hex.EncodeToString(bytesOf(v))
+		}
+	case "date":
+		format = timeFormat("2006-01-02")
+	case "time without time zone":
+		format = timeFormat("15:04:05.999999")
+	case "time with time zone":
+		format = timeFormat("15:04:05.999999-07:00")
+	case "timestamp without time zone":
+		format = timeFormat("2006-01-02 15:04:05.999999")
+	default:
+		if strings.HasSuffix(pgType, "[]") {
+			format = arrayLiteral
+		} else {
+			format = textOf
+		}
+	}
+	return func(buf *bytes.Buffer, v interface{}) error {
+		copyEscape(buf, format(v))
+		return nil
+	}
+}
+
+func timeFormat(layout string) func(v interface{}) string {
+	return func(v interface{}) string {
+		if t, ok := v.(time.Time); ok {
+			return t.Format(layout)
+		}
+		return textOf(v)
+	}
+}
+
+// textOf returns the PostgreSQL literal of a value of the database/sql types.
+func textOf(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999-07:00")
+	}
+	return fmt.Sprint(v)
+}
+
+func bytesOf(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return []byte(textOf(v))
+}
+
+// arrayLiteral returns the PostgreSQL array literal of an array value, with
+// every element quoted.
+func arrayLiteral(v interface{}) string {
+	elements, ok := v.([]interface{})
+	if !ok {
+		return textOf(v)
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for k, e := range elements {
+		if k > 0 {
+			sb.WriteByte(',')
+		}
+		if e == nil {
+			sb.WriteString("NULL")
+			continue
+		}
+		sb.WriteByte('"')
+		for _, r := range textOf(e) {
+			if r == '"' || r == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(r)
+		}
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// copyEscape writes s escaped for the text format of COPY.
+func copyEscape(buf *bytes.Buffer, s string) {
+	for k := 0; k < len(s); k++ {
+		switch c := s[k]; c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// binaryEncoders holds the encoders of the binary format, by PostgreSQL
+// type; a column of another type is copied in the text format only.
+var binaryEncoders = map[string]valueEncoder{
+	"boolean": func(buf *bytes.Buffer, v interface{}) error {
+		b, ok := v.(bool)
+		if !ok {
+			switch s := strings.ToLower(textOf(v)); s {
+			case "t", "true", "1":
+				b = true
+			case "f", "false", "0":
+			default:
+				return errors.Errorf("%q is not a boolean", s)
+			}
+		}
+		if b {
+			return buf.WriteByte(1)
+		}
+		return buf.WriteByte(0)
+	},
+	"smallint": integerEncoder(16),
+	"integer":  integerEncoder(32),
+	"bigint":   integerEncoder(64),
+	"real": func(buf *bytes.Buffer, v interface{}) error {
+		f, err := floatOf(v)
+		if err == nil {
+			err = binary.Write(buf, binary.BigEndian, math.Float32bits(float32(f)))
+		}
+		return err
+	},
+	"double precision": func(buf *bytes.Buffer, v interface{}) error {
+		f, err := floatOf(v)
+		if err == nil {
+			err = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		}
+		return err
+	},
+	"numeric":           numericEncoder,
+	"text":              textBinaryEncoder,
+	"character varying": textBinaryEncoder,
+	"character":         textBinaryEncoder,
+	"citext":            textBinaryEncoder,
+	"json":              textBinaryEncoder,
+	"jsonb": func(buf *bytes.Buffer, v interface{}) error {
+		buf.WriteByte(1) //  Version of the jsonb binary format
+		return textBinaryEncoder(buf, v)
+	},
+	"bytea": func(buf *bytes.Buffer, v interface{}) error {
+		buf.Write(bytesOf(v))
+		return nil
+	},
+	"uuid": func(buf *bytes.Buffer, v interface{}) error {
+		if b, ok := v.([]byte); ok && len(b) == 16 {
+			buf.Write(b)
+			return nil
+		}
+		s := strings.Replace(textOf(v), "-", "", -1)
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != 16 {
+			return errors.Errorf("%q is not a UUID", textOf(v))
+		}
+		buf.Write(b)
+		return nil
+	},
+	"date": timeEncoder(func(t time.Time) interface{} {
+		y, m, d := t.Date()
+		return int32(julianDay(y, m, d) - pgEpochDay)
+	}),
+	"time without time zone": timeEncoder(func(t time.Time) interface{} {
+		h, m, s := t.Clock()
+		return (int64(h*3600+m*60+s)*1000000 + int64(t.Nanosecond()/1000))
+	}),
+	"timestamp without time zone": timeEncoder(func(t time.Time) interface{} {
+		/*  The wall clock time, whatever the location of t. */
+		y, mo, d := t.Date()
+		h, mi, s := t.Clock()
+		return pgMicroseconds(time.Date(y, mo, d, h, mi, s, t.Nanosecond(), time.UTC))
+	}),
+	"timestamp with time zone": timeEncoder(func(t time.Time) interface{} {
+		return pgMicroseconds(t)
+	}),
+}
+
+// pgEpoch is the zero of the binary dates and timestamps of PostgreSQL.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// pgEpochDay is the Julian day number of pgEpoch.
+const pgEpochDay = 2451545
+
+// julianDay returns the Julian day number of a date of the proleptic
+// Gregorian calendar.
+func julianDay(y int, m time.Month, d int) int {
+	a := (14 - int(m)) / 12
+	y, mm := y+4800-a, int(m)+12*a-3
+	return d + (153*mm+2)/5 + 365*y + y/4 - y/100 + y/400 - 32045
+}
+
+// pgMicroseconds returns the microseconds from pgEpoch to t. Unlike
+// t.Sub, it does not saturate for the years far from 2000.
+func pgMicroseconds(t time.Time) int64 {
+	return (t.Unix()-pgEpoch.Unix())*1000000 + int64(t.Nanosecond()/1000)
+}
+
+func textBinaryEncoder(buf *bytes.Buffer, v interface{}) error {
+	buf.WriteString(textOf(v))
+	return nil
+}
+
+func integerEncoder(bits int) valueEncoder {
+	return func(buf *bytes.Buffer, v interface{}) error {
+		i, ok := v.(int64)
+		if !ok {
+			var err error
+			if i, err = strconv.ParseInt(strings.TrimSpace(textOf(v)), 10, 64); err != nil {
+				return errors.Errorf("%q is not an integer", textOf(v))
+			}
+		}
+		switch {
+		case bits == 16 && i == int64(int16(i)):
+			return binary.Write(buf, binary.BigEndian, int16(i))
+		case bits == 32 && i == int64(int32(i)):
+			return binary.Write(buf, binary.BigEndian, int32(i))
+		case bits == 64:
+			return binary.Write(buf, binary.BigEndian, i)
+		}
+		return errors.Errorf("%d is out of range of a %d bit integer", i, bits)
+	}
+}
+
+func floatOf(v interface{}) (result float64, err error) {
+	switch v := v.(type) {
+	case float64:
+		result = v
+	case int64:
+		result = float64(v)
+	default:
+		if result, err = strconv.ParseFloat(strings.TrimSpace(textOf(v)), 64); err != nil {
+			err = errors.Errorf("%q is not a number", textOf(v))
+		}
+	}
+	return
+}
+
+func timeEncoder(value func(t time.Time) interface{}) valueEncoder {
+	return func(buf *bytes.Buffer, v interface{}) error {
+		t, ok := v.(time.Time)
+		if !ok {
+			return errors.Errorf("%v is not a time value", v)
+		}
+		return binary.Write(buf, binary.BigEndian, value(t))
+	}
+}
+
+// The sign of the binary numeric format.
+const (
+	numericPositive = 0x0000
+	numericNegative = 0x4000
+	numericNaN      = 0xC000
+)
+
+// numericEncoder writes a decimal number, given in any of the database/sql
+// types, as the base 10000 digits of the binary numeric format.
+func numericEncoder(buf *bytes.Buffer, v interface{}) error {
+	var s string
+	if f, ok := v.(float64); ok {
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	} else {
+		s = strings.TrimSpace(textOf(v))
+	}
+	var sign uint16 = numericPositive
+	var dscale int
+	if strings.EqualFold(s, "NaN") {
+		return binary.Write(buf, binary.BigEndian, []uint16{0, 0, numericNaN, 0})
+	}
+	mantissa, exponent := s, 0
+	if k := strings.IndexAny(s, "eE"); k >= 0 {
+		var err error
+		if exponent, err = strconv.Atoi(s[k+1:]); err != nil {
+			return errors.Errorf("%q is not a number", s)
+		}
+		mantissa = s[:k]
+	}
+	if strings.HasPrefix(mantissa, "-") {
+		sign = numericNegative
+		mantissa = mantissa[1:]
+	} else {
+		mantissa = strings.TrimPrefix(mantissa, "+")
+	}
+	intPart, fracPart := mantissa, ""
+	if k := strings.IndexByte(mantissa, '.'); k >= 0 {
+		intPart, fracPart = mantissa[:k], mantissa[k+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return errors.Errorf("%q is not a number", s)
+	}
+	point := len(intPart) + exponent //  Digits before the decimal point
+	if scale := len(digits) - point; scale > 0 {
+		dscale = scale
+	}
+	/*  Align the decimal point and the end of the digits on base 10000
+	digit boundaries. */
+	if pad := (4 - (point%4+4)%4) % 4; pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+		point += pad
+	}
+	if len(digits) < point {
+		digits += strings.Repeat("0", point-len(digits))
+	}
+	if r := len(digits) % 4; r > 0 {
+		digits += strings.Repeat("0", 4-r)
+	}
+	var groups []uint16
+	for k := 0; k < len(digits); k += 4 {
+		g, _ := strconv.Atoi(digits[k : k+4])
+		groups = append(groups, uint16(g))
+	}
+	weight := point/4 - 1
+	for len(groups) > 0 && groups[0] == 0 {
+		groups = groups[1:]
+		weight--
+	}
+	for len(groups) > 0 && groups[len(groups)-1] == 0 {
+		groups = groups[:len(groups)-1]
+	}
+	if len(groups) == 0 {
+		weight, sign = 0, numericPositive
+	}
+	if weight != int(int16(weight)) || dscale > math.MaxInt16 {
+		return errors.Errorf("%q is out of range of numeric", s)
+	}
+	header := []uint16{uint16(len(groups)), uint16(int16(weight)), sign, uint16(dscale)}
+	return binary.Write(buf, binary.BigEndian, append(header, groups...))
+}
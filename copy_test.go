@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type testCursor struct {
+	rows [][]interface{}
+	next int
+}
+
+func (c *testCursor) Next() bool {
+	c.next++
+	return c.next <= len(c.rows)
+}
+
+func (c *testCursor) Scan(dst ...interface{}) error {
+	for k, d := range dst {
+		*d.(*interface{}) = c.rows[c.next-1][k]
+	}
+	return nil
+}
+
+func (c *testCursor) Err() error   { return nil }
+func (c *testCursor) Close() error { return nil }
+
+type testSource map[string][][]interface{}
+
+func (s testSource) Rows(ctx context.Context, t *table) (rowCursor, error) {
+	return &testCursor{rows: s[t.tableName]}, nil
+}
+
+type testCopy struct {
+	statement string
+	data      []byte
+}
+
+// testTarget takes the COPY data in memory, counting the rows it holds.
+type testTarget struct {
+	mu     sync.Mutex
+	copies []testCopy
+	fail   string // Table whose copy fails
+}
+
+func (tt *testTarget) CopyFrom(ctx context.Context, r io.Reader, statement string) (rows int64, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	if tt.fail != "" && strings.Contains(statement, "."+quoteIdent(tt.fail)+" ") {
+		err = errors.New("relation does not exist")
+		return
+	}
+	tt.mu.Lock()
+	tt.copies = append(tt.copies, testCopy{statement, data})
+	tt.mu.Unlock()
+	if !strings.HasSuffix(statement, "(format binary)") {
+		rows = int64(bytes.Count(data, []byte("\n")))
+		return
+	}
+	if !bytes.HasPrefix(data, pgCopySignature) {
+		err = errors.New("no binary COPY signature")
+		return
+	}
+	data = data[len(pgCopySignature):]
+	for {
+		fields := int16(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if fields == -1 {
+			break
+		}
+		for ; fields > 0; fields-- {
+			size := int32(binary.BigEndian.Uint32(data))
+			data = data[4:]
+			if size > 0 {
+				data = data[size:]
+			}
+		}
+		rows++
+	}
+	if len(data) != 0 {
+		err = errors.New("data after the binary COPY trailer")
+	}
+	return
+}
+
+func testCopyTable(name string, types ...string) *table {
+	t := &table{schemaName: "PUBLIC", tableName: name}
+	for k, typeName := range types {
+		t.columns = append(t.columns, &column{
+			schemaName: "PUBLIC",
+			tableName:  name,
+			columnName: "C" + string(rune('1'+k)),
+			typeName:   typeName,
+			position:   k + 1,
+		})
+	}
+	return t
+}
+
+func TestCopyTablesText(t *testing.T) {
+	at := time.Date(2020, 2, 29, 13, 14, 15, 500000000, time.UTC)
+	source := testSource{"T": {
+		{int64(1), "a\tb\\c\nd", true, at, []byte{0xde, 0xad}},
+		{int64(2), nil, false, nil, nil},
+		{int64(3), []byte("x"), nil, at, []byte{}},
+	}}
+	target := new(testTarget)
+	engine := newCopyEngine(source, target, copyBatchSize(2))
+	summaries, err := engine.copyTables(context.Background(), []*table{
+		testCopyTable("T", "BIGINT", "VARCHAR", "BOOLEAN", "TIMESTAMP", "VARBINARY"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := summaries[0]; s.rowsRead != 3 || s.rowsWritten != 3 || s.err != nil {
+		t.Error(errors.Errorf("summary: %+v", s))
+	}
+	statement := `copy "PUBLIC"."T" ("C1","C2","C3","C4","C5") from stdin`
+	want := []testCopy{
+		{statement, []byte("1\ta\\tb\\\\c\\nd\tt\t2020-02-29 13:14:15.5\t\\\\xdead\n2\t\\N\tf\t\\N\t\\N\n")},
+		{statement, []byte("3\tx\t\\N\t2020-02-29 13:14:15.5\t\\\\x\n")},
+	}
+	if !reflect.DeepEqual(target.copies, want) {
+		t.Error(errors.Errorf("copies: %q", target.copies))
+	}
+}
+
+func TestCopyTablesParallel(t *testing.T) {
+	source := testSource{}
+	var tables []*table
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		for k := 0; k < 10; k++ {
+			source[name] = append(source[name], []interface{}{int64(k)})
+		}
+		tables = append(tables, testCopyTable(name, "INT"))
+	}
+	target := &testTarget{fail: "C"}
+	engine := newCopyEngine(source, target, copyBatchSize(3), copyParallelism(3), copyInFormat(copyBinary))
+	summaries, err := engine.copyTables(context.Background(), tables)
+	if err == nil || !strings.Contains(err.Error(), "PUBLIC.C") {
+		t.Error(errors.Errorf("failed table not reported: %v", err))
+	}
+	for k, s := range summaries {
+		switch {
+		case s.tableName != tables[k].tableName:
+			t.Error(errors.Errorf("summary %d is of %s", k, s.tableName))
+		case s.tableName == "C" && (s.err == nil || s.rowsWritten != 0):
+			t.Error(errors.Errorf("summary of the failed table: %+v", s))
+		case s.tableName != "C" && (s.err != nil || s.rowsRead != 10 || s.rowsWritten != 10):
+			t.Error(errors.Errorf("summary: %+v", s))
+		}
+	}
+	if len(target.copies) != 4*4 {
+		t.Error(errors.Errorf("%d batches copied", len(target.copies)))
+	}
+}
+
+func TestCopyBinaryUnsupported(t *testing.T) {
+	engine := newCopyEngine(testSource{}, new(testTarget), copyInFormat(copyBinary))
+	summaries, err := engine.copyTables(context.Background(), []*table{testCopyTable("T", "INTERVAL DAY")})
+	if err == nil || summaries[0].err == nil || !strings.Contains(summaries[0].err.Error(), "binary format") {
+		t.Error(errors.Errorf("interval copied in the binary format: %v", err))
+	}
+}
+
+func TestBinaryEncoders(t *testing.T) {
+	for _, test := range []struct {
+		pgType string
+		value  interface{}
+		want   []uint16
+	}{
+		{"numeric", "123.45", []uint16{2, 0, numericPositive, 2, 123, 4500}},
+		{"numeric", []byte("-0.001"), []uint16{1, 0xffff, numericNegative, 3, 10}},
+		{"numeric", "1E+5", []uint16{1, 1, numericPositive, 0, 10}},
+		{"numeric", "12345678", []uint16{2, 1, numericPositive, 0, 1234, 5678}},
+		{"numeric", int64(0), []uint16{0, 0, numericPositive, 0}},
+		{"numeric", "0.00", []uint16{0, 0, numericPositive, 2}},
+		{"numeric", "NaN", []uint16{0, 0, numericNaN, 0}},
+		{"smallint", int64(-2), []uint16{0xfffe}},
+		{"integer", "7", []uint16{0, 7}},
+		{"date", time.Date(2000, 1, 2, 23, 0, 0, 0, time.UTC), []uint16{0, 1}},
+		{"timestamp without time zone", time.Date(2000, 1, 1, 0, 0, 1, 0, time.FixedZone("X", 3600)), []uint16{0, 0, 0x000f, 0x4240}},
+		{"date", time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), []uint16{0xfff4, 0xdbf9}},
+		{"date", time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC), []uint16{0x002c, 0x95d3}},
+		{"timestamp without time zone", time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), []uint16{0xff1f, 0xe2ff, 0xc59c, 0x6000}},
+		{"timestamp with time zone", time.Date(9999, 12, 31, 23, 59, 59, 999999000, time.UTC), []uint16{0x0380, 0xe70b, 0x913b, 0x7fff}},
+	} {
+		var buf bytes.Buffer
+		if err := binaryEncoders[test.pgType](&buf, test.value); err != nil {
+			t.Error(err)
+			continue
+		}
+		got := make([]uint16, buf.Len()/2)
+		binary.Read(&buf, binary.BigEndian, got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Error(errors.Errorf("%s %v: %v, want %v", test.pgType, test.value, got, test.want))
+		}
+	}
+	var buf bytes.Buffer
+	if err := binaryEncoders["smallint"](&buf, int64(40000)); err == nil {
+		t.Error(errors.New("no error on smallint overflow"))
+	}
+	if err := binaryEncoders["numeric"](&buf, "1.2.3"); err == nil {
+		t.Error(errors.New("no error on a malformed number"))
+	}
+}
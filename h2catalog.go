@@ -0,0 +1,546 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// h2Catalog holds the INFORMATION_SCHEMA queries of an H2 major version.
+// Whatever the version, each query returns the columns its collect function
+// scans, so that both fill the same model. The queries take the schema name
+// and, for the per table queries, the table name as parameters.
+type h2Catalog struct {
+	major       int
+	schemas     string
+	tables      string
+	columns     string
+	identities  string
+	constraints string
+	references  string
+	indexes     string
+	sequences   string
+	views       string
+}
+
+// The queries that read the same in both layouts.
+const (
+	h2SchemasQuery = `
+	SELECT S.SCHEMA_NAME
+	FROM INFORMATION_SCHEMA.SCHEMATA S
+	WHERE S.SCHEMA_NAME <> 'INFORMATION_SCHEMA'
+	ORDER BY S.SCHEMA_NAME
+	`
+	h2ViewsQuery = `
+	SELECT 
+		V.TABLE_SCHEMA
+		,V.TABLE_NAME
+		,V.VIEW_DEFINITION
+	FROM INFORMATION_SCHEMA.VIEWS V
+	WHERE V.TABLE_SCHEMA = ?
+	ORDER BY V.TABLE_NAME
+	`
+)
+
+// h2v1Catalog reads the H2 1.4 layout: COLUMNS.TYPE_NAME, TABLES.STORAGE_TYPE,
+// CONSTRAINTS.COLUMN_LIST, CROSS_REFERENCES and a row per index column in
+// INDEXES.
+var h2v1Catalog = &h2Catalog{
+	major:   1,
+	schemas: h2SchemasQuery,
+	tables: `
+	SELECT 
+		T.TABLE_SCHEMA
+		,T.TABLE_NAME
+	FROM INFORMATION_SCHEMA.TABLES T
+	WHERE T.TABLE_SCHEMA = ?
+		AND T.TABLE_TYPE='TABLE' 
+		AND T.STORAGE_TYPE = 'CACHED' 
+	ORDER BY T.TABLE_NAME
+	`,
+	columns: `
+	SELECT 
+        C.TABLE_SCHEMA
+        ,C.TABLE_NAME
+        ,C.COLUMN_NAME 
+		,C.ORDINAL_POSITION 
+		,C.COLUMN_DEFAULT 
+		,C.NULLABLE 
+		,C.TYPE_NAME 
+		,C.CHARACTER_MAXIMUM_LENGTH 
+		,C.NUMERIC_PRECISION 
+		,C.NUMERIC_SCALE
+	FROM INFORMATION_SCHEMA.COLUMNS C
+  		INNER JOIN INFORMATION_SCHEMA.TABLES  T
+		ON T.TABLE_CATALOG = C.TABLE_CATALOG
+		AND T.TABLE_SCHEMA = C.TABLE_SCHEMA
+		AND T.TABLE_NAME = C.TABLE_NAME
+	WHERE T.TABLE_SCHEMA = ?
+		AND T.TABLE_TYPE='TABLE' 
+		AND T.STORAGE_TYPE = 'CACHED' 
+		AND T.TABLE_NAME = ?
+	ORDER BY 
+		C.TABLE_NAME ASC
+		,C.ORDINAL_POSITION ASC
+`,
+	constraints: `
+	SELECT 
+	C.CONSTRAINT_TYPE
+	,C.CONSTRAINT_NAME
+	,C.COLUMN_LIST
+	,C.UNIQUE_INDEX_NAME
+	,C.CHECK_EXPRESSION
+   FROM INFORMATION_SCHEMA.CONSTRAINTS C
+   INNER JOIN INFORMATION_SCHEMA.TABLES T
+	ON T.TABLE_CATALOG = C.TABLE_CATALOG
+   AND T.TABLE_SCHEMA = C.TABLE_SCHEMA
+   AND T.TABLE_NAME = C.TABLE_NAME
+   WHERE T.TABLE_SCHEMA = ?
+   AND T.TABLE_TYPE='TABLE' 
+   AND T.STORAGE_TYPE = 'CACHED' 
+   AND T.TABLE_NAME = ?
+   `,
+	references: `
+	SELECT 
+		R.FK_NAME
+		,R.FKCOLUMN_NAME
+		,R.PKTABLE_SCHEMA
+		,R.PKTABLE_NAME
+		,R.PKCOLUMN_NAME
+		,R.UPDATE_RULE
+		,R.DELETE_RULE
+	FROM INFORMATION_SCHEMA.CROSS_REFERENCES R
+	WHERE R.FKTABLE_SCHEMA = ?
+		AND R.FKTABLE_NAME = ?
+	ORDER BY R.FK_NAME, R.ORDINAL_POSITION ASC
+   `,
+	indexes: `
+	SELECT 
+		C.TABLE_SCHEMA
+		,C.TABLE_NAME
+		,C.INDEX_NAME
+		,C.NON_UNIQUE
+		,C.INDEX_TYPE_NAME
+		,C.CONSTRAINT_NAME
+		,C.COLUMN_NAME
+		,C.ORDINAL_POSITION
+		,C.ASC_OR_DESC
+	FROM INFORMATION_SCHEMA.INDEXES C
+		INNER JOIN  INFORMATION_SCHEMA.TABLES T
+			ON T.TABLE_CATALOG = C.TABLE_CATALOG
+			AND T.TABLE_SCHEMA = C.TABLE_SCHEMA
+			AND T.TABLE_NAME = C.TABLE_NAME
+	WHERE T.TABLE_SCHEMA = ?
+		AND T.TABLE_TYPE='TABLE' 
+		AND T.STORAGE_TYPE = 'CACHED' 
+		AND T.TABLE_NAME = ?
+      ORDER BY C.INDEX_NAME,C.ORDINAL_POSITION asc
+   `,
+	identities: `
+	SELECT 
+		C.COLUMN_NAME
+		,'BY DEFAULT'
+		,S.CURRENT_VALUE + S.INCREMENT
+		,S.INCREMENT
+		,S.MIN_VALUE
+		,S.MAX_VALUE
+		,S.IS_CYCLE
+		,S.CACHE
+	FROM INFORMATION_SCHEMA.COLUMNS C
+		INNER JOIN INFORMATION_SCHEMA.SEQUENCES S
+		ON S.SEQUENCE_CATALOG = C.TABLE_CATALOG
+		AND S.SEQUENCE_SCHEMA = C.TABLE_SCHEMA
+		AND S.SEQUENCE_NAME = C.SEQUENCE_NAME
+	WHERE C.TABLE_SCHEMA = ?
+		AND C.TABLE_NAME = ?
+	ORDER BY C.ORDINAL_POSITION
+	`,
+	sequences: `
+	SELECT 
+		S.SEQUENCE_SCHEMA
+		,S.SEQUENCE_NAME
+		,S.CURRENT_VALUE + S.INCREMENT
+		,S.INCREMENT
+		,S.MIN_VALUE
+		,S.MAX_VALUE
+		,S.IS_CYCLE
+		,S.CACHE
+		,S.IS_GENERATED
+	FROM INFORMATION_SCHEMA.SEQUENCES S
+	WHERE S.SEQUENCE_SCHEMA = ?
+	ORDER BY S.SEQUENCE_NAME
+	`,
+	views: h2ViewsQuery,
+}
+
+// h2v2Catalog reads the H2 2.x layout, which follows the SQL standard:
+// COLUMNS.DATA_TYPE (with ELEMENT_TYPES for arrays), TABLE_CONSTRAINTS with
+// KEY_COLUMN_USAGE and CHECK_CONSTRAINTS, REFERENTIAL_CONSTRAINTS, and
+// INDEX_COLUMNS. Values are translated into their 1.4 form: constraint type
+// REFERENTIAL, referential actions as java.sql.DatabaseMetaData numbers, and
+// A/D column ordering.
+var h2v2Catalog = &h2Catalog{
+	major:   2,
+	schemas: h2SchemasQuery,
+	tables: `
+	SELECT 
+		T.TABLE_SCHEMA
+		,T.TABLE_NAME
+	FROM INFORMATION_SCHEMA.TABLES T
+	WHERE T.TABLE_SCHEMA = ?
+		AND T.TABLE_TYPE = 'BASE TABLE' 
+	ORDER BY T.TABLE_NAME
+	`,
+	columns: `
+	SELECT 
+		C.TABLE_SCHEMA
+		,C.TABLE_NAME
+		,C.COLUMN_NAME 
+		,C.ORDINAL_POSITION 
+		,COALESCE(C.COLUMN_DEFAULT, '')
+		,CASE WHEN C.IS_NULLABLE = 'YES' THEN 1 ELSE 0 END
+		,CASE WHEN C.DATA_TYPE = 'ARRAY' THEN E.DATA_TYPE || ' ARRAY' ELSE C.DATA_TYPE END
+		,COALESCE(C.CHARACTER_MAXIMUM_LENGTH, 0)
+		,COALESCE(C.NUMERIC_PRECISION, 0)
+		,COALESCE(C.NUMERIC_SCALE, C.DATETIME_PRECISION, -1)
+	FROM INFORMATION_SCHEMA.COLUMNS C
+		INNER JOIN INFORMATION_SCHEMA.TABLES T
+		ON T.TABLE_CATALOG = C.TABLE_CATALOG
+		AND T.TABLE_SCHEMA = C.TABLE_SCHEMA
+		AND T.TABLE_NAME = C.TABLE_NAME
+		LEFT JOIN INFORMATION_SCHEMA.ELEMENT_TYPES E
+		ON E.OBJECT_CATALOG = C.TABLE_CATALOG
+		AND E.OBJECT_SCHEMA = C.TABLE_SCHEMA
+		AND E.OBJECT_NAME = C.TABLE_NAME
+		AND E.OBJECT_TYPE = 'TABLE'
+		AND E.COLLECTION_TYPE_IDENTIFIER = C.DTD_IDENTIFIER
+	WHERE T.TABLE_SCHEMA = ?
+		AND T.TABLE_TYPE = 'BASE TABLE' 
+		AND T.TABLE_NAME = ?
+	ORDER BY 
+		C.TABLE_NAME ASC
+		,C.ORDINAL_POSITION ASC
+`,
+	constraints: `
+	SELECT 
+		CASE TC.CONSTRAINT_TYPE WHEN 'FOREIGN KEY' THEN 'REFERENTIAL' ELSE TC.CONSTRAINT_TYPE END
+		,TC.CONSTRAINT_NAME
+		,COALESCE((
+			SELECT LISTAGG(K.COLUMN_NAME, ',') WITHIN GROUP (ORDER BY K.ORDINAL_POSITION)
+			FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE K
+			WHERE K.CONSTRAINT_CATALOG = TC.CONSTRAINT_CATALOG
+			AND K.CONSTRAINT_SCHEMA = TC.CONSTRAINT_SCHEMA
+			AND K.CONSTRAINT_NAME = TC.CONSTRAINT_NAME
+		), '')
+		,COALESCE(TC.INDEX_NAME, '')
+		,COALESCE(CC.CHECK_CLAUSE, '')
+	FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS TC
+		INNER JOIN INFORMATION_SCHEMA.TABLES T
+		ON T.TABLE_CATALOG = TC.TABLE_CATALOG
+		AND T.TABLE_SCHEMA = TC.TABLE_SCHEMA
+		AND T.TABLE_NAME = TC.TABLE_NAME
+		LEFT JOIN INFORMATION_SCHEMA.CHECK_CONSTRAINTS CC
+		ON CC.CONSTRAINT_CATALOG = TC.CONSTRAINT_CATALOG
+		AND CC.CONSTRAINT_SCHEMA = TC.CONSTRAINT_SCHEMA
+		AND CC.CONSTRAINT_NAME = TC.CONSTRAINT_NAME
+	WHERE T.TABLE_SCHEMA = ?
+		AND T.TABLE_TYPE = 'BASE TABLE' 
+		AND T.TABLE_NAME = ?
+	`,
+	references: `
+	SELECT 
+		R.CONSTRAINT_NAME
+		,FK.COLUMN_NAME
+		,PK.TABLE_SCHEMA
+		,PK.TABLE_NAME
+		,PK.COLUMN_NAME
+		,` + h2v2RuleNumber("R.UPDATE_RULE") + `
+		,` + h2v2RuleNumber("R.DELETE_RULE") + `
+	FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS R
+		INNER JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE FK
+		ON FK.CONSTRAINT_CATALOG = R.CONSTRAINT_CATALOG
+		AND FK.CONSTRAINT_SCHEMA = R.CONSTRAINT_SCHEMA
+		AND FK.CONSTRAINT_NAME = R.CONSTRAINT_NAME
+		INNER JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE PK
+		ON PK.CONSTRAINT_CATALOG = R.UNIQUE_CONSTRAINT_CATALOG
+		AND PK.CONSTRAINT_SCHEMA = R.UNIQUE_CONSTRAINT_SCHEMA
+		AND PK.CONSTRAINT_NAME = R.UNIQUE_CONSTRAINT_NAME
+		AND PK.ORDINAL_POSITION = FK.POSITION_IN_UNIQUE_CONSTRAINT
+	WHERE FK.TABLE_SCHEMA = ?
+		AND FK.TABLE_NAME = ?
+	ORDER BY R.CONSTRAINT_NAME, FK.ORDINAL_POSITION ASC
+	`,
+	indexes: `
+	SELECT 
+		I.TABLE_SCHEMA
+		,I.TABLE_NAME
+		,I.INDEX_NAME
+		,NOT IC.IS_UNIQUE
+		,I.INDEX_TYPE_NAME
+		,COALESCE(TC.CONSTRAINT_NAME, '')
+		,IC.COLUMN_NAME
+		,IC.ORDINAL_POSITION
+		,CASE IC.ORDERING_SPECIFICATION WHEN 'DESC' THEN 'D' ELSE 'A' END
+	FROM INFORMATION_SCHEMA.INDEXES I
+		INNER JOIN INFORMATION_SCHEMA.INDEX_COLUMNS IC
+		ON IC.INDEX_CATALOG = I.INDEX_CATALOG
+		AND IC.INDEX_SCHEMA = I.INDEX_SCHEMA
+		AND IC.INDEX_NAME = I.INDEX_NAME
+		INNER JOIN INFORMATION_SCHEMA.TABLES T
+		ON T.TABLE_CATALOG = I.TABLE_CATALOG
+		AND T.TABLE_SCHEMA = I.TABLE_SCHEMA
+		AND T.TABLE_NAME = I.TABLE_NAME
+		LEFT JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS TC
+		ON TC.INDEX_CATALOG = I.INDEX_CATALOG
+		AND TC.INDEX_SCHEMA = I.INDEX_SCHEMA
+		AND TC.INDEX_NAME = I.INDEX_NAME
+	WHERE T.TABLE_SCHEMA = ?
+		AND T.TABLE_TYPE = 'BASE TABLE' 
+		AND T.TABLE_NAME = ?
+	ORDER BY I.INDEX_NAME, IC.ORDINAL_POSITION ASC
+	`,
+	identities: `
+	SELECT 
+		C.COLUMN_NAME
+		,C.IDENTITY_GENERATION
+		,C.IDENTITY_BASE
+		,C.IDENTITY_INCREMENT
+		,C.IDENTITY_MINIMUM
+		,C.IDENTITY_MAXIMUM
+		,C.IDENTITY_CYCLE = 'YES'
+		,C.IDENTITY_CACHE
+	FROM INFORMATION_SCHEMA.COLUMNS C
+	WHERE C.TABLE_SCHEMA = ?
+		AND C.TABLE_NAME = ?
+		AND C.IS_IDENTITY = 'YES'
+	ORDER BY C.ORDINAL_POSITION
+	`,
+	sequences: `
+	SELECT 
+		S.SEQUENCE_SCHEMA
+		,S.SEQUENCE_NAME
+		,S.BASE_VALUE
+		,S.INCREMENT
+		,S.MINIMUM_VALUE
+		,S.MAXIMUM_VALUE
+		,S.CYCLE_OPTION = 'YES'
+		,S.CACHE
+		,FALSE
+	FROM INFORMATION_SCHEMA.SEQUENCES S
+	WHERE S.SEQUENCE_SCHEMA = ?
+	ORDER BY S.SEQUENCE_NAME
+	`,
+	views: h2ViewsQuery,
+}
+
+// h2v2RuleNumber returns the SQL translating an H2 2.x referential action
+// column into the java.sql.DatabaseMetaData number H2 1.4 reports.
+func h2v2RuleNumber(column string) string {
+	return "CASE " + column +
+		" WHEN 'CASCADE' THEN " + strconv.Itoa(h2RuleCascade) +
+		" WHEN 'RESTRICT' THEN " + strconv.Itoa(h2RuleRestrict) +
+		" WHEN 'SET NULL' THEN " + strconv.Itoa(h2RuleSetNull) +
+		" WHEN 'SET DEFAULT' THEN " + strconv.Itoa(h2RuleSetDefault) +
+		" ELSE " + strconv.Itoa(h2RuleNoAction) + " END"
+}
+
+// parseH2Version returns the major version of an H2VERSION() string such as
+// "1.4.200" or "2.1.214".
+func parseH2Version(version string) (major int, err error) {
+	v := strings.TrimSpace(version)
+	if k := strings.IndexAny(v, ". "); k >= 0 {
+		v = v[:k]
+	}
+	if major, err = strconv.Atoi(v); err != nil || major < 1 {
+		err = errors.Errorf("could not parse H2 version %q", version)
+	}
+	return
+}
+
+func catalogFor(major int) (result *h2Catalog, err error) {
+	switch major {
+	case 1:
+		result = h2v1Catalog
+	case 2:
+		result = h2v2Catalog
+	default:
+		err = errors.Errorf("H2 version %d is not supported", major)
+	}
+	return
+}
+
+// h2Reader is the CatalogReader of H2 databases, 1.4 and 2.x.
+type h2Reader struct {
+	conn    *sql.DB
+	queries *h2Catalog
+}
+
+// newH2Reader asks the database for its version, and returns a reader with
+// the queries of that version.
+func newH2Reader(conn *sql.DB) (r *h2Reader, err error) {
+	var version string
+	if err = conn.QueryRow("SELECT H2VERSION()").Scan(&version); err != nil {
+		err = errors.Wrap(err, "could not read H2 version")
+		return
+	}
+	major, err := parseH2Version(version)
+	if err != nil {
+		return
+	}
+	queries, err := catalogFor(major)
+	if err != nil {
+		return
+	}
+	r = &h2Reader{
+		conn:    conn,
+		queries: queries,
+	}
+	return
+}
+
+func (r *h2Reader) Schemas() (result []string, err error) {
+	rs, err := r.conn.Query(r.queries.schemas)
+	if err != nil {
+		err = errors.Wrap(err, "could not open H2 schema metadata")
+		return
+	}
+	defer rs.Close()
+	if result, err = collectNames(rs); err != nil {
+		err = errors.Wrap(err, "could not read H2 schema metadata")
+	}
+	return
+}
+
+func (r *h2Reader) Tables(schemaName string) (result []*table, err error) {
+	rs, err := r.conn.Query(r.queries.tables, schemaName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 table metadata from schema %s", schemaName)
+		return
+	}
+	defer rs.Close()
+	if result, err = collectTableNames(rs); err != nil {
+		err = errors.Wrapf(err, "could not read H2 table metadata from schema %s", schemaName)
+	}
+	return
+}
+
+func (r *h2Reader) Columns(t *table) (result []*column, err error) {
+	rs, err := r.conn.Query(r.queries.columns, t.schemaName, t.tableName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 column metadata for %s.%s", t.schemaName, t.tableName)
+		return
+	}
+	defer rs.Close()
+	tables, err := collectTables(rs)
+	if err != nil {
+		err = errors.Wrapf(err, "could not read H2 column metadata for %s.%s", t.schemaName, t.tableName)
+		return
+	}
+	if len(tables) == 0 {
+		return
+	}
+	result = tables[0].columns
+	rs, err = r.conn.Query(r.queries.identities, t.schemaName, t.tableName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 identity metadata for %s.%s", t.schemaName, t.tableName)
+		return
+	}
+	defer rs.Close()
+	identities, err := collectIdentities(rs)
+	if err != nil {
+		err = errors.Wrapf(err, "could not read H2 identity metadata for %s.%s", t.schemaName, t.tableName)
+		return
+	}
+	for _, c := range result {
+		c.identity = identities[c.columnName]
+		/*  The H2 2.x query gives -1 for no scale. */
+		if c.scaleReported = c.numScale >= 0; !c.scaleReported {
+			c.numScale = 0
+		}
+	}
+	return
+}
+
+func (r *h2Reader) Constraints(t *table) (result []*constraint, err error) {
+	rs, err := r.conn.Query(r.queries.constraints, t.schemaName, t.tableName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 constraint metadata for %s.%s ", t.schemaName, t.tableName)
+		return
+	}
+	defer rs.Close()
+	result, err = collectConstraints(rs)
+	if err != nil {
+		err = errors.Wrapf(err, "could not read H2 constraint metadata for %s.%s ", t.schemaName, t.tableName)
+		return
+	}
+	refs, err := r.references(t)
+	if err != nil {
+		return
+	}
+	for _, c := range result {
+		c.schemaName, c.tableName = t.schemaName, t.tableName
+		if c.typeName == "REFERENTIAL" {
+			if c.references = refs[c.consName]; c.references == nil {
+				err = errors.Errorf("could not find H2 cross references of constraint %s on %s.%s", c.consName, t.schemaName, t.tableName)
+				return
+			}
+		}
+	}
+	return
+}
+
+func (r *h2Reader) references(t *table) (result map[string]*reference, err error) {
+	rs, err := r.conn.Query(r.queries.references, t.schemaName, t.tableName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 cross reference metadata for %s.%s ", t.schemaName, t.tableName)
+		return
+	}
+	defer rs.Close()
+	result, err = collectReferences(rs)
+	if err != nil {
+		err = errors.Wrapf(err, "could not read H2 cross reference metadata for %s.%s ", t.schemaName, t.tableName)
+	}
+	return
+}
+
+func (r *h2Reader) Indexes(t *table) (result []*index, err error) {
+	rs, err := r.conn.Query(r.queries.indexes, t.schemaName, t.tableName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 index metadata for %s.%s ", t.schemaName, t.tableName)
+		return
+	}
+	defer rs.Close()
+	result, err = collectIndexes(rs)
+	if err != nil {
+		err = errors.Wrapf(err, "could not read H2 index metadata for %s.%s ", t.schemaName, t.tableName)
+	}
+	return
+}
+
+func (r *h2Reader) Sequences(schemaName string) (result []*sequence, err error) {
+	rs, err := r.conn.Query(r.queries.sequences, schemaName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 sequence metadata from schema %s", schemaName)
+		return
+	}
+	defer rs.Close()
+	if result, err = collectSequences(rs); err != nil {
+		err = errors.Wrapf(err, "could not read H2 sequence metadata from schema %s", schemaName)
+	}
+	return
+}
+
+func (r *h2Reader) Views(schemaName string) (result []*view, err error) {
+	rs, err := r.conn.Query(r.queries.views, schemaName)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open H2 view metadata from schema %s", schemaName)
+		return
+	}
+	defer rs.Close()
+	if result, err = collectViews(rs); err != nil {
+		err = errors.Wrapf(err, "could not read H2 view metadata from schema %s", schemaName)
+	}
+	return
+}
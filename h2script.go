@@ -0,0 +1,990 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// scriptCatalog is the CatalogReader of an H2 SCRIPT dump: the metadata is
+// parsed from the DDL of the dump (CREATE SCHEMA, TABLE, INDEX, SEQUENCE and
+// VIEW, ALTER TABLE ADD CONSTRAINT) instead of read from INFORMATION_SCHEMA.
+// Other statements, INSERTs included, are skipped.
+//
+// The model is filled as the live readers fill it, with the H2 1.4 values:
+// unquoted names in upper case, REFERENTIAL foreign keys, A/D index column
+// ordering. A view definition is its query, as H2 2.x reports it.
+type scriptCatalog struct {
+	schemas   map[string]bool
+	tables    map[string]*table
+	sequences []*sequence
+	views     []*view
+}
+
+func tableKey(schemaName, tableName string) string {
+	return schemaName + "." + tableName
+}
+
+// parseH2Script parses the DDL of an H2 SCRIPT dump.
+func parseH2Script(r io.Reader) (result *scriptCatalog, err error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		err = errors.Wrap(err, "could not read H2 script")
+		return
+	}
+	p := &scriptParser{
+		src: string(src),
+		cat: &scriptCatalog{
+			schemas: map[string]bool{"PUBLIC": true},
+			tables:  make(map[string]*table),
+		},
+		identitySequences: make(map[*column]string),
+	}
+	if err = p.parse(); err != nil {
+		return
+	}
+	if err = p.linkIdentities(); err != nil {
+		return
+	}
+	result = p.cat
+	return
+}
+
+// linkIdentities takes the options of the H2 1.4 identities from their
+// generated sequences, which the script may create before or after their
+// table.
+func (p *scriptParser) linkIdentities() (err error) {
+	sequences := make(map[string]*sequence)
+	for _, s := range p.cat.sequences {
+		sequences[tableKey(s.schemaName, s.seqName)] = s
+	}
+	for c, key := range p.identitySequences {
+		s := sequences[key]
+		if s == nil {
+			err = errors.Errorf("sequence %s of column %s.%s.%s is not created in the H2 script",
+				key, c.schemaName, c.tableName, c.columnName)
+			return
+		}
+		s.generated = true
+		c.identity = &identity{sequence: *s}
+	}
+	return
+}
+
+func (c *scriptCatalog) Schemas() (result []string, err error) {
+	for name := range c.schemas {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return
+}
+
+func (c *scriptCatalog) Tables(schemaName string) (result []*table, err error) {
+	for _, t := range c.tables {
+		if t.schemaName == schemaName {
+			result = append(result, &table{schemaName: t.schemaName, tableName: t.tableName})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].tableName < result[j].tableName })
+	return
+}
+
+func (c *scriptCatalog) table(t *table) (result *table, err error) {
+	if result = c.tables[tableKey(t.schemaName, t.tableName)]; result == nil {
+		err = errors.Errorf("table %s.%s is not in the H2 script", t.schemaName, t.tableName)
+	}
+	return
+}
+
+func (c *scriptCatalog) Columns(t *table) (result []*column, err error) {
+	st, err := c.table(t)
+	if err == nil {
+		result = st.columns
+	}
+	return
+}
+
+func (c *scriptCatalog) Constraints(t *table) (result []*constraint, err error) {
+	st, err := c.table(t)
+	if err == nil {
+		result = st.cons
+	}
+	return
+}
+
+func (c *scriptCatalog) Indexes(t *table) (result []*index, err error) {
+	st, err := c.table(t)
+	if err == nil {
+		result = append(result, st.indexes...)
+		sort.SliceStable(result, func(i, j int) bool { return result[i].indexName < result[j].indexName })
+	}
+	return
+}
+
+func (c *scriptCatalog) Sequences(schemaName string) (result []*sequence, err error) {
+	for _, s := range c.sequences {
+		if s.schemaName == schemaName {
+			result = append(result, s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].seqName < result[j].seqName })
+	return
+}
+
+func (c *scriptCatalog) Views(schemaName string) (result []*view, err error) {
+	for _, v := range c.views {
+		if v.schemaName == schemaName {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].viewName < result[j].viewName })
+	return
+}
+
+type scriptTokenKind int
+
+const (
+	wordToken   scriptTokenKind = iota // Unquoted identifier or keyword
+	quotedToken                        // "Quoted identifier"
+	stringToken                        // 'String', $$String$$
+	numberToken
+	symbolToken
+)
+
+type scriptToken struct {
+	kind  scriptTokenKind
+	text  string //  Unquoted, unescaped text; words in upper case
+	start int    //  Offsets of the token in the script
+	end   int
+}
+
+// scriptSyntaxError is raised by the parser with panic, and recovered as an
+// error at the statement it was raised in.
+type scriptSyntaxError struct {
+	msg string
+}
+
+type scriptParser struct {
+	src  string
+	toks []scriptToken //  Tokens of the current statement
+	pos  int
+	cat  *scriptCatalog
+
+	identitySequences map[*column]string //  Keys of the sequences of H2 1.4 identities
+}
+
+func (p *scriptParser) parse() (err error) {
+	var toks []scriptToken
+	for off := 0; ; {
+		var tok scriptToken
+		var ok bool
+		if tok, off, ok, err = p.lex(off); err != nil {
+			return
+		}
+		if !ok || (tok.kind == symbolToken && tok.text == ";") {
+			if len(toks) != 0 {
+				if err = p.statement(toks); err != nil {
+					return
+				}
+			}
+			if !ok {
+				return
+			}
+			toks = nil
+			continue
+		}
+		toks = append(toks, tok)
+	}
+}
+
+// lineOf returns the line number of an offset in the script.
+func (p *scriptParser) lineOf(off int) int {
+	return strings.Count(p.src[:off], "\n") + 1
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || c == '$' || (c >= '0' && c <= '9')
+}
+
+// lex returns the token at or after off, and the offset after it; ok is
+// false at the end of the script.
+func (p *scriptParser) lex(off int) (tok scriptToken, next int, ok bool, err error) {
+	src := p.src
+	for off < len(src) {
+		switch {
+		case src[off] == ' ' || src[off] == '\t' || src[off] == '\r' || src[off] == '\n':
+			off++
+		case strings.HasPrefix(src[off:], "--") || strings.HasPrefix(src[off:], "//"):
+			if k := strings.IndexByte(src[off:], '\n'); k >= 0 {
+				off += k + 1
+			} else {
+				off = len(src)
+			}
+		case strings.HasPrefix(src[off:], "/*"):
+			k := strings.Index(src[off+2:], "*/")
+			if k < 0 {
+				err = errors.Errorf("H2 script line %d: unterminated comment", p.lineOf(off))
+				return
+			}
+			off += k + 4
+		default:
+			tok, next, err = p.lexToken(off)
+			ok = err == nil
+			return
+		}
+	}
+	next = off
+	return
+}
+
+func (p *scriptParser) lexToken(off int) (tok scriptToken, next int, err error) {
+	src := p.src
+	tok.start = off
+	c := src[off]
+	switch {
+	case c == '"' || c == '\'':
+		tok.kind = quotedToken
+		if c == '\'' {
+			tok.kind = stringToken
+		}
+		var sb strings.Builder
+		for next = off + 1; ; next++ {
+			if next >= len(src) {
+				err = errors.Errorf("H2 script line %d: unterminated %c", p.lineOf(off), c)
+				return
+			}
+			if src[next] == c {
+				if next+1 < len(src) && src[next+1] == c {
+					next++ //  Doubled quote
+				} else {
+					next++
+					break
+				}
+			}
+			sb.WriteByte(src[next])
+		}
+		tok.text = sb.String()
+	case strings.HasPrefix(src[off:], "$$"):
+		k := strings.Index(src[off+2:], "$$")
+		if k < 0 {
+			err = errors.Errorf("H2 script line %d: unterminated $$", p.lineOf(off))
+			return
+		}
+		tok.kind, tok.text = stringToken, src[off+2:off+2+k]
+		next = off + k + 4
+	case c >= '0' && c <= '9' || (c == '.' && off+1 < len(src) && src[off+1] >= '0' && src[off+1] <= '9'):
+		tok.kind = numberToken
+		for next = off; next < len(src) && (isWordPart(src[next]) || src[next] == '.'); next++ {
+		}
+		tok.text = src[off:next]
+	case isWordStart(c):
+		tok.kind = wordToken
+		for next = off; next < len(src) && isWordPart(src[next]); next++ {
+		}
+		tok.text = strings.ToUpper(src[off:next])
+	default:
+		tok.kind, tok.text = symbolToken, string(c)
+		next = off + 1
+	}
+	tok.end = next
+	return
+}
+
+func (p *scriptParser) fail(format string, args ...interface{}) {
+	panic(&scriptSyntaxError{fmt.Sprintf(format, args...)})
+}
+
+// statement parses the DDL statements of the model, and skips the others.
+func (p *scriptParser) statement(toks []scriptToken) (err error) {
+	p.toks, p.pos = toks, 0
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(*scriptSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = errors.Errorf("H2 script line %d: %s", p.lineOf(toks[0].start), se.msg)
+		}
+	}()
+	switch {
+	case p.accept("CREATE"):
+		p.accept("OR", "REPLACE")
+		switch {
+		case p.accept("SCHEMA"):
+			p.accept("IF", "NOT", "EXISTS")
+			p.cat.schemas[p.name()] = true
+		case p.is("CACHED") || p.is("MEMORY") || p.is("TABLE"):
+			p.accept("CACHED")
+			p.accept("MEMORY")
+			p.expect("TABLE")
+			p.createTable()
+		case p.is("UNIQUE") || p.is("HASH") || p.is("SPATIAL") || p.is("INDEX"):
+			p.createIndex()
+		case p.accept("SEQUENCE"):
+			p.createSequence()
+		case p.is("FORCE") || p.is("VIEW"):
+			p.accept("FORCE")
+			p.expect("VIEW")
+			p.createView()
+		}
+	case p.accept("ALTER", "TABLE"):
+		p.accept("IF", "EXISTS")
+		t := p.tableNamed(p.qualifiedName())
+		if p.accept("ADD") {
+			if c := p.tableConstraint(t); c != nil {
+				t.cons = append(t.cons, c)
+			}
+		}
+	}
+	return
+}
+
+func (p *scriptParser) peek() *scriptToken {
+	if p.pos < len(p.toks) {
+		return &p.toks[p.pos]
+	}
+	return nil
+}
+
+// is reports whether the next tokens are the given keywords.
+func (p *scriptParser) is(words ...string) bool {
+	for k, w := range words {
+		if p.pos+k >= len(p.toks) {
+			return false
+		}
+		if t := p.toks[p.pos+k]; t.kind != wordToken || t.text != w {
+			return false
+		}
+	}
+	return true
+}
+
+// accept consumes the given keywords if they are next.
+func (p *scriptParser) accept(words ...string) bool {
+	if !p.is(words...) {
+		return false
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *scriptParser) expect(words ...string) {
+	if !p.accept(words...) {
+		p.fail("%s expected, found %s", strings.Join(words, " "), p.describe())
+	}
+}
+
+func (p *scriptParser) isSymbol(s string) bool {
+	t := p.peek()
+	return t != nil && t.kind == symbolToken && t.text == s
+}
+
+func (p *scriptParser) acceptSymbol(s string) bool {
+	if !p.isSymbol(s) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *scriptParser) expectSymbol(s string) {
+	if !p.acceptSymbol(s) {
+		p.fail("%s expected, found %s", s, p.describe())
+	}
+}
+
+func (p *scriptParser) describe() string {
+	if t := p.peek(); t != nil {
+		return strconv.Quote(p.src[t.start:t.end])
+	}
+	return "end of statement"
+}
+
+func (p *scriptParser) name() string {
+	t := p.peek()
+	if t == nil || (t.kind != wordToken && t.kind != quotedToken) {
+		p.fail("name expected, found %s", p.describe())
+	}
+	p.pos++
+	return t.text
+}
+
+// qualifiedName returns the schema (PUBLIC if not given) and the name of a
+// [catalog.][schema.]name.
+func (p *scriptParser) qualifiedName() (schemaName, name string) {
+	schemaName, name = "PUBLIC", p.name()
+	for p.acceptSymbol(".") {
+		schemaName, name = name, p.name()
+	}
+	return
+}
+
+func (p *scriptParser) number() int64 {
+	negative := p.acceptSymbol("-")
+	t := p.peek()
+	if t == nil || t.kind != numberToken {
+		p.fail("number expected, found %s", p.describe())
+	}
+	p.pos++
+	v, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil {
+		p.fail("integer expected, found %s", strconv.Quote(t.text))
+	}
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+// skipGroup skips a parenthesized or bracketed group, returning the script
+// text inside it.
+func (p *scriptParser) skipGroup() string {
+	open := p.peek()
+	depth := 0
+	for t := p.peek(); t != nil; t = p.peek() {
+		p.pos++
+		if t.kind == symbolToken {
+			switch t.text {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				if depth--; depth == 0 {
+					return p.src[open.end:t.start]
+				}
+			}
+		}
+	}
+	p.fail("unbalanced parentheses")
+	return ""
+}
+
+// expression skips an expression up to a comma or closing parenthesis at
+// its level, or a keyword in stop, returning its script text.
+func (p *scriptParser) expression(stop map[string]bool) string {
+	first := p.peek()
+	var last *scriptToken
+	for t := p.peek(); t != nil; t = p.peek() {
+		if last != nil && t.kind == wordToken && stop[t.text] {
+			break
+		}
+		if t.kind == symbolToken && (t.text == "," || t.text == ")") {
+			break
+		}
+		if t.kind == symbolToken && (t.text == "(" || t.text == "[") {
+			p.skipGroup()
+		} else {
+			p.pos++
+		}
+		last = &p.toks[p.pos-1]
+	}
+	if last == nil {
+		p.fail("expression expected, found %s", p.describe())
+	}
+	return p.src[first.start:last.end]
+}
+
+// nameList parses a parenthesized list of column names, skipping ordering.
+func (p *scriptParser) nameList() (result []string) {
+	p.expectSymbol("(")
+	for {
+		result = append(result, p.name())
+		p.accept("ASC")
+		p.accept("DESC")
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	p.expectSymbol(")")
+	return
+}
+
+func (p *scriptParser) tableNamed(schemaName, tableName string) *table {
+	t := p.cat.tables[tableKey(schemaName, tableName)]
+	if t == nil {
+		p.fail("table %s.%s is not created in the script", schemaName, tableName)
+	}
+	return t
+}
+
+// The keywords ending the type or the default of a column definition.
+var columnClauses = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "UNIQUE": true,
+	"CHECK": true, "REFERENCES": true, "CONSTRAINT": true, "SELECTIVITY": true,
+	"SEQUENCE": true, "NULL_TO_DEFAULT": true, "AUTO_INCREMENT": true,
+	"IDENTITY": true, "GENERATED": true, "COMMENT": true, "ON": true,
+	"INVISIBLE": true, "VISIBLE": true, "AS": true,
+}
+
+func (p *scriptParser) createTable() {
+	p.accept("IF", "NOT", "EXISTS")
+	schemaName, tableName := p.qualifiedName()
+	key := tableKey(schemaName, tableName)
+	if p.cat.tables[key] != nil {
+		p.fail("table %s is created twice", key)
+	}
+	t := &table{schemaName: schemaName, tableName: tableName}
+	p.expectSymbol("(")
+	for !p.acceptSymbol(")") {
+		if p.is("CONSTRAINT") || p.is("PRIMARY") || p.is("UNIQUE") || p.is("FOREIGN") || p.is("CHECK") {
+			if c := p.tableConstraint(t); c != nil {
+				t.cons = append(t.cons, c)
+			}
+		} else {
+			p.columnDefinition(t)
+		}
+		if !p.acceptSymbol(",") && !p.isSymbol(")") {
+			p.fail(", or ) expected, found %s", p.describe())
+		}
+	}
+	p.cat.tables[key] = t
+}
+
+func (p *scriptParser) columnDefinition(t *table) {
+	c := &column{
+		schemaName: t.schemaName,
+		tableName:  t.tableName,
+		columnName: p.name(),
+		position:   len(t.columns) + 1,
+		nullable:   1,
+	}
+	p.columnType(c, columnClauses)
+	for t0 := p.peek(); t0 != nil && !p.isSymbol(",") && !p.isSymbol(")"); t0 = p.peek() {
+		consName := ""
+		if p.accept("CONSTRAINT") {
+			_, consName = p.qualifiedName()
+		}
+		switch {
+		case p.accept("NOT", "NULL"):
+			c.nullable = 0
+		case p.accept("NULL"), p.accept("NULL_TO_DEFAULT"), p.accept("VISIBLE"), p.accept("INVISIBLE"):
+		case p.accept("DEFAULT"):
+			p.accept("ON", "NULL")
+			c.defValue = p.expression(columnClauses)
+		case p.accept("ON", "UPDATE"), p.accept("AS"):
+			p.expression(columnClauses)
+		case p.accept("SELECTIVITY"):
+			p.number()
+		case p.accept("SEQUENCE"):
+			/*  The generated sequence of an H2 1.4 identity, created
+			BELONGS_TO_TABLE. */
+			schemaName, seqName := p.qualifiedName()
+			p.identitySequences[c] = tableKey(schemaName, seqName)
+		case p.accept("COMMENT"):
+			p.pos++
+		case p.accept("AUTO_INCREMENT"), p.accept("IDENTITY"):
+			c.identity = &identity{sequence: *newScriptSequence()}
+			if p.acceptSymbol("(") {
+				c.identity.nextValue = p.number()
+				if p.acceptSymbol(",") {
+					c.identity.increment = p.number()
+				}
+				p.expectSymbol(")")
+			}
+		case p.accept("GENERATED"):
+			always := p.accept("ALWAYS")
+			if !always {
+				p.expect("BY", "DEFAULT")
+			}
+			p.expect("AS")
+			if !p.accept("IDENTITY") {
+				p.skipGroup() //  Computed column
+				break
+			}
+			c.identity = &identity{sequence: *newScriptSequence(), always: always}
+			if p.acceptSymbol("(") {
+				p.sequenceOptions(&c.identity.sequence)
+				p.expectSymbol(")")
+			}
+		case p.accept("PRIMARY", "KEY"):
+			p.accept("HASH")
+			t.cons = append(t.cons, &constraint{
+				schemaName: t.schemaName,
+				tableName:  t.tableName,
+				consName:   nameOr(consName, t.tableName+"_PKEY"),
+				typeName:   "PRIMARY KEY",
+				columList:  c.columnName,
+			})
+		case p.accept("UNIQUE"):
+			t.cons = append(t.cons, &constraint{
+				schemaName: t.schemaName,
+				tableName:  t.tableName,
+				consName:   nameOr(consName, t.tableName+"_"+c.columnName+"_KEY"),
+				typeName:   "UNIQUE",
+				columList:  c.columnName,
+			})
+		case p.accept("CHECK"):
+			t.cons = append(t.cons, &constraint{
+				schemaName: t.schemaName,
+				tableName:  t.tableName,
+				consName:   nameOr(consName, t.tableName+"_"+c.columnName+"_CHECK"),
+				typeName:   "CHECK",
+				checkExpr:  p.skipGroup(),
+			})
+		case p.is("REFERENCES"):
+			fk := &constraint{
+				schemaName: t.schemaName,
+				tableName:  t.tableName,
+				consName:   nameOr(consName, t.tableName+"_"+c.columnName+"_FKEY"),
+				typeName:   "REFERENTIAL",
+				columList:  c.columnName,
+			}
+			p.references(fk, []string{c.columnName})
+			t.cons = append(t.cons, fk)
+		default:
+			p.fail("unexpected %s in the definition of column %s", p.describe(), c.columnName)
+		}
+	}
+	t.columns = append(t.columns, c)
+}
+
+func nameOr(name, generated string) string {
+	if name != "" {
+		return name
+	}
+	return generated
+}
+
+// h2TypeAliases maps the alternative type names H2 accepts in a script onto
+// the names it reports in INFORMATION_SCHEMA, which h2TypeRules maps.
+var h2TypeAliases = map[string]string{
+	"BOOL":          "BOOLEAN",
+	"INT2":          "SMALLINT",
+	"INT4":          "INTEGER",
+	"MEDIUMINT":     "INTEGER",
+	"SIGNED":        "INTEGER",
+	"INT8":          "BIGINT",
+	"NUMBER":        "DECIMAL",
+	"DEC":           "DECIMAL",
+	"FLOAT4":        "REAL",
+	"FLOAT8":        "DOUBLE",
+	"NCHAR":         "CHAR",
+	"VARCHAR2":      "VARCHAR",
+	"NVARCHAR":      "VARCHAR",
+	"NVARCHAR2":     "VARCHAR",
+	"LONGVARCHAR":   "VARCHAR",
+	"TEXT":          "CLOB",
+	"TINYTEXT":      "CLOB",
+	"MEDIUMTEXT":    "CLOB",
+	"LONGTEXT":      "CLOB",
+	"NCLOB":         "CLOB",
+	"BYTEA":         "VARBINARY",
+	"RAW":           "VARBINARY",
+	"LONGVARBINARY": "VARBINARY",
+	"IMAGE":         "BLOB",
+	"DATETIME":      "TIMESTAMP",
+	"SMALLDATETIME": "TIMESTAMP",
+}
+
+// columnType parses the data type of a column, up to a keyword in stop, into
+// typeName, and its parameters into charLength, numPrec and numScale as H2
+// reports them.
+func (p *scriptParser) columnType(c *column, stop map[string]bool) {
+	var words []string
+	var params []int64
+	for t := p.peek(); t != nil; t = p.peek() {
+		if t.kind == wordToken && (len(words) == 0 || !stop[t.text]) {
+			words = append(words, t.text)
+			p.pos++
+			continue
+		}
+		if p.isSymbol("(") && params == nil {
+			params = []int64{}
+			for _, param := range strings.Split(p.skipGroup(), ",") {
+				if v, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64); err == nil {
+					params = append(params, v)
+				}
+			}
+			continue
+		}
+		if p.isSymbol("[") {
+			p.skipGroup() //  Maximum cardinality of an array
+			continue
+		}
+		break
+	}
+	if len(words) == 0 {
+		p.fail("data type of column %s expected, found %s", c.columnName, p.describe())
+	}
+	c.typeName = strings.Join(words, " ")
+	if name, ok := h2TypeAliases[c.typeName]; ok {
+		c.typeName = name
+	}
+	switch c.typeName {
+	case "IDENTITY", "BIGSERIAL":
+		c.typeName = "BIGINT"
+		c.identity = &identity{sequence: *newScriptSequence()}
+	case "SERIAL":
+		c.typeName = "INTEGER"
+		c.identity = &identity{sequence: *newScriptSequence()}
+	}
+	param := func(k int, def int) int {
+		if k < len(params) && params[k] <= math.MaxInt32 {
+			return int(params[k])
+		}
+		return def
+	}
+	/*  H2 reports the default precision of a time type declared without
+	one. */
+	switch {
+	case strings.HasPrefix(c.typeName, "TIMESTAMP"):
+		c.numScale, c.scaleReported = param(0, 6), true
+	case strings.HasPrefix(c.typeName, "TIME"):
+		c.numScale, c.scaleReported = param(0, 0), true
+	case c.typeName == "DECIMAL" || c.typeName == "NUMERIC":
+		c.numPrec, c.numScale = param(0, 0), param(1, 0)
+	default:
+		c.charLength = param(0, 0)
+	}
+}
+
+// tableConstraint parses the [CONSTRAINT name] constraint of ALTER TABLE ADD
+// or CREATE TABLE. It returns nil for an ALTER TABLE ADD of anything else.
+func (p *scriptParser) tableConstraint(t *table) *constraint {
+	c := &constraint{
+		schemaName: t.schemaName,
+		tableName:  t.tableName,
+	}
+	if p.accept("CONSTRAINT") {
+		p.accept("IF", "NOT", "EXISTS")
+		_, c.consName = p.qualifiedName()
+	}
+	switch {
+	case p.accept("PRIMARY", "KEY"):
+		p.accept("HASH")
+		c.typeName = "PRIMARY KEY"
+		c.columList = strings.Join(p.nameList(), ",")
+		c.consName = nameOr(c.consName, t.tableName+"_PKEY")
+	case p.accept("UNIQUE"):
+		p.accept("KEY")
+		c.typeName = "UNIQUE"
+		columns := p.nameList()
+		c.columList = strings.Join(columns, ",")
+		c.consName = nameOr(c.consName, t.tableName+"_"+strings.Join(columns, "_")+"_KEY")
+	case p.accept("FOREIGN", "KEY"):
+		columns := p.nameList()
+		c.typeName = "REFERENTIAL"
+		c.columList = strings.Join(columns, ",")
+		c.consName = nameOr(c.consName, t.tableName+"_"+strings.Join(columns, "_")+"_FKEY")
+		p.references(c, columns)
+	case p.accept("CHECK"):
+		c.typeName = "CHECK"
+		c.checkExpr = p.skipGroup()
+		c.consName = nameOr(c.consName, t.tableName+"_CHECK")
+	default:
+		if c.consName != "" {
+			p.fail("unknown constraint %s", p.describe())
+		}
+		return nil //  ALTER TABLE ADD COLUMN, ...
+	}
+	for p.peek() != nil && !p.isSymbol(",") && !p.isSymbol(")") {
+		switch {
+		case p.accept("INDEX"):
+			_, c.indexName = p.qualifiedName()
+		case p.accept("NOCHECK"), p.accept("CHECK"):
+		default:
+			p.fail("unexpected %s after constraint %s", p.describe(), c.consName)
+		}
+	}
+	return c
+}
+
+// references parses the REFERENCES clause of a foreign key. Without column
+// names, the key refers to the primary key of the table, which must have
+// been added to it already.
+func (p *scriptParser) references(c *constraint, columns []string) {
+	p.expect("REFERENCES")
+	r := &reference{
+		consName:   c.consName,
+		columns:    columns,
+		updateRule: h2RuleRestrict,
+		deleteRule: h2RuleRestrict,
+	}
+	r.schemaName, r.tableName = p.qualifiedName()
+	if p.isSymbol("(") {
+		r.refColumns = p.nameList()
+	} else {
+		key := tableKey(r.schemaName, r.tableName)
+		var pk *constraint
+		if t := p.cat.tables[key]; t != nil {
+			for _, tc := range t.cons {
+				if tc.typeName == "PRIMARY KEY" {
+					pk = tc
+				}
+			}
+		}
+		if pk == nil {
+			p.fail("foreign key %s refers to %s, which has no primary key yet", c.consName, key)
+		}
+		r.refColumns = strings.Split(pk.columList, ",")
+	}
+	if len(r.refColumns) != len(r.columns) {
+		p.fail("foreign key %s has %d columns referring to %d", c.consName, len(r.columns), len(r.refColumns))
+	}
+	for p.accept("ON") {
+		rule := &r.deleteRule
+		if p.accept("UPDATE") {
+			rule = &r.updateRule
+		} else {
+			p.expect("DELETE")
+		}
+		switch {
+		case p.accept("CASCADE"):
+			*rule = h2RuleCascade
+		case p.accept("RESTRICT"):
+			*rule = h2RuleRestrict
+		case p.accept("NO", "ACTION"):
+			*rule = h2RuleNoAction
+		case p.accept("SET", "NULL"):
+			*rule = h2RuleSetNull
+		case p.accept("SET", "DEFAULT"):
+			*rule = h2RuleSetDefault
+		default:
+			p.fail("referential action expected, found %s", p.describe())
+		}
+	}
+	c.references = r
+}
+
+func (p *scriptParser) createIndex() {
+	typeName := "INDEX"
+	switch {
+	case p.accept("UNIQUE"):
+		typeName = "UNIQUE INDEX"
+	case p.accept("PRIMARY", "KEY"):
+		typeName = "PRIMARY KEY"
+	}
+	p.accept("HASH")
+	p.accept("SPATIAL")
+	p.expect("INDEX")
+	p.accept("IF", "NOT", "EXISTS")
+	_, indexName := p.qualifiedName()
+	p.expect("ON")
+	t := p.tableNamed(p.qualifiedName())
+	i := &index{
+		schemaName: t.schemaName,
+		tableName:  t.tableName,
+		indexName:  indexName,
+		typeName:   typeName,
+		nonUnique:  typeName == "INDEX",
+	}
+	p.expectSymbol("(")
+	for {
+		ic := &indexColumn{
+			columnName: p.name(),
+			position:   len(i.columns) + 1,
+			asc:        "A",
+		}
+		if p.accept("DESC") {
+			ic.asc = "D"
+		} else {
+			p.accept("ASC")
+		}
+		_ = p.accept("NULLS", "FIRST") || p.accept("NULLS", "LAST")
+		i.columns = append(i.columns, ic)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	p.expectSymbol(")")
+	t.indexes = append(t.indexes, i)
+}
+
+func (p *scriptParser) createSequence() {
+	p.accept("IF", "NOT", "EXISTS")
+	s := newScriptSequence()
+	s.schemaName, s.seqName = p.qualifiedName()
+	p.sequenceOptions(s)
+	p.cat.sequences = append(p.cat.sequences, s)
+}
+
+// newScriptSequence returns a sequence of the H2 default options.
+func newScriptSequence() *sequence {
+	return &sequence{
+		nextValue: 1,
+		increment: 1,
+		minValue:  1,
+		maxValue:  math.MaxInt64,
+		cacheSize: 32,
+	}
+}
+
+// The keywords ending the data type of a sequence.
+var sequenceClauses = map[string]bool{
+	"START": true, "RESTART": true, "INCREMENT": true, "MINVALUE": true, "MAXVALUE": true,
+	"NO": true, "NOMINVALUE": true, "NOMAXVALUE": true, "CYCLE": true, "NOCYCLE": true,
+	"CACHE": true, "NOCACHE": true, "BELONGS_TO_TABLE": true,
+}
+
+// sequenceOptions parses the options of a sequence, or of an identity up to
+// the closing parenthesis.
+func (p *scriptParser) sequenceOptions(s *sequence) {
+	var minGiven, maxGiven, startGiven bool
+	for p.peek() != nil && !p.isSymbol(")") {
+		switch {
+		case p.accept("AS"):
+			p.columnType(&column{columnName: s.seqName}, sequenceClauses)
+		case p.accept("START", "WITH"):
+			s.nextValue, startGiven = p.number(), true
+		case p.accept("RESTART", "WITH"):
+			s.nextValue, startGiven = p.number(), true
+		case p.accept("INCREMENT", "BY"):
+			s.increment = p.number()
+		case p.accept("MINVALUE"):
+			s.minValue, minGiven = p.number(), true
+		case p.accept("MAXVALUE"):
+			s.maxValue, maxGiven = p.number(), true
+		case p.accept("CYCLE"):
+			s.cycle = true
+		case p.accept("NO", "CYCLE"), p.accept("NOCYCLE"), p.accept("NO", "MINVALUE"), p.accept("NOMINVALUE"),
+			p.accept("NO", "MAXVALUE"), p.accept("NOMAXVALUE"):
+		case p.accept("CACHE"):
+			s.cacheSize = p.number()
+		case p.accept("NO", "CACHE"), p.accept("NOCACHE"):
+			s.cacheSize = 1
+		case p.accept("BELONGS_TO_TABLE"):
+			s.generated = true
+		default:
+			p.fail("unexpected %s in the options of sequence %s", p.describe(), s.seqName)
+		}
+	}
+	/*  A descending sequence defaults to the negative values, starting
+	from its maximum. */
+	if s.increment < 0 {
+		if !minGiven {
+			s.minValue = math.MinInt64
+		}
+		if !maxGiven {
+			s.maxValue = -1
+		}
+		if !startGiven {
+			s.nextValue = s.maxValue
+		}
+	}
+}
+
+func (p *scriptParser) createView() {
+	p.accept("IF", "NOT", "EXISTS")
+	v := new(view)
+	v.schemaName, v.viewName = p.qualifiedName()
+	if p.isSymbol("(") {
+		p.skipGroup()
+	}
+	p.expect("AS")
+	t := p.peek()
+	if t == nil {
+		p.fail("query of view %s expected", v.viewName)
+	}
+	v.definition = p.src[t.start:p.toks[len(p.toks)-1].end]
+	p.pos = len(p.toks)
+	p.cat.views = append(p.cat.views, v)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const testH2Script = `;
+CREATE USER IF NOT EXISTS "SA" SALT 'a1' HASH 'b2' ADMIN;
+CREATE SEQUENCE "PUBLIC"."SEQ_ORDERS" START WITH 101 INCREMENT BY 1 CACHE 10;
+CREATE SCHEMA IF NOT EXISTS "APP" AUTHORIZATION "SA";
+CREATE CACHED TABLE "PUBLIC"."ORDERS"(
+    "ID" BIGINT DEFAULT (NEXT VALUE FOR "PUBLIC"."SEQ_ORDERS") NOT NULL,
+    "CODE" VARCHAR(20) NOT NULL,
+    "AMOUNT" DECIMAL(18, 2) DEFAULT 0 SELECTIVITY 90,
+    "CREATED" TIMESTAMP DEFAULT CURRENT_TIMESTAMP()
+);
+ALTER TABLE "PUBLIC"."ORDERS" ADD CONSTRAINT "PUBLIC"."PK_ORDERS" PRIMARY KEY("ID");
+-- 1 +/- SELECT COUNT(*) FROM PUBLIC.ORDERS;
+INSERT INTO "PUBLIC"."ORDERS" VALUES(100, 'A;1', 1.50, TIMESTAMP '2020-01-01 00:00:00');
+CREATE MEMORY TABLE "PUBLIC"."LINES"(
+    "ORDER_ID" BIGINT NOT NULL,
+    "QTY" INT
+);
+CREATE INDEX "PUBLIC"."IX_LINES" ON "PUBLIC"."LINES"("ORDER_ID" DESC, "QTY");
+ALTER TABLE "PUBLIC"."LINES" ADD CONSTRAINT "PUBLIC"."FK_LINES" FOREIGN KEY("ORDER_ID") REFERENCES "PUBLIC"."ORDERS"("ID") ON DELETE CASCADE NOCHECK;
+ALTER TABLE "PUBLIC"."LINES" ADD CONSTRAINT "PUBLIC"."CK_QTY" CHECK("QTY" > 0) NOCHECK;
+CREATE FORCE VIEW "PUBLIC"."V_ORDERS"("ID") AS
+SELECT "ID" FROM "PUBLIC"."ORDERS" WHERE "CODE" <> 'X';
+`
+
+func TestParseH2Script(t *testing.T) {
+	cat, err := parseH2Script(strings.NewReader(testH2Script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas, err := cat.Schemas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schemas, []string{"APP", "PUBLIC"}) {
+		t.Error(errors.Errorf("schemas: %v", schemas))
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.tables) != 2 || s.tables[0].tableName != "LINES" || s.tables[1].tableName != "ORDERS" {
+		t.Fatal(errors.New("tables of the script are wrong"))
+	}
+	lines, orders := s.tables[0], s.tables[1]
+	want := []*column{
+		{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "ID", typeName: "BIGINT", position: 1,
+			defValue: `(NEXT VALUE FOR "PUBLIC"."SEQ_ORDERS")`},
+		{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "CODE", typeName: "VARCHAR", position: 2,
+			charLength: 20},
+		{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "AMOUNT", typeName: "DECIMAL", position: 3,
+			nullable: 1, defValue: "0", numPrec: 18, numScale: 2},
+		{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "CREATED", typeName: "TIMESTAMP", position: 4,
+			nullable: 1, defValue: "CURRENT_TIMESTAMP()", numScale: 6, scaleReported: true},
+	}
+	if !reflect.DeepEqual(orders.columns, want) {
+		t.Error(errors.New("columns of ORDERS are wrong"))
+	}
+	ddl, err := orders.createDDL(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ddl != `create table if not exists "PUBLIC"."ORDERS"("ID" bigint not null default nextval('"PUBLIC"."SEQ_ORDERS"'),`+
+		`"CODE" character varying(20) not null,"AMOUNT" numeric(18,2) default 0,"CREATED" timestamp without time zone default current_timestamp);` {
+		t.Error(errors.Errorf("ORDERS DDL: %s", ddl))
+	}
+	if len(orders.cons) != 1 || orders.cons[0].consName != "PK_ORDERS" || orders.cons[0].columList != "ID" {
+		t.Error(errors.New("primary key of ORDERS is wrong"))
+	}
+	if len(lines.cons) != 2 {
+		t.Fatal(errors.New("constraints of LINES are wrong"))
+	}
+	for k, expected := range []string{
+		`alter table "PUBLIC"."LINES" add constraint "FK_LINES" foreign key ("ORDER_ID") references "PUBLIC"."ORDERS"("ID") on delete cascade on update restrict;`,
+		`alter table "PUBLIC"."LINES" add constraint "CK_QTY" check ("QTY" > 0);`,
+	} {
+		if ddl, err := lines.cons[k].createDDL(); err != nil || ddl != expected {
+			t.Error(errors.Errorf("constraint %d DDL: %s %v", k, ddl, err))
+		}
+	}
+	if len(lines.indexes) != 1 || lines.indexes[0].createDDL() != `create index if not exists "IX_LINES" on "PUBLIC"."LINES"("ORDER_ID" DESC,"QTY" ASC);` {
+		t.Error(errors.New("index of LINES is wrong"))
+	}
+	wantSeq := []*sequence{{schemaName: "PUBLIC", seqName: "SEQ_ORDERS", nextValue: 101, increment: 1,
+		minValue: 1, maxValue: math.MaxInt64, cacheSize: 10}}
+	if !reflect.DeepEqual(s.sequences, wantSeq) {
+		t.Error(errors.New("sequences of the script are wrong"))
+	}
+	wantView := []*view{{schemaName: "PUBLIC", viewName: "V_ORDERS",
+		definition: `SELECT "ID" FROM "PUBLIC"."ORDERS" WHERE "CODE" <> 'X'`}}
+	if !reflect.DeepEqual(s.views, wantView) {
+		t.Error(errors.New("views of the script are wrong"))
+	}
+}
+
+func TestParseH2ScriptInline(t *testing.T) {
+	cat, err := parseH2Script(strings.NewReader(`
+		create table parent(id identity primary key, name varchar_ignorecase(10) unique);
+		create table child(
+			id int not null,
+			parent_id bigint references parent on update set null,
+			constraint ck_child check (id <> parent_id));
+		create unique index ux_child on child(parent_id)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, parent := s.tables[0], s.tables[1]
+	if parent.columns[0].typeName != "BIGINT" || parent.columns[1].charLength != 10 {
+		t.Error(errors.New("columns of PARENT are wrong"))
+	}
+	var names []string
+	for _, c := range append(parent.cons, child.cons...) {
+		names = append(names, c.consName)
+	}
+	if !reflect.DeepEqual(names, []string{"PARENT_PKEY", "PARENT_NAME_KEY", "CHILD_PARENT_ID_FKEY", "CK_CHILD"}) {
+		t.Error(errors.Errorf("constraint names: %v", names))
+	}
+	r := child.cons[0].references
+	if r == nil || !reflect.DeepEqual(r.refColumns, []string{"ID"}) || r.updateRule != h2RuleSetNull || r.deleteRule != h2RuleRestrict {
+		t.Error(errors.New("implicit reference to the primary key is wrong"))
+	}
+	if len(child.indexes) != 1 || child.indexes[0].nonUnique || child.indexes[0].typeName != "UNIQUE INDEX" {
+		t.Error(errors.New("unique index of CHILD is wrong"))
+	}
+}
+
+func TestParseH2ScriptErrors(t *testing.T) {
+	for _, script := range []string{
+		"CREATE TABLE T(A INT;",
+		"CREATE TABLE T(A INT);\nALTER TABLE U ADD CONSTRAINT C PRIMARY KEY(A);",
+		"CREATE TABLE T(A INT);\nALTER TABLE T ADD CONSTRAINT C FOREIGN KEY(A) REFERENCES T;",
+		"CREATE TABLE T(A INT);\nCREATE TABLE T(B INT);",
+		"INSERT INTO T VALUES('unterminated);",
+	} {
+		if _, err := parseH2Script(strings.NewReader(script)); err == nil {
+			t.Error(errors.Errorf("no error parsing %q", script))
+		}
+	}
+}
+
+func TestParseH2ScriptLexing(t *testing.T) {
+	cat, err := parseH2Script(strings.NewReader(`/* A comment; not a statement */
+		CREATE TABLE "Mixed""Case"( -- a comment; to the end of the line
+			"id" INT DEFAULT $$it's; done$$, // another comment
+			lower_name VARCHAR(5) DEFAULT 'a;''b'
+		);
+		CREATE VIEW V AS SELECT 'x;y' AS S FROM "Mixed""Case"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.tables) != 1 || s.tables[0].tableName != `Mixed"Case` {
+		t.Fatal(errors.New("quoted table name is wrong"))
+	}
+	columns := s.tables[0].columns
+	if len(columns) != 2 || columns[0].columnName != "id" || columns[0].defValue != `$$it's; done$$` ||
+		columns[1].columnName != "LOWER_NAME" || columns[1].defValue != `'a;''b'` {
+		t.Error(errors.Errorf("columns: %+v %+v", *columns[0], *columns[1]))
+	}
+	if len(s.views) != 1 || s.views[0].definition != `SELECT 'x;y' AS S FROM "Mixed""Case"` {
+		t.Error(errors.Errorf("views: %v", s.views))
+	}
+}
+
+func TestParseH2ScriptSequences(t *testing.T) {
+	cat, err := parseH2Script(strings.NewReader(`
+		CREATE SEQUENCE S AS INTEGER START WITH 1 INCREMENT BY 2 MAXVALUE 100 NOCYCLE;
+		CREATE SEQUENCE "APP"."DOWN" AS NUMERIC(10) INCREMENT BY -1 CACHE 5;
+		CREATE SEQUENCE BACK START WITH 100 INCREMENT BY -1 MAXVALUE 100;
+		CREATE SEQUENCE FLOOR INCREMENT BY -1 MINVALUE -50;
+		CREATE TABLE T("ID" INT GENERATED BY DEFAULT AS IDENTITY(AS INT START WITH 5 NO CACHE))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*sequence{
+		{schemaName: "PUBLIC", seqName: "S", nextValue: 1, increment: 2, minValue: 1, maxValue: 100, cacheSize: 32},
+		{schemaName: "APP", seqName: "DOWN", nextValue: -1, increment: -1, minValue: math.MinInt64, maxValue: -1, cacheSize: 5},
+		{schemaName: "PUBLIC", seqName: "BACK", nextValue: 100, increment: -1, minValue: math.MinInt64, maxValue: 100, cacheSize: 32},
+		{schemaName: "PUBLIC", seqName: "FLOOR", nextValue: -1, increment: -1, minValue: -50, maxValue: -1, cacheSize: 32},
+	}
+	if len(cat.sequences) != len(want) {
+		t.Fatal(errors.Errorf("%d sequences, want %d", len(cat.sequences), len(want)))
+	}
+	for k, s := range cat.sequences {
+		if !reflect.DeepEqual(s, want[k]) {
+			t.Error(errors.Errorf("sequence %s: %+v", want[k].seqName, *s))
+		}
+	}
+	tables, err := cat.Tables("PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, err := cat.Columns(tables[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := columns[0].identity; i == nil || i.nextValue != 5 || i.cacheSize != 1 {
+		t.Error(errors.New("identity options are wrong"))
+	}
+}
+
+func TestParseH2ScriptTypeAliases(t *testing.T) {
+	cat, err := parseH2Script(strings.NewReader(`
+		CREATE TABLE T(A NUMBER(10, 2), B DEC(5), C DATETIME, D TEXT, E INT4, F INT8,
+			G BOOL, H VARCHAR2(30), I LONGVARCHAR(40))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ddl, err := s.tables[0].createDDL(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ddl != `create table if not exists "PUBLIC"."T"("A" numeric(10,2),"B" numeric(5,0),"C" timestamp without time zone,"D" text,`+
+		`"E" integer,"F" bigint,"G" boolean,"H" character varying(30),"I" character varying(40));` {
+		t.Error(errors.Errorf("DDL of the aliased types: %s", ddl))
+	}
+}
+
+func TestParseH2ScriptReferences(t *testing.T) {
+	cat, err := parseH2Script(strings.NewReader(`
+		CREATE TABLE "APP"."P"("A" INT, "B" INT);
+		ALTER TABLE "APP"."P" ADD CONSTRAINT "APP"."PK_P" PRIMARY KEY("A", "B");
+		CREATE TABLE "PUBLIC"."C"("X" INT, "Y" INT);
+		ALTER TABLE "PUBLIC"."C" ADD CONSTRAINT "PUBLIC"."FK_C" FOREIGN KEY("X", "Y") REFERENCES "APP"."P" ON DELETE SET NULL NOCHECK`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := s.tables[0].cons[0]
+	r := c.references
+	if r == nil || r.schemaName != "APP" || r.tableName != "P" || !reflect.DeepEqual(r.refColumns, []string{"A", "B"}) ||
+		r.deleteRule != h2RuleSetNull || r.updateRule != h2RuleRestrict {
+		t.Fatal(errors.New("reference to the primary key of APP.P is wrong"))
+	}
+	ddl, err := c.createDDL()
+	if err != nil || ddl != `alter table "PUBLIC"."C" add constraint "FK_C" foreign key ("X","Y") references "APP"."P"("A","B") on delete set null on update restrict;` {
+		t.Error(errors.Errorf("DDL: %s %v", ddl, err))
+	}
+}
+
+func TestParseH2ScriptErrorMessages(t *testing.T) {
+	for script, want := range map[string]string{
+		"CREATE TABLE T(A INT);\n/* unterminated":                     "line 2: unterminated comment",
+		"CREATE TABLE T(A INT DEFAULT $$x);":                          "line 1: unterminated $$",
+		"\nCREATE TABLE \"T(A INT);":                                  `line 2: unterminated "`,
+		"CREATE SEQUENCE S AS INTEGER START WITH X;":                  "line 1: number expected",
+		"CREATE SEQUENCE S SOON;":                                     `unexpected "SOON" in the options of sequence S`,
+		"CREATE TABLE T(A INT NOT NULL 5);":                           `unexpected "5" in the definition of column A`,
+		"CREATE TABLE P(A INT);\nCREATE TABLE C(B INT REFERENCES P);": "line 2: foreign key C_B_FKEY refers to PUBLIC.P, which has no primary key yet",
+		"CREATE TABLE P(A INT PRIMARY KEY);\nCREATE TABLE C(B INT, D INT, FOREIGN KEY(B, D) REFERENCES P);": "has 2 columns referring to 1",
+	} {
+		_, err := parseH2Script(strings.NewReader(script))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Error(errors.Errorf("parsing %q: %v, want %q", script, err, want))
+		}
+	}
+}
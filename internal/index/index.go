@@ -0,0 +1,180 @@
+// Package index keeps an inverted index of documents numbered by int32
+// ordinals: every (field, value) term of a document maps to the sparse.BitSet
+// of the ordinals of the documents holding it, its postings. Boolean queries
+// over the terms are evaluated with the set operations of sparse.BitSet,
+// without touching the documents themselves.
+package index
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ovlad32/hpg/internal/sparse"
+)
+
+// ErrDuplicate is returned by Add for a document ordinal already indexed.
+var ErrDuplicate = errors.New("index: document already indexed")
+
+// Document holds the values of the fields of a document to be indexed. A
+// field may have several values; a document matches a term if any value of
+// the field equals the value of the term.
+type Document map[string][]string
+
+// Index is an inverted index. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]*sparse.BitSet //  field -> value -> ordinals
+	docs     map[int32]Document                   //  The terms to remove on Update and Delete
+	all      *sparse.BitSet                       //  The ordinals of all documents, for Not
+	options  []sparse.Option
+}
+
+// New creates an empty index. The options configure every bit set of the
+// index, postings and query results alike.
+func New(options ...sparse.Option) *Index {
+	return &Index{
+		postings: make(map[string]map[string]*sparse.BitSet),
+		docs:     make(map[int32]Document),
+		all:      sparse.New(options...),
+		options:  options,
+	}
+}
+
+// Len returns the number of documents in the index.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Document returns the fields of the document with the given ordinal as
+// indexed, and false if there is no such document. The values of a field are
+// returned sorted, without duplicates; the Document must not be modified.
+func (ix *Index) Document(doc int32) (Document, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	d, ok := ix.docs[doc]
+	return d, ok
+}
+
+// Add indexes the document under the given ordinal, which must be a valid
+// bit index (0..math.MaxInt32-1) not indexed yet.
+func (ix *Index) Add(doc int32, d Document) error {
+	if err := checkOrdinal(doc); err != nil {
+		return err
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, ok := ix.docs[doc]; ok {
+		return fmt.Errorf("%w: %d", ErrDuplicate, doc)
+	}
+	ix.insert(doc, d)
+	return nil
+}
+
+// Update replaces the indexed fields of a document, indexing it if it is not
+// indexed yet.
+func (ix *Index) Update(doc int32, d Document) error {
+	if err := checkOrdinal(doc); err != nil {
+		return err
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(doc)
+	ix.insert(doc, d)
+	return nil
+}
+
+// Delete removes a document from the index, and reports whether it was
+// indexed.
+func (ix *Index) Delete(doc int32) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.remove(doc)
+}
+
+// checkOrdinal fails for an ordinal a bit set cannot hold.
+func checkOrdinal(doc int32) error {
+	if doc < 0 || doc == math.MaxInt32 {
+		return fmt.Errorf("index: document ordinal %d out of range", doc)
+	}
+	return nil
+}
+
+// insert adds the terms of the document to the postings. The document is
+// copied, so that the caller may reuse it.
+func (ix *Index) insert(doc int32, d Document) {
+	stored := make(Document, len(d))
+	for field, values := range d {
+		if len(values) == 0 {
+			continue
+		}
+		stored[field] = sortedSet(values)
+		for _, value := range stored[field] {
+			ix.posting(field, value, true).Set(doc)
+		}
+	}
+	ix.docs[doc] = stored
+	ix.all.Set(doc)
+}
+
+// sortedSet returns a sorted copy of values without duplicates, the form in
+// which the index keeps the values of a field (and Load recovers them).
+func sortedSet(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	n := 0
+	for k, value := range sorted {
+		if k == 0 || value != sorted[n-1] {
+			sorted[n] = value
+			n++
+		}
+	}
+	return sorted[:n]
+}
+
+// remove clears the document from the postings of its terms, dropping the
+// postings left empty.
+func (ix *Index) remove(doc int32) bool {
+	d, ok := ix.docs[doc]
+	if !ok {
+		return false
+	}
+	for field, values := range d {
+		for _, value := range values {
+			if p := ix.posting(field, value, false); p != nil {
+				p.Clear(doc)
+				if p.IsEmpty() {
+					delete(ix.postings[field], value)
+				}
+			}
+		}
+		if len(ix.postings[field]) == 0 {
+			delete(ix.postings, field)
+		}
+	}
+	delete(ix.docs, doc)
+	ix.all.Clear(doc)
+	return true
+}
+
+// posting returns the postings of a term, creating them if asked to, or nil.
+func (ix *Index) posting(field, value string, create bool) *sparse.BitSet {
+	values := ix.postings[field]
+	if values == nil {
+		if !create {
+			return nil
+		}
+		values = make(map[string]*sparse.BitSet)
+		ix.postings[field] = values
+	}
+	p := values[value]
+	if p == nil && create {
+		p = sparse.New(ix.options...)
+		values[value] = p
+	}
+	return p
+}
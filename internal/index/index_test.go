@@ -0,0 +1,104 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testIndex(t *testing.T) *Index {
+	ix := New()
+	docs := []Document{
+		{"table": {"ORDERS"}, "type": {"VARCHAR"}, "tag": {"pk", "migrated"}},
+		{"table": {"ORDERS"}, "type": {"INTEGER"}},
+		{"table": {"ORDER_LINES"}, "type": {"VARCHAR"}, "tag": {"migrated"}},
+		{"table": {"CUSTOMERS"}, "type": {"CLOB"}},
+	}
+	for doc, d := range docs {
+		if err := ix.Add(int32(doc*1000), d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ix
+}
+
+func TestSearch(t *testing.T) {
+	ix := testIndex(t)
+	tests := []struct {
+		q    Query
+		want []int32
+	}{
+		{Term("table", "ORDERS"), []int32{0, 1000}},
+		{Term("table", "NONE"), []int32{}},
+		{Prefix("table", "ORDER"), []int32{0, 1000, 2000}},
+		{And(Term("type", "VARCHAR"), Term("tag", "migrated")), []int32{0, 2000}},
+		{And(Prefix("table", "ORDER"), Not(Term("tag", "pk"))), []int32{1000, 2000}},
+		{Or(Term("type", "CLOB"), Term("tag", "pk")), []int32{0, 3000}},
+		{Not(Term("tag", "migrated")), []int32{1000, 3000}},
+		{And(), []int32{0, 1000, 2000, 3000}},
+		{Or(), []int32{}},
+	}
+	for _, tt := range tests {
+		if got := ix.Search(tt.q).ToSlice(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+
+	/*  The result is the caller's: changing it must not change the index. */
+	ix.Search(Term("table", "ORDERS")).Set(5)
+	if got := ix.Search(Term("table", "ORDERS")).ToSlice(); !reflect.DeepEqual(got, []int32{0, 1000}) {
+		t.Errorf("postings changed through a search result: %v", got)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	ix := testIndex(t)
+	if err := ix.Add(0, Document{}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Add of an indexed document: err = %v, want ErrDuplicate", err)
+	}
+	if err := ix.Update(1000, Document{"table": {"ORDER_LINES"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !ix.Delete(0) || ix.Delete(0) {
+		t.Error("Delete did not report the document indexed exactly once")
+	}
+	if got := ix.Search(Prefix("table", "ORDER")).ToSlice(); !reflect.DeepEqual(got, []int32{1000, 2000}) {
+		t.Errorf("ORDER* after update and delete = %v", got)
+	}
+	if _, ok := ix.postings["table"]["ORDERS"]; ok {
+		t.Error("empty postings of table=ORDERS kept")
+	}
+	if ix.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", ix.Len())
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ix := testIndex(t)
+	var buf bytes.Buffer
+	if err := ix.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snap := buf.Bytes()
+	loaded := New()
+	if err := loaded.Load(bytes.NewReader(snap)); err != nil {
+		t.Fatal(err)
+	}
+	for doc, d := range ix.docs {
+		if got, _ := loaded.Document(doc); !reflect.DeepEqual(got, d) {
+			t.Errorf("document %d loaded as %v, want %v", doc, got, d)
+		}
+	}
+	q := And(Prefix("table", "ORDER"), Not(Term("tag", "pk")))
+	if got, want := loaded.Search(q).ToSlice(), ix.Search(q).ToSlice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Search(%v) after Load = %v, want %v", q, got, want)
+	}
+	buf.Reset()
+	if err := loaded.Snapshot(&buf); err != nil || !bytes.Equal(buf.Bytes(), snap) {
+		t.Errorf("snapshot of the loaded index differs (err = %v)", err)
+	}
+	if err := loaded.Load(bytes.NewReader(snap[:len(snap)/2])); err == nil || loaded.Len() != 4 {
+		t.Errorf("Load of a truncated snapshot: err = %v, %d documents left", err, loaded.Len())
+	}
+}
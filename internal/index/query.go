@@ -0,0 +1,200 @@
+package index
+
+import (
+	"strings"
+
+	"github.com/ovlad32/hpg/internal/sparse"
+)
+
+// Query selects documents of an index. Queries are built with Term, Prefix,
+// All, And, Or and Not, and evaluated by Index.Search.
+type Query interface {
+	String() string
+	/*  eval returns the ordinals of the matching documents; if shared is
+	true, the set belongs to the index and must be copied before changing. */
+	eval(ix *Index) (result *sparse.BitSet, shared bool)
+}
+
+// Term selects the documents with the given value in the field.
+func Term(field, value string) Query {
+	return termQuery{field, value}
+}
+
+// Prefix selects the documents with a value starting with prefix in the
+// field.
+func Prefix(field, prefix string) Query {
+	return prefixQuery{field, prefix}
+}
+
+// All selects every document of the index.
+func All() Query {
+	return allQuery{}
+}
+
+// And selects the documents selected by every query given; And() selects
+// every document.
+func And(queries ...Query) Query {
+	return andQuery(queries)
+}
+
+// Or selects the documents selected by any query given; Or() selects none.
+func Or(queries ...Query) Query {
+	return orQuery(queries)
+}
+
+// Not selects the documents of the index not selected by q.
+func Not(q Query) Query {
+	return notQuery{q}
+}
+
+type termQuery struct {
+	field, value string
+}
+
+func (q termQuery) String() string {
+	return q.field + "=" + q.value
+}
+
+func (q termQuery) eval(ix *Index) (*sparse.BitSet, bool) {
+	if p := ix.posting(q.field, q.value, false); p != nil {
+		return p, true
+	}
+	return sparse.New(ix.options...), false
+}
+
+type prefixQuery struct {
+	field, prefix string
+}
+
+func (q prefixQuery) String() string {
+	return q.field + "=" + q.prefix + "*"
+}
+
+func (q prefixQuery) eval(ix *Index) (result *sparse.BitSet, shared bool) {
+	for value, p := range ix.postings[q.field] {
+		if !strings.HasPrefix(value, q.prefix) {
+			continue
+		}
+		result, shared = union(result, shared, p, true)
+	}
+	if result == nil {
+		return sparse.New(ix.options...), false
+	}
+	return result, shared
+}
+
+type allQuery struct{}
+
+func (allQuery) String() string {
+	return "*"
+}
+
+func (allQuery) eval(ix *Index) (*sparse.BitSet, bool) {
+	return ix.all, true
+}
+
+type andQuery []Query
+
+func (q andQuery) String() string {
+	return join(q, " AND ")
+}
+
+/*
+ *  The negated operands are left for last and subtracted with AndNot, so
+ *  that "a AND NOT b" never computes the complement of b.
+ */
+func (q andQuery) eval(ix *Index) (result *sparse.BitSet, shared bool) {
+	var negated []Query
+	for _, operand := range q {
+		if n, ok := operand.(notQuery); ok {
+			negated = append(negated, n.q)
+			continue
+		}
+		bs, sh := operand.eval(ix)
+		if result == nil {
+			result, shared = bs, sh
+			continue
+		}
+		if shared {
+			result, shared = result.Clone(), false
+		}
+		result.AndBitSet(bs)
+		if result.IsEmpty() {
+			return result, false
+		}
+	}
+	if result == nil {
+		result, shared = ix.all, true
+	}
+	for _, operand := range negated {
+		bs, _ := operand.eval(ix)
+		if shared {
+			result, shared = result.Clone(), false
+		}
+		result.AndNotBitSet(bs)
+	}
+	return result, shared
+}
+
+type orQuery []Query
+
+func (q orQuery) String() string {
+	return join(q, " OR ")
+}
+
+func (q orQuery) eval(ix *Index) (result *sparse.BitSet, shared bool) {
+	for _, operand := range q {
+		bs, sh := operand.eval(ix)
+		result, shared = union(result, shared, bs, sh)
+	}
+	if result == nil {
+		return sparse.New(ix.options...), false
+	}
+	return result, shared
+}
+
+type notQuery struct {
+	q Query
+}
+
+func (q notQuery) String() string {
+	return "NOT " + q.q.String()
+}
+
+func (q notQuery) eval(ix *Index) (*sparse.BitSet, bool) {
+	bs, _ := q.q.eval(ix)
+	return sparse.AndNot(ix.all, bs), false
+}
+
+// union ors bs into result, copying result first if it is shared; a nil
+// result is taken to be empty, and replaced by bs.
+func union(result *sparse.BitSet, shared bool, bs *sparse.BitSet, bsShared bool) (*sparse.BitSet, bool) {
+	if result == nil {
+		return bs, bsShared
+	}
+	if shared {
+		result = result.Clone()
+	}
+	result.OrBitSet(bs)
+	return result, false
+}
+
+func join(queries []Query, op string) string {
+	terms := make([]string, len(queries))
+	for k, q := range queries {
+		terms[k] = q.String()
+	}
+	return "(" + strings.Join(terms, op) + ")"
+}
+
+// Search returns the ordinals of the documents selected by q, in a set the
+// caller owns.
+func (ix *Index) Search(q Query) *sparse.BitSet {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	result, shared := q.eval(ix)
+	if shared {
+		result = result.Clone()
+	}
+	return result
+}
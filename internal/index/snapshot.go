@@ -0,0 +1,102 @@
+package index
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ovlad32/hpg/internal/sparse"
+)
+
+// snapshotVersion is the version of the snapshot format written by Snapshot.
+const snapshotVersion = 1
+
+/*
+ *  A snapshot is gob encoded. The sets of ordinals are kept in the binary
+ *  form of sparse.BitSet.MarshalBinary; the documents are not kept, Load
+ *  recovers their terms from the postings.
+ */
+type snapshot struct {
+	Version int
+	All     []byte
+	Terms   []snapshotTerm
+}
+
+type snapshotTerm struct {
+	Field    string
+	Value    string
+	Postings []byte
+}
+
+// Snapshot writes the index to w, terms in order of field and value, so that
+// equal indexes give equal snapshots.
+func (ix *Index) Snapshot(w io.Writer) error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	s := snapshot{Version: snapshotVersion}
+	var err error
+	if s.All, err = ix.all.MarshalBinary(); err != nil {
+		return err
+	}
+	for field, values := range ix.postings {
+		for value, p := range values {
+			data, err := p.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			s.Terms = append(s.Terms, snapshotTerm{field, value, data})
+		}
+	}
+	sort.Slice(s.Terms, func(i, j int) bool {
+		if s.Terms[i].Field != s.Terms[j].Field {
+			return s.Terms[i].Field < s.Terms[j].Field
+		}
+		return s.Terms[i].Value < s.Terms[j].Value
+	})
+	return gob.NewEncoder(w).Encode(&s)
+}
+
+// Load replaces the contents of the index with a snapshot read from r. The
+// index is left unchanged if the snapshot cannot be read or is inconsistent.
+func (ix *Index) Load(r io.Reader) error {
+	var s snapshot
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("index: reading snapshot: %w", err)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("index: unknown snapshot version %d", s.Version)
+	}
+	all := sparse.New(ix.options...)
+	if err := all.UnmarshalBinary(s.All); err != nil {
+		return fmt.Errorf("index: reading snapshot: %w", err)
+	}
+	docs := make(map[int32]Document, all.Cardinality())
+	for _, doc := range all.ToSlice() {
+		docs[doc] = Document{}
+	}
+	postings := make(map[string]map[string]*sparse.BitSet)
+	for _, t := range s.Terms {
+		p := sparse.New(ix.options...)
+		if err := p.UnmarshalBinary(t.Postings); err != nil {
+			return fmt.Errorf("index: reading postings of %s=%s: %w", t.Field, t.Value, err)
+		}
+		if p.IsEmpty() || !sparse.AndNot(p, all).IsEmpty() {
+			return fmt.Errorf("index: postings of %s=%s are empty or hold unknown documents", t.Field, t.Value)
+		}
+		if postings[t.Field] == nil {
+			postings[t.Field] = make(map[string]*sparse.BitSet)
+		}
+		if postings[t.Field][t.Value] != nil {
+			return fmt.Errorf("index: duplicate postings of %s=%s", t.Field, t.Value)
+		}
+		postings[t.Field][t.Value] = p
+		for _, doc := range p.ToSlice() {
+			docs[doc][t.Field] = append(docs[doc][t.Field], t.Value)
+		}
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.postings, ix.docs, ix.all = postings, docs, all
+	return nil
+}
@@ -0,0 +1,306 @@
+package sparse
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// RangeOp selects the comparison evaluated by BitSlicedIndex.Range.
+type RangeOp int
+
+const (
+	// RangeLT selects rows whose value is less than lo.
+	RangeLT RangeOp = iota
+	// RangeLE selects rows whose value is less than or equal to lo.
+	RangeLE
+	// RangeEQ selects rows whose value is equal to lo.
+	RangeEQ
+	// RangeNE selects rows whose value is not equal to lo.
+	RangeNE
+	// RangeGE selects rows whose value is greater than or equal to lo.
+	RangeGE
+	// RangeGT selects rows whose value is greater than lo.
+	RangeGT
+	// RangeBetween selects rows whose value lies within lo..hi, both inclusive.
+	RangeBetween
+)
+
+func (op RangeOp) String() string {
+	switch op {
+	case RangeLT:
+		return "<"
+	case RangeLE:
+		return "<="
+	case RangeEQ:
+		return "="
+	case RangeNE:
+		return "<>"
+	case RangeGE:
+		return ">="
+	case RangeGT:
+		return ">"
+	case RangeBetween:
+		return "between"
+	default:
+		panic(fmt.Sprintf("Unknown range operation %d", op))
+	}
+}
+
+/*BitSlicedIndex ...
+ *  A bit-sliced index keeps an integer value for every row (bit index) of a
+ *  column. Bit k of every value is stored in its own BitSet (the k-th slice),
+ *  and the rows that hold a value at all are kept in the existence bitmap.
+ *  <p>
+ *  Values are held in two's complement using as many slices as the widest
+ *  value needs; the last slice is the sign slice. Widening the index just
+ *  sign-extends it by repeating the sign slice.
+ *  <p>
+ *  Predicates and aggregates are evaluated slice by slice with the set
+ *  operations of BitSet, so their cost depends on the number of slices and
+ *  not on the number of rows.
+ */
+type BitSlicedIndex struct {
+	ebm    *BitSet
+	slices []*BitSet
+}
+
+// NewBitSlicedIndex creates an empty bit-sliced index.
+func NewBitSlicedIndex() *BitSlicedIndex {
+	return &BitSlicedIndex{
+		ebm: New(),
+	}
+}
+
+// Depth returns the number of bit slices currently kept, sign slice included.
+func (bsi *BitSlicedIndex) Depth() int {
+	return len(bsi.slices)
+}
+
+// Existence returns the bitmap of the rows that hold a value. The bitmap is
+// owned by the index and must not be modified.
+func (bsi *BitSlicedIndex) Existence() *BitSet {
+	return bsi.ebm
+}
+
+// Cardinality returns the number of rows that hold a value.
+func (bsi *BitSlicedIndex) Cardinality() int32 {
+	return bsi.ebm.Cardinality()
+}
+
+// depthOf returns the number of two's complement bits needed to hold v.
+func depthOf(v int64) int {
+	if v < 0 {
+		v = ^v
+	}
+	return bits.Len64(uint64(v)) + 1
+}
+
+// grow sign-extends the index up to the given depth.
+func (bsi *BitSlicedIndex) grow(depth int) {
+	for len(bsi.slices) < depth {
+		var slice *BitSet
+		if n := len(bsi.slices); n == 0 {
+			slice = New()
+		} else {
+			slice = bsi.slices[n-1].Clone()
+		}
+		bsi.slices = append(bsi.slices, slice)
+	}
+}
+
+// Set stores value for the given row, replacing any value the row had.
+func (bsi *BitSlicedIndex) Set(row int32, value int64) {
+	bsi.grow(depthOf(value))
+	for k, slice := range bsi.slices {
+		/*  The slices above the 64th bit do not exist, so the shift never
+		runs out of bits of the value. */
+		slice.SetBit(row, (value>>uint(k))&1 != 0)
+	}
+	bsi.ebm.Set(row)
+}
+
+// Clear removes the value of the given row.
+func (bsi *BitSlicedIndex) Clear(row int32) {
+	for _, slice := range bsi.slices {
+		slice.Clear(row)
+	}
+	bsi.ebm.Clear(row)
+}
+
+// Get returns the value of the given row, and false if the row has none.
+func (bsi *BitSlicedIndex) Get(row int32) (value int64, ok bool) {
+	if !bsi.ebm.GetBit(row) {
+		return
+	}
+	depth := len(bsi.slices)
+	for k, slice := range bsi.slices {
+		if slice.GetBit(row) {
+			value |= 1 << uint(k)
+		}
+	}
+	/*  Sign-extend from the sign slice. */
+	if depth > 0 && depth < 64 && bsi.slices[depth-1].GetBit(row) {
+		value |= -1 << uint(depth)
+	}
+	return value, true
+}
+
+/*Range ...
+ *  Returns a new BitSet of the rows whose value satisfies the comparison
+ *  with <i>lo</i> (and <i>hi</i> for RangeBetween). Rows without a value are
+ *  never selected.
+ */
+func (bsi *BitSlicedIndex) Range(op RangeOp, lo, hi int64) *BitSet {
+	switch op {
+	case RangeLT:
+		lt, _, _ := bsi.compare(lo)
+		return lt
+	case RangeLE:
+		lt, eq, _ := bsi.compare(lo)
+		lt.OrBitSet(eq)
+		return lt
+	case RangeEQ:
+		_, eq, _ := bsi.compare(lo)
+		return eq
+	case RangeNE:
+		lt, _, gt := bsi.compare(lo)
+		lt.OrBitSet(gt)
+		return lt
+	case RangeGE:
+		_, eq, gt := bsi.compare(lo)
+		gt.OrBitSet(eq)
+		return gt
+	case RangeGT:
+		_, _, gt := bsi.compare(lo)
+		return gt
+	case RangeBetween:
+		if hi < lo {
+			return New()
+		}
+		ge := bsi.Range(RangeGE, lo, 0)
+		ge.AndBitSet(bsi.Range(RangeLE, hi, 0))
+		return ge
+	default:
+		panic(fmt.Sprintf("Unknown range operation %d", op))
+	}
+}
+
+/*
+ *  Splits the existing rows into those less than, equal to, and greater than
+ *  the constant c. The slices are visited from the most significant one down,
+ *  narrowing the set of rows still equal to the prefix of c seen so far. The
+ *  sign slice has the reversed order: a set sign bit means a smaller value.
+ */
+func (bsi *BitSlicedIndex) compare(c int64) (lt, eq, gt *BitSet) {
+	lt, gt = New(), New()
+	eq = bsi.ebm.Clone()
+	depth := len(bsi.slices)
+	/*  A constant that needs more bits than the index has is either above or
+	below every stored value. */
+	if depthOf(c) > depth {
+		if c < 0 {
+			return lt, New(), eq
+		}
+		return eq, New(), gt
+	}
+	for k := depth - 1; k >= 0; k-- {
+		slice := bsi.slices[k]
+		bit := (c>>uint(k))&1 != 0
+		if k == depth-1 {
+			/*  Sign slice: the rows with a clear sign bit are the greater ones. */
+			slice = AndNot(bsi.ebm, slice)
+			bit = !bit
+		}
+		if bit {
+			lt.OrBitSet(AndNot(eq, slice))
+			eq.AndBitSet(slice)
+		} else {
+			gt.OrBitSet(And(eq, slice))
+			eq.AndNotBitSet(slice)
+		}
+	}
+	return
+}
+
+// filtered returns the existing rows limited by filter, all of them if
+// filter is nil.
+func (bsi *BitSlicedIndex) filtered(filter *BitSet) *BitSet {
+	if filter == nil {
+		return bsi.ebm.Clone()
+	}
+	return And(bsi.ebm, filter)
+}
+
+/*Sum ...
+ *  Returns the sum of the values of the rows selected by <i>filter</i> (all
+ *  rows if it is nil) together with the number of rows that were summed.
+ *  Each slice contributes its weight times the number of selected rows having
+ *  that bit set; the sign slice contributes with a negative weight. As with
+ *  int64 arithmetic, the sum wraps around on overflow.
+ */
+func (bsi *BitSlicedIndex) Sum(filter *BitSet) (sum int64, count int32) {
+	rows := bsi.filtered(filter)
+	count = rows.Cardinality()
+	if count == 0 {
+		return
+	}
+	depth := len(bsi.slices)
+	for k, slice := range bsi.slices {
+		n := int64(And(rows, slice).Cardinality())
+		if k == depth-1 {
+			sum -= n << uint(k)
+		} else {
+			sum += n << uint(k)
+		}
+	}
+	return
+}
+
+// Min returns the smallest value among the rows selected by filter (all rows
+// if it is nil), and false if no row is selected.
+func (bsi *BitSlicedIndex) Min(filter *BitSet) (min int64, ok bool) {
+	return bsi.extreme(filter, false)
+}
+
+// Max returns the largest value among the rows selected by filter (all rows
+// if it is nil), and false if no row is selected.
+func (bsi *BitSlicedIndex) Max(filter *BitSet) (max int64, ok bool) {
+	return bsi.extreme(filter, true)
+}
+
+/*
+ *  Walks the slices from the most significant one down, keeping at each step
+ *  only the candidates that have the preferred bit, if there are any. For the
+ *  maximum the preferred bit is one, for the minimum it is zero; on the sign
+ *  slice the preference is reversed.
+ */
+func (bsi *BitSlicedIndex) extreme(filter *BitSet, max bool) (value int64, ok bool) {
+	candidates := bsi.filtered(filter)
+	if candidates.IsEmpty() {
+		return
+	}
+	depth := len(bsi.slices)
+	for k := depth - 1; k >= 0; k-- {
+		slice := bsi.slices[k]
+		preferOne := max != (k == depth-1)
+		var preferred *BitSet
+		if preferOne {
+			preferred = And(candidates, slice)
+		} else {
+			preferred = AndNot(candidates, slice)
+		}
+		bit := preferOne
+		if preferred.IsEmpty() {
+			bit = !bit
+		} else {
+			candidates = preferred
+		}
+		if bit {
+			value |= 1 << uint(k)
+		}
+	}
+	if depth > 0 && depth < 64 && value&(1<<uint(depth-1)) != 0 {
+		value |= -1 << uint(depth)
+	}
+	return value, true
+}
@@ -0,0 +1,162 @@
+package sparse
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testIndex builds an index of the given values, setting the small ones
+// first so that the index is widened after negative values are stored.
+func testIndex(values map[int32]int64) *BitSlicedIndex {
+	rows := make([]int32, 0, len(values))
+	for row := range values {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return depthOf(values[rows[i]]) < depthOf(values[rows[j]]) ||
+			depthOf(values[rows[i]]) == depthOf(values[rows[j]]) && rows[i] < rows[j]
+	})
+	bsi := NewBitSlicedIndex()
+	for _, row := range rows {
+		bsi.Set(row, values[row])
+	}
+	return bsi
+}
+
+func selected(values map[int32]int64, pred func(int64) bool) []int32 {
+	result := []int32{}
+	for row, v := range values {
+		if pred(v) {
+			result = append(result, row)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func TestBitSlicedIndexRange(t *testing.T) {
+	values := map[int32]int64{
+		0: 5, 1: -3, 2: 0, 3: 1 << 40, 4: -(1 << 40), 7: 127, 8: -128, 10: 5, 1000: -1, 1 << 20: 64,
+	}
+	bsi := testIndex(values)
+	if bsi.Cardinality() != int32(len(values)) || bsi.Depth() != depthOf(1<<40) {
+		t.Fatalf("cardinality %d, depth %d", bsi.Cardinality(), bsi.Depth())
+	}
+	for row, want := range values {
+		if got, ok := bsi.Get(row); !ok || got != want {
+			t.Errorf("Get(%d) = %d, %v, want %d", row, got, ok, want)
+		}
+	}
+	if _, ok := bsi.Get(5); ok {
+		t.Error("Get of a row without a value succeeded")
+	}
+
+	preds := map[RangeOp]func(v, c int64) bool{
+		RangeLT: func(v, c int64) bool { return v < c },
+		RangeLE: func(v, c int64) bool { return v <= c },
+		RangeEQ: func(v, c int64) bool { return v == c },
+		RangeNE: func(v, c int64) bool { return v != c },
+		RangeGE: func(v, c int64) bool { return v >= c },
+		RangeGT: func(v, c int64) bool { return v > c },
+	}
+	constants := []int64{-(1 << 50), -(1 << 40), -200, -128, -3, -1, 0, 1, 5, 64, 127, 1 << 40, 1 << 50}
+	for op, pred := range preds {
+		for _, c := range constants {
+			want := selected(values, func(v int64) bool { return pred(v, c) })
+			if got := bsi.Range(op, c, 0).ToSlice(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Range(%v %d) = %v, want %v", op, c, got, want)
+			}
+		}
+	}
+	for _, r := range [][2]int64{{-3, 5}, {-(1 << 50), 0}, {6, 126}, {5, 5}, {5, -3}} {
+		want := selected(values, func(v int64) bool { return r[0] <= v && v <= r[1] })
+		if got := bsi.Range(RangeBetween, r[0], r[1]).ToSlice(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Range(between %d and %d) = %v, want %v", r[0], r[1], got, want)
+		}
+	}
+
+	bsi.Set(0, -7)
+	bsi.Clear(3)
+	if v, ok := bsi.Get(0); !ok || v != -7 {
+		t.Errorf("Get(0) = %d, %v after replacing the value, want -7", v, ok)
+	}
+	if got := bsi.Range(RangeGE, 1<<40, 0).ToSlice(); len(got) != 0 {
+		t.Errorf("cleared row still selected: %v", got)
+	}
+}
+
+func TestBitSlicedIndexAggregates(t *testing.T) {
+	values := map[int32]int64{0: 5, 1: -3, 2: 0, 3: 1 << 40, 4: -(1 << 40), 7: 127, 8: -128, 9: -1}
+	bsi := testIndex(values)
+	filter := New()
+	for _, row := range []int32{1, 2, 7, 8, 100} {
+		filter.Set(row)
+	}
+
+	var wantSum, filterSum int64
+	for row, v := range values {
+		wantSum += v
+		if filter.GetBit(row) {
+			filterSum += v
+		}
+	}
+	if sum, count := bsi.Sum(nil); sum != wantSum || count != int32(len(values)) {
+		t.Errorf("Sum(nil) = %d, %d, want %d, %d", sum, count, wantSum, len(values))
+	}
+	if sum, count := bsi.Sum(filter); sum != filterSum || count != 4 {
+		t.Errorf("Sum(filter) = %d, %d, want %d, 4", sum, count, filterSum)
+	}
+
+	for _, test := range []struct {
+		filter   *BitSet
+		min, max int64
+	}{
+		{nil, -(1 << 40), 1 << 40},
+		{filter, -128, 127},
+		{FromSorted([]int32{1, 9}), -3, -1},
+	} {
+		if min, ok := bsi.Min(test.filter); !ok || min != test.min {
+			t.Errorf("Min(%v) = %d, %v, want %d", test.filter, min, ok, test.min)
+		}
+		if max, ok := bsi.Max(test.filter); !ok || max != test.max {
+			t.Errorf("Max(%v) = %d, %v, want %d", test.filter, max, ok, test.max)
+		}
+	}
+
+	empty := FromSorted([]int32{5, 6})
+	if _, ok := bsi.Min(empty); ok {
+		t.Error("Min over no rows succeeded")
+	}
+	if sum, count := bsi.Sum(empty); sum != 0 || count != 0 {
+		t.Errorf("Sum over no rows = %d, %d", sum, count)
+	}
+}
+
+func TestBitSlicedIndexExtremes(t *testing.T) {
+	bsi := NewBitSlicedIndex()
+	bsi.Set(1, math.MinInt64)
+	bsi.Set(2, math.MaxInt64)
+	bsi.Set(3, 0)
+	if bsi.Depth() != 64 {
+		t.Fatalf("depth %d, want 64", bsi.Depth())
+	}
+	for row, want := range map[int32]int64{1: math.MinInt64, 2: math.MaxInt64, 3: 0} {
+		if got, _ := bsi.Get(row); got != want {
+			t.Errorf("Get(%d) = %d, want %d", row, got, want)
+		}
+	}
+	if min, _ := bsi.Min(nil); min != math.MinInt64 {
+		t.Errorf("Min = %d", min)
+	}
+	if max, _ := bsi.Max(nil); max != math.MaxInt64 {
+		t.Errorf("Max = %d", max)
+	}
+	if got := bsi.Range(RangeLT, 0, 0).ToSlice(); !reflect.DeepEqual(got, []int32{1}) {
+		t.Errorf("Range(< 0) = %v", got)
+	}
+	if got := bsi.Range(RangeGT, math.MaxInt64-1, 0).ToSlice(); !reflect.DeepEqual(got, []int32{2}) {
+		t.Errorf("Range(> MaxInt64-1) = %v", got)
+	}
+}
@@ -1,6 +1,11 @@
 package sparse
 
-const compactionCountDefault int32 = 2
+/**
+ *  The compaction count given to bit sets made without WithCompactionCount,
+ *  read and written atomically.
+ * @see #SetDefaultCompactionCount(int32)
+ */
+var compactionCountDefault int32 = 2
 
 /**
  *  The number of bits in a long value.
@@ -26,14 +31,14 @@ const cIndexSize uint32 = cIntegerSize - 1 //Integer.SIZE - 1;
 const cLevel4 uint32 = 6
 
 /**
- *  LEVEL3 is the number of bits of the level3 address.
- *  Do not change!
+ *  LEVEL3 is the number of bits of the level3 address in DefaultGeometry.
+ *  The constants derived from LEVEL2 and LEVEL3 below describe the default
+ *  geometry only; a set uses the values of its own layout (see geometry.go).
  */
 const cLevel3 uint32 = 5
 
 /**
- *  LEVEL2 is the number of bits of the level3 address.
- *  Do not change!
+ *  LEVEL2 is the number of bits of the level2 address in DefaultGeometry.
  */
 const cLevel2 uint32 = 5
 
@@ -141,3 +146,10 @@ const cValueOpFalseEqFalse = 0x4
  *  'xor', and 'andNot', since for all of these "x" op "false" = "x".
  */
 const cValueOpFalseEqValue = 0x8
+
+/** If the operation only reads the level3 blocks of this set (it may still
+ *  remove blocks found to be all zero), then this property is required. For
+ *  example, this is true for 'equals', 'intersects' and the statistics
+ *  update, and it spares blocks shared through an interner from being copied.
+ */
+const cReadsOnly = 0x10
@@ -0,0 +1,169 @@
+package sparse
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+/*Format ...
+ *  Implements fmt.Formatter. The verbs are:
+ *  <ul>
+ *  <li>%v and %s - the set notation with runs folded as by String(),
+ *      e.g. "{2..4,10}"
+ *  <li>%d - the set notation listing every index, e.g. "{2,3,4,10}"
+ *  <li>%x - the non-zero words as "word index:hex value" pairs,
+ *      e.g. "{0:000000000000041c}"
+ *  <li>%+v - a dump of the level1/level2/level3 structure with the occupancy
+ *      of every area and block, intended for diagnosing the layout of a set
+ *  </ul>
+ */
+func (bs *BitSet) Format(f fmt.State, verb rune) {
+	if bs == nil {
+		f.Write([]byte("<nil>"))
+		return
+	}
+	var sb strings.Builder
+	switch verb {
+	case 'v':
+		if f.Flag('+') {
+			bs.writeTree(&sb)
+		} else {
+			bs.writeRanges(&sb, bs.compactionCount)
+		}
+	case 's':
+		bs.writeRanges(&sb, bs.compactionCount)
+	case 'd':
+		bs.writeRanges(&sb, 0)
+	case 'x':
+		bs.writeWords(&sb)
+	default:
+		fmt.Fprintf(&sb, "%%!%c(*sparse.BitSet=", verb)
+		bs.writeRanges(&sb, bs.compactionCount)
+		sb.WriteByte(')')
+	}
+	f.Write([]byte(sb.String()))
+}
+
+/*
+ *  Writes the set notation of the bit set. A run of set bits longer than
+ *  <i>compactionCount</i> is written as "first..last"; a zero count lists
+ *  every index.
+ */
+func (bs *BitSet) writeRanges(sb *strings.Builder, compactionCount int32) {
+	sb.WriteByte('{')
+	i := bs.NextSetBit(0)
+	/*  Loop so long as there is another bit to append to the String. */
+	for i >= 0 {
+		/*  Append that next bit */
+		sb.WriteString(strconv.Itoa(int(i)))
+		/*  Find the position of the next bit to show. */
+		j := bs.NextSetBit(i + 1)
+		if compactionCount > 0 {
+			/*  Give up if there is no next bit to show. */
+			if j < 0 {
+				break
+			}
+			/*  Find the next clear bit is after the current bit, i.e., i */
+			last := bs.NextClearBit(i)
+			/*  Compute the position of the next clear bit after the current
+			subsequence of set bits. */
+			if last < 0 {
+				last = math.MaxInt32
+			}
+			/*  If the subsequence is more than the specified bits long, then
+			collapse the subsequence into one entry in the String. */
+			if (i + compactionCount) < last {
+				sb.WriteString("..")
+				sb.WriteString(strconv.Itoa(int(last - 1)))
+				/*  Having accounted for a subsequence of bits that are all set,
+				recompute the label of the next bit to show. */
+				j = bs.NextSetBit(last)
+			}
+		}
+		/*  If there is another set bit, put a comma after the last entry in
+		the String.  */
+		if j >= 0 {
+			sb.WriteByte(',')
+		}
+		/*  Transfer to i the index of the next set bit. */
+		i = j
+	}
+	/*  Terminate the representational String. */
+	sb.WriteByte('}')
+}
+
+// writeWords writes every non-zero word as its word index and hex value.
+func (bs *BitSet) writeWords(sb *strings.Builder) {
+	g := bs.geo
+	sb.WriteByte('{')
+	first := true
+	for w1, a2 := range bs.bits {
+		for w2, a3 := range a2 {
+			for w3, word := range a3 {
+				if word == 0 {
+					continue
+				}
+				if !first {
+					sb.WriteByte(',')
+				}
+				first = false
+				w := (int32(w1) << g.shift1) + (int32(w2) << g.shift2) + int32(w3)
+				fmt.Fprintf(sb, "%d:%016x", w, word)
+			}
+		}
+	}
+	sb.WriteByte('}')
+}
+
+/*
+ *  Writes the structure of the bit set: the level1 array, and below it every
+ *  level2 area and level3 block that is present, with the number of entries
+ *  in use. Blocks that are present but all zero (not yet normalized away)
+ *  are shown as such.
+ */
+func (bs *BitSet) writeTree(sb *strings.Builder) {
+	g := bs.geo
+	areas := 0
+	for _, a2 := range bs.bits {
+		if a2 != nil {
+			areas++
+		}
+	}
+	fmt.Fprintf(sb, "level1: %d/%d areas, bitsLength=%d, compaction=%d\n",
+		areas, len(bs.bits), bs.bitsLength, bs.compactionCount)
+	for w1, a2 := range bs.bits {
+		if a2 == nil {
+			continue
+		}
+		blocks := 0
+		for _, a3 := range a2 {
+			if a3 != nil {
+				blocks++
+			}
+		}
+		fmt.Fprintf(sb, "  level2[%d]: %d/%d blocks, bits %d..%d\n",
+			w1, blocks, len(a2), int64(w1)*int64(g.unit), int64(w1+1)*int64(g.unit)-1)
+		for w2, a3 := range a2 {
+			if a3 == nil {
+				continue
+			}
+			words, cardinality := 0, 0
+			for _, word := range a3 {
+				if word != 0 {
+					words++
+					cardinality += bits.OnesCount64(word)
+				}
+			}
+			base := ((int32(w1) << g.shift1) + (int32(w2) << g.shift2)) << cShift3
+			fmt.Fprintf(sb, "    level3[%d][%d]: %d/%d words, %d bits set, base %d",
+				w1, w2, words, len(a3), cardinality, base)
+			if words == 0 {
+				sb.WriteString(" (zero block)")
+			}
+			sb.WriteByte('\n')
+		}
+	}
+}
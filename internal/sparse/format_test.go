@@ -0,0 +1,38 @@
+package sparse
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	bs := New()
+	bs.ToStringCompaction(2)
+	bs.SetRange(2, 5)
+	bs.Set(10)
+	var none *BitSet
+	for format, want := range map[string]string{
+		"%v": "{2..4,10}",
+		"%s": "{2..4,10}",
+		"%d": "{2,3,4,10}",
+		"%x": "{0:000000000000041c}",
+		"%q": "%!q(*sparse.BitSet={2..4,10})",
+	} {
+		if got := fmt.Sprintf(format, bs); got != want {
+			t.Errorf("%s: %q, want %q", format, got, want)
+		}
+	}
+	if got := fmt.Sprintf("%v", none); got != "<nil>" {
+		t.Errorf("nil set: %q", got)
+	}
+
+	bs.Set(3000)
+	bs.Clear(3000) //  Leaves a zero block until the statistics update
+	want := "level1: 1/1 areas, bitsLength=65536, compaction=2\n" +
+		"  level2[0]: 2/32 blocks, bits 0..65535\n" +
+		"    level3[0][0]: 1/32 words, 4 bits set, base 0\n" +
+		"    level3[0][1]: 0/32 words, 0 bits set, base 2048 (zero block)\n"
+	if got := fmt.Sprintf("%+v", bs); got != want {
+		t.Errorf("%%+v:\n%s\nwant:\n%s", got, want)
+	}
+}
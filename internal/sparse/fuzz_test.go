@@ -0,0 +1,236 @@
+package sparse
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// model is the reference a BitSet is checked against: the indexes of its
+// set bits.
+type model map[int32]bool
+
+func (m model) keys() []int32 {
+	keys := make([]int32, 0, len(m))
+	for i := range m {
+		keys = append(keys, i)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func (m model) clone() model {
+	c := make(model, len(m))
+	for i := range m {
+		c[i] = true
+	}
+	return c
+}
+
+// index decodes two bytes into an index near zero, in the middle of the
+// index space, or near its end.
+func index(hi, lo byte) int32 {
+	v := int32(hi&0x3f)<<8 | int32(lo)
+	switch hi >> 6 {
+	case 0:
+		return v
+	case 1:
+		return v * 64
+	case 2:
+		return v * 8191
+	default:
+		return math.MaxInt32 - 1 - v
+	}
+}
+
+// runOps decodes data into a sequence of operations on two bit sets, applies
+// them to the sets and to their models, and checks after every operation
+// that each set is valid and holds the bits of its model.
+func runOps(t *testing.T, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	geometries := []Geometry{DefaultGeometry, VerySparseGeometry, ClusteredGeometry}
+	g := geometries[int(data[0])%len(geometries)]
+	sets := [2]*BitSet{New(WithGeometry(g)), New(WithGeometry(g))}
+	models := [2]model{{}, {}}
+	for data = data[1:]; len(data) >= 4; data = data[4:] {
+		op, x := data[0], index(data[1], data[2])
+		y := x + int32(data[3])*37
+		if y < x {
+			y = math.MaxInt32
+		}
+		k := int(op>>4) & 1
+		a, b := sets[k], sets[1-k]
+		m, mb := models[k], models[1-k]
+		switch op % 16 {
+		case 0:
+			a.Set(x)
+			m[x] = true
+		case 1:
+			a.Clear(x)
+			delete(m, x)
+		case 2:
+			a.FlipBit(x)
+			if m[x] {
+				delete(m, x)
+			} else {
+				m[x] = true
+			}
+		case 3:
+			a.SetRange(x, y)
+			for i := x; i < y; i++ {
+				m[i] = true
+			}
+		case 4:
+			a.ClearRange(x, y)
+			for i := x; i < y; i++ {
+				delete(m, i)
+			}
+		case 5:
+			a.FlipRange(x, y)
+			for i := x; i < y; i++ {
+				if m[i] {
+					delete(m, i)
+				} else {
+					m[i] = true
+				}
+			}
+		case 6:
+			a.AndBitSet(b)
+			for i := range m {
+				if !mb[i] {
+					delete(m, i)
+				}
+			}
+		case 7:
+			a.OrBitSet(b)
+			for i := range mb {
+				m[i] = true
+			}
+		case 8:
+			a.XorBitSet(b)
+			for i := range mb {
+				if m[i] {
+					delete(m, i)
+				} else {
+					m[i] = true
+				}
+			}
+		case 9:
+			a.AndNotBitSet(b)
+			for i := range mb {
+				delete(m, i)
+			}
+		case 10:
+			sets[k] = a.Clone()
+		case 11:
+			a.Compact()
+		case 12:
+			a.SetRangeBit(x, y, op&0x20 != 0)
+			for i := x; i < y; i++ {
+				if op&0x20 != 0 {
+					m[i] = true
+				} else {
+					delete(m, i)
+				}
+			}
+		case 13:
+			sets[k] = a.GetBitSetFromRange(x, y)
+			for i := range m {
+				if i < x || i >= y {
+					delete(m, i)
+				}
+			}
+		case 14:
+			z := y
+			if z > x {
+				z-- //  The last index of the range, or x for an empty range
+			}
+			tx := a.Begin()
+			tx.Set(x)
+			tx.Clear(z)
+			/*  A read within the transaction sees the pending bits, and
+			leaves the blocks Rollback puts back. */
+			pending := m.clone()
+			pending[x] = true
+			delete(pending, z)
+			if !a.Equals(FromSorted(pending.keys(), WithGeometry(g))) {
+				t.Fatalf("%v within the transaction, want %v", a, pending.keys())
+			}
+			if op&0x20 != 0 {
+				tx.Rollback()
+			} else {
+				tx.Commit()
+				m[x] = true
+				delete(m, z)
+			}
+		case 15:
+			sets[k] = Xor(a, b)
+			models[k] = m.clone()
+			for i := range mb {
+				if m[i] {
+					delete(models[k], i)
+				} else {
+					models[k][i] = true
+				}
+			}
+		}
+		for n := range sets {
+			checkModel(t, sets[n], models[n])
+		}
+	}
+}
+
+func checkModel(t *testing.T, bs *BitSet, m model) {
+	t.Helper()
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bs.Cardinality(), int32(len(m)); got != want {
+		t.Fatalf("Cardinality() = %d, want %d", got, want)
+	}
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, want := bs.ToSlice(), m.keys()
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("bits %v, want %v", bs, want)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("bits %v, want %v", bs, want)
+	}
+	length := int32(0)
+	if len(want) != 0 {
+		length = want[len(want)-1] + 1
+		if bs.NextSetBit(0) != want[0] || bs.PreviousSetBit(math.MaxInt32-1) != want[len(want)-1] {
+			t.Fatalf("first/last set bit %d/%d, want %d/%d", bs.NextSetBit(0),
+				bs.PreviousSetBit(math.MaxInt32-1), want[0], want[len(want)-1])
+		}
+	}
+	if bs.Length() != length {
+		t.Fatalf("Length() = %d, want %d", bs.Length(), length)
+	}
+}
+
+func FuzzOps(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 5, 0})
+	f.Add([]byte{1, 3, 0, 10, 200, 0x13, 0, 50, 100, 6, 0, 0, 0})
+	f.Add([]byte{2, 3, 0xff, 0xff, 0, 5, 0xff, 0xf0, 255, 0x17, 0xc0, 0, 3, 8, 0, 0, 0})
+	f.Add([]byte{0, 3, 0x40, 0, 255, 0x11, 0x40, 1, 0, 14, 0x40, 1, 1, 11, 0, 0, 0})
+	f.Fuzz(runOps)
+}
+
+// TestRandomOps runs random operation sequences through runOps, so that a
+// plain go test covers more than the seed corpus of FuzzOps.
+func TestRandomOps(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 100; n++ {
+		data := make([]byte, 1+4*rng.Intn(24))
+		rng.Read(data)
+		runOps(t, data)
+	}
+}
@@ -0,0 +1,150 @@
+package sparse
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+/*Geometry ...
+ *  The widths of the level2 and level3 addresses of a bit set. A level3 block
+ *  holds 1 << Level3 words, and a level2 area holds 1 << Level2 blocks; the
+ *  level1 address takes the remaining bits of an index.
+ *  <p>
+ *  Small blocks suit very sparse sets, where most blocks hold a single bit
+ *  and the rest of the block is wasted. Large blocks suit clustered sets,
+ *  where whole runs of blocks are full and fewer, bigger blocks mean fewer
+ *  references to follow.
+ */
+type Geometry struct {
+	Level2 uint32
+	Level3 uint32
+}
+
+var (
+	// DefaultGeometry is the geometry of SparseBitSet: 32 words to a block,
+	// 32 blocks to an area.
+	DefaultGeometry = Geometry{Level2: cLevel2, Level3: cLevel3}
+	// VerySparseGeometry has 4-word blocks in 16-block areas, wasting less
+	// memory on sets where set bits are far apart.
+	VerySparseGeometry = Geometry{Level2: 4, Level3: 2}
+	// ClusteredGeometry has 256-word blocks in 64-block areas, for sets
+	// made of long runs of set bits.
+	ClusteredGeometry = Geometry{Level2: 6, Level3: 8}
+)
+
+// ErrGeometryMismatch is the error every GeometryError unwraps to.
+var ErrGeometryMismatch = errors.New("sparse: bit sets have different geometries")
+
+// GeometryError reports an operation between two bit sets of different
+// geometries.
+type GeometryError struct {
+	A, B Geometry
+}
+
+func (e *GeometryError) Error() string {
+	return fmt.Sprintf("%v: %v and %v", ErrGeometryMismatch, e.A, e.B)
+}
+
+func (e *GeometryError) Unwrap() error {
+	return ErrGeometryMismatch
+}
+
+func (g Geometry) String() string {
+	return fmt.Sprintf("level2=%d/level3=%d", g.Level2, g.Level3)
+}
+
+// Validate reports whether the geometry can address every index of a set.
+func (g Geometry) Validate() error {
+	if g.Level2 < 1 || g.Level3 < 1 {
+		return fmt.Errorf("sparse: invalid geometry %v: level2 and level3 must be at least 1", g)
+	}
+	if g.Level2+g.Level3 > cIndexSize-cLevel4-1 {
+		return fmt.Errorf("sparse: invalid geometry %v: level2 and level3 together may not exceed %d bits",
+			g, cIndexSize-cLevel4-1)
+	}
+	return nil
+}
+
+// WithGeometry makes a set use the given geometry instead of
+// DefaultGeometry. It panics if the geometry is not valid.
+func WithGeometry(g Geometry) Option {
+	l := layoutOf(g)
+	return func(bs *BitSet) {
+		bs.geo = l
+	}
+}
+
+// Geometry returns the geometry of the bit set.
+func (bs *BitSet) Geometry() Geometry {
+	return bs.geo.Geometry
+}
+
+/*
+ *  All the values derived from a geometry that the scanning code needs, in
+ *  the shape of the constants of the same names (see constants.go) that
+ *  describe the default geometry.
+ */
+type layout struct {
+	Geometry
+	level1      uint32
+	maxLength1  int32
+	length2     int32
+	length3     int32
+	shift1      uint32
+	shift2      uint32
+	mask2       int32
+	mask3       int32
+	unit        int32
+	length2Size int32
+	length3Size int32
+	/*  ZERO_BLOCK: a read-only all zero block, used when scanning in place of
+	a missing source block so that code does not have to test for nil. */
+	zeroBlock b1DimType
+	/*  Bytes taken by a level2 area and a level3 block. */
+	area2Bytes  int64
+	block3Bytes int64
+}
+
+var layouts sync.Map // Geometry -> *layout
+
+var defaultLayout = layoutOf(DefaultGeometry)
+
+// layoutOf returns the shared layout of a geometry, panicking if it is not
+// valid.
+func layoutOf(g Geometry) *layout {
+	if l, ok := layouts.Load(g); ok {
+		return l.(*layout)
+	}
+	if err := g.Validate(); err != nil {
+		panic(err)
+	}
+	l := &layout{
+		Geometry: g,
+		level1:   cIndexSize - g.Level2 - g.Level3 - cLevel4,
+		length2:  1 << g.Level2,
+		length3:  1 << g.Level3,
+		shift1:   g.Level2 + g.Level3,
+		shift2:   g.Level3,
+	}
+	l.maxLength1 = 1 << l.level1
+	l.mask2 = l.length2 - 1
+	l.mask3 = l.length3 - 1
+	l.unit = l.length2 * l.length3 * cLength4
+	l.length2Size = l.length2 - 1
+	l.length3Size = l.length3 - 1
+	l.zeroBlock = make(b1DimType, l.length3)
+	l.area2Bytes = int64(l.length2) * int64(unsafe.Sizeof(b1DimType(nil)))
+	l.block3Bytes = int64(l.length3) * int64(unsafe.Sizeof(wordType(0)))
+	actual, _ := layouts.LoadOrStore(g, l)
+	return actual.(*layout)
+}
+
+// sameGeometry panics with a *GeometryError if b is laid out differently.
+// The operations between two sets call it before they change anything.
+func (bs *BitSet) sameGeometry(b *BitSet) {
+	if b != nil && bs.geo != b.geo {
+		panic(&GeometryError{A: bs.geo.Geometry, B: b.geo.Geometry})
+	}
+}
@@ -0,0 +1,35 @@
+package sparse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGeometryMismatch(t *testing.T) {
+	other := New(WithGeometry(VerySparseGeometry))
+	other.Set(5)
+	ops := map[string]func(*BitSet, *BitSet) error{
+		"And":    (*BitSet).TryAndBitSet,
+		"AndNot": (*BitSet).TryAndNotBitSet,
+		"Or":     (*BitSet).TryOrBitSet,
+		"Xor":    (*BitSet).TryXorBitSet,
+	}
+	for name, op := range ops {
+		bs := New()
+		bs.Set(5)
+		bs.Set(1 << 20)
+		err := op(bs, other)
+		var ge *GeometryError
+		if !errors.As(err, &ge) || !errors.Is(err, ErrGeometryMismatch) ||
+			ge.A != DefaultGeometry || ge.B != VerySparseGeometry {
+			t.Errorf("%s: err = %v, want a GeometryError", name, err)
+		}
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{5, 1 << 20}) {
+			t.Errorf("%s: receiver changed to %v by the failed operation", name, got)
+		}
+		if err := bs.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
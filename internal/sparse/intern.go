@@ -0,0 +1,202 @@
+package sparse
+
+import "sync"
+
+/*Interner ...
+ *  A store of immutable level3 blocks shared by a family of bit sets. Sets
+ *  made with WithInterner(in) hand their blocks to it on Intern, and a block
+ *  with the same words as one already held is replaced by that one, so that
+ *  near-copies of a set (e.g. daily snapshots of the same key space) keep a
+ *  single copy of the blocks they have in common.
+ *  <p>
+ *  A shared block is never written to: a set about to change a shared block
+ *  first takes a private copy of it (copy on write). Shared blocks are owned
+ *  by the interner, and are not counted by the HeapBytes (nor the memory
+ *  limit) of the sets referring to them, while a private copy is.
+ *  <p>
+ *  A block is dropped from the interner when no set refers to it any more.
+ *  A set that is no longer needed should therefore be cleared with ClearAll,
+ *  otherwise the blocks it shares are kept alive by the interner.
+ *  <p>
+ *  The interner may be used by sets in different goroutines; each set is, as
+ *  ever, to be used by one goroutine at a time.
+ */
+type Interner struct {
+	mu sync.Mutex
+	/*  The shared blocks, by hash of their words. */
+	blocks map[uint64][]*internedBlock
+	/*  The number of shared blocks, and of references to them from sets. */
+	physical int
+	logical  int
+}
+
+type internedBlock struct {
+	words b1DimType
+	refs  int
+}
+
+// NewInterner returns an empty interner.
+func NewInterner() *Interner {
+	return &Interner{blocks: make(map[uint64][]*internedBlock)}
+}
+
+// WithInterner makes a set one of the family of sets sharing blocks through
+// the interner. Sets derived from it (by GetBitSetFromRange, clone, ...)
+// belong to the same family.
+func WithInterner(in *Interner) Option {
+	return func(bs *BitSet) {
+		bs.interner = in
+	}
+}
+
+// Blocks returns the number of references to shared blocks held by all the
+// sets of the family (the logical count) and the number of distinct blocks
+// the interner keeps for them (the physical count).
+func (in *Interner) Blocks() (logical, physical int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.logical, in.physical
+}
+
+/*
+ *  Returns the shared block with the same words as a3, taking a3 itself as
+ *  the shared block if there is none yet. Either way the caller gives up a3.
+ */
+func (in *Interner) intern(a3 b1DimType) b1DimType {
+	h := hashBlock(a3)
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.logical++
+	for _, e := range in.blocks[h] {
+		if equalBlocks(e.words, a3) {
+			e.refs++
+			return e.words
+		}
+	}
+	in.blocks[h] = append(in.blocks[h], &internedBlock{words: a3, refs: 1})
+	in.physical++
+	return a3
+}
+
+// release drops a reference to the shared block a3.
+func (in *Interner) release(a3 b1DimType) {
+	h := hashBlock(a3)
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	bucket := in.blocks[h]
+	for k, e := range bucket {
+		if &e.words[0] != &a3[0] {
+			continue
+		}
+		in.logical--
+		if e.refs--; e.refs == 0 {
+			bucket[k] = bucket[len(bucket)-1]
+			bucket[len(bucket)-1] = nil
+			if bucket = bucket[:len(bucket)-1]; len(bucket) == 0 {
+				delete(in.blocks, h)
+			} else {
+				in.blocks[h] = bucket
+			}
+			in.physical--
+		}
+		return
+	}
+	panic("sparse: releasing a block the interner does not hold")
+}
+
+func hashBlock(a3 b1DimType) uint64 {
+	h := uint64(14695981039346656037) //  FNV-1a offset basis
+	for _, word := range a3 {
+		h = (h ^ uint64(word)) * 1099511628211
+	}
+	return h
+}
+
+func equalBlocks(a3, b3 b1DimType) bool {
+	if len(a3) != len(b3) {
+		return false
+	}
+	for w3 := range a3 {
+		if a3[w3] != b3[w3] {
+			return false
+		}
+	}
+	return true
+}
+
+/*Intern ...
+ *  Replaces every private non-zero level3 block of the set by the shared
+ *  block with the same words, giving the block to the interner if it holds
+ *  no such block yet. Does nothing for a set made without WithInterner. Must
+ *  not be called while a transaction on the set is open.
+ */
+func (bs *BitSet) Intern() {
+	in := bs.interner
+	if in == nil {
+		return
+	}
+	bs.statisticsUpdate() //  Drops the all zero blocks
+	for _, a2 := range bs.bits {
+		for w2, a3 := range a2 {
+			if a3 == nil || bs.isShared(a3) || isZeroBlock(a3) {
+				continue
+			}
+			s3 := in.intern(a3)
+			if &s3[0] != &a3[0] {
+				bs.pool.put(a3) //  An equal block was already shared
+			}
+			a2[w2] = s3
+			bs.heapBytes -= bs.geo.block3Bytes
+			if bs.shared == nil {
+				bs.shared = make(map[*wordType]int32)
+			}
+			bs.shared[&s3[0]]++
+		}
+	}
+}
+
+// isShared reports whether a3 is a block shared through the interner.
+func (bs *BitSet) isShared(a3 b1DimType) bool {
+	if len(bs.shared) == 0 {
+		return false
+	}
+	_, ok := bs.shared[&a3[0]]
+	return ok
+}
+
+/*
+ *  Gives up a reference to the shared block a3, which has already been
+ *  removed from the set.
+ */
+func (bs *BitSet) releaseShared(a3 b1DimType) {
+	key := &a3[0]
+	if bs.shared[key]--; bs.shared[key] == 0 {
+		delete(bs.shared, key)
+	}
+	bs.interner.release(a3)
+}
+
+/*
+ *  Returns the block a2[w2] (nil if there is none) ready to be written to:
+ *  a shared block is first replaced by a private copy of it.
+ */
+func (bs *BitSet) writable(a2 b2DimType, w2 int32) b1DimType {
+	a3 := a2[w2]
+	if a3 == nil || !bs.isShared(a3) {
+		return a3
+	}
+	c3 := bs.newBlock()
+	copy(c3, a3)
+	a2[w2] = c3
+	bs.releaseShared(a3)
+	return c3
+}
+
+// sharedBlocks returns the number of distinct shared blocks the set refers
+// to, and the number of its references to them.
+func (bs *BitSet) sharedBlocks() (distinct, refs int32) {
+	for _, n := range bs.shared {
+		refs += n
+	}
+	return int32(len(bs.shared)), refs
+}
@@ -0,0 +1,77 @@
+package sparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func checkBlocks(t *testing.T, in *Interner, logical, physical int) {
+	t.Helper()
+	if l, p := in.Blocks(); l != logical || p != physical {
+		t.Errorf("Blocks() = %d, %d, want %d, %d", l, p, logical, physical)
+	}
+}
+
+func TestInterner(t *testing.T) {
+	g := defaultLayout
+	in := NewInterner()
+	a := New(WithInterner(in))
+	b := New(WithInterner(in))
+	for _, i := range []int32{5, 3000, 1 << 20} {
+		a.Set(i)
+		b.Set(i)
+	}
+	b.Set(5000) //  A block of its own
+	heap := a.HeapBytes()
+
+	a.Intern()
+	b.Intern()
+	checkBlocks(t, in, 7, 4)
+	if a.HeapBytes() != heap-3*g.block3Bytes {
+		t.Errorf("HeapBytes() = %d after Intern, want %d", a.HeapBytes(), heap-3*g.block3Bytes)
+	}
+	var values [Statistics_Values_Length]string
+	a.Statistics(values[:])
+	if values[Level3_blocks] != "3" || values[Level3_physical_blocks] != "3" {
+		t.Errorf("%s level3 blocks, %s physical", values[Level3_blocks], values[Level3_physical_blocks])
+	}
+
+	/*  Writing to a shared block writes to a private copy of it. */
+	a.Set(6)
+	if b.GetBit(6) || !a.GetBit(6) {
+		t.Errorf("Set(6) on one set gave %v and %v", a, b)
+	}
+	checkBlocks(t, in, 6, 4)
+	if a.HeapBytes() != heap-2*g.block3Bytes {
+		t.Errorf("HeapBytes() = %d after a copy on write, want %d", a.HeapBytes(), heap-2*g.block3Bytes)
+	}
+	a.Clear(6)
+	a.Intern()
+	checkBlocks(t, in, 7, 4)
+	b.Clear(5000)
+	b.Intern()
+	checkBlocks(t, in, 6, 3)
+	if !a.Equals(b) || !reflect.DeepEqual(a.ToSlice(), []int32{5, 3000, 1 << 20}) {
+		t.Errorf("sets %v and %v after the writes", a, b)
+	}
+	for _, s := range []*BitSet{a, b} {
+		if err := s.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	/*  The blocks are dropped with the last set referring to them. */
+	a.ClearAll()
+	checkBlocks(t, in, 3, 3)
+	b.ClearAll()
+	checkBlocks(t, in, 0, 0)
+
+	/*  Without an interner, Intern does nothing. */
+	c := New()
+	c.Set(5)
+	heap = c.HeapBytes()
+	c.Intern()
+	if c.HeapBytes() != heap {
+		t.Errorf("Intern without an interner changed HeapBytes to %d", c.HeapBytes())
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math"
 	"math/bits"
-	"reflect"
 )
 
 func isZeroBlock(a3 b1DimType) bool {
@@ -29,10 +28,12 @@ func highestOneBit(x int32) (result int32) {
 	return
 }
 
-func (bs *BitSet) resize(index int32) {
-	/*  Find an array size that is a power of two that is as least as large
-	enough to contain the index requested. */
-	w1 := int32((index >> cShift3) >> cShift3)
+/*
+ *  Finds an array size that is a power of two that is as least as large
+ *  enough to contain the index requested.
+ */
+func (g *layout) length1For(index int32) int32 {
+	w1 := int32((index >> cShift3) >> g.shift1)
 	newSize := int32(highestOneBit(w1))
 	if newSize == 0 {
 		newSize = 1
@@ -40,9 +41,15 @@ func (bs *BitSet) resize(index int32) {
 	if w1 >= newSize {
 		newSize <<= 1
 	}
-	if newSize > cMaxLength1 {
-		newSize = cMaxLength1
+	if newSize > g.maxLength1 {
+		newSize = g.maxLength1
 	}
+	return newSize
+}
+
+func (bs *BitSet) resize(index int32) {
+	g := bs.geo
+	newSize := g.length1For(index)
 
 	aLength1 := int32(0)
 	if bs.bits != nil {
@@ -51,16 +58,22 @@ func (bs *BitSet) resize(index int32) {
 
 	if newSize != aLength1 || bs.bits == nil {
 		// only if the size needs to be changed
+		growth := int64(newSize-aLength1) * cLevel1EntryBytes
+		bs.reserve(growth)
 		temp := make(b3DimType, newSize) //  Get the new array
 		if aLength1 != 0 {
-			/*  If it exists, copy old array to the new array. */
+			/*  If it exists, copy old array to the new array. The areas
+			move to the new array, so they are not released. */
 			copy(temp, bs.bits)
-			bs.nullify(0) //  Don't leave unused pointers around. */
+			for w := range bs.bits {
+				bs.bits[w] = nil //  Don't leave unused pointers around. */
+			}
 		}
+		bs.heapBytes += growth
 		bs.bits = temp                //  Set new array as the set array
 		bs.bitsLength = math.MaxInt32 //  Index of last possible bit, plus one.
-		if newSize != cMaxLength1 {
-			bs.bitsLength = newSize * cUnit
+		if newSize != g.maxLength1 {
+			bs.bitsLength = newSize * g.unit
 		}
 	}
 }
@@ -104,7 +117,7 @@ func (bs *BitSet) nullify(start int32) {
 	aLength := int32(len(bs.bits))
 	if start < aLength {
 		for w := start; w != aLength; w++ {
-			bs.bits[w] = nil
+			bs.dropArea(w)
 		}
 		bs.cache.hash = 0 //  Invalidate size, etc., values
 	}
@@ -116,7 +129,7 @@ func (bs *BitSet) nullify(start int32) {
  * @since       1.6
  */
 func (bs *BitSet) constructorHelper() {
-	bs.spare = make(b1DimType, cLength3)
+	bs.spare = bs.newBlock()
 }
 
 /**
@@ -147,7 +160,6 @@ type strateger interface {
 }
 
 func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
-
 	/*  This method has been assessed as having a McCabe cyclomatic
 	complexity of 47 (i.e., impossibly high). However, given that this
 	method incorporates all the set scanning logic for all methods
@@ -158,6 +170,8 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 	proceeds step-wise, and each sub-section piece is reasonably
 	straight-forward. Nevertheless, the number of paths is high, and
 	caution is advised in attempting to correct anything. */
+	bs.sameGeometry(b)
+	g := bs.geo
 
 	/*  Do whatever the strategy needs to get started, and do whatever initial
 	checking is needed--fail here if needed before much else is done. */
@@ -179,6 +193,10 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 	falseOpValueEqFalse := (properties & cFalseOpValueEqFalse) != 0
 	valueOpFalseEqFalse := (properties & cValueOpFalseEqFalse) != 0
 	valueOpFalseEqValue := (properties & cValueOpFalseEqValue) != 0
+	readsOnly := (properties & cReadsOnly) != 0
+	/*  A read within a transaction leaves the blocks and areas in place, as
+	Rollback puts the saved blocks back into them. */
+	normalize := !(readsOnly && bs.txn != nil)
 
 	/*  Index of the current word, and mask for the first word,
 	to be processed in the bit set. */
@@ -190,7 +208,7 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 	to be processed in the bit set. */
 	v := int32((j - 1)) >> cShift3
 	// final long vm = ~0L >>> -j;
-	vm := ^wordType(^uint(0) << remainderOf64(j))
+	vm := ^wordType(0) >> (uint(-j) & uint(cLength4Size))
 
 	/*  Set up the two bit arrays (if the second exists), and their
 	corresponding lengths (if any). */
@@ -207,13 +225,13 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 
 	/*  Calculate the initial values of the parts of the words addresses,
 	as well as the location of the final block to be processed.  */
-	u1 := u >> cShift1
-	u2 := (u >> cShift2) & cMask2
-	u3 := u & cMask3
-	v1 := v >> cShift1
-	v2 := (v >> cShift2) & cMask2
-	v3 := v & cMask3
-	lastA3Block := (v1 << cLevel2) + v2
+	u1 := u >> g.shift1
+	u2 := (u >> g.shift2) & g.mask2
+	u3 := u & g.mask3
+	v1 := v >> g.shift1
+	v2 := (v >> g.shift2) & g.mask2
+	v3 := v & g.mask3
+	lastA3Block := (v1 << g.Level2) + v2
 
 	/*  Initialize the local copies of the counts of blocks and areas; and
 	whether there is a partial first block.  */
@@ -252,11 +270,11 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 				!haveA2 && falseOpValueEqFalse ||
 				!haveB2 && valueOpFalseEqFalse) {
 			//nested if!
-			if u1 < aLength1 {
-				a1[u1] = nil
+			if u1 < aLength1 && normalize {
+				bs.dropArea(u1)
 			}
 		} else {
-			limit2 := cLength2
+			limit2 := g.length2
 			if u1 == v1 {
 				limit2 = int32(v2 + 1)
 			}
@@ -284,20 +302,20 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 					haveB3 = haveB3 && b3 != nil
 				}
 
-				a3Block := (u1 << cLevel2) + u2
+				a3Block := (u1 << g.Level2) + u2
 				notLastBlock := lastA3Block != a3Block
 
 				/*  Handling of level 3 empty areas: determined by the
 				properties of the strategy. */
 				if (!haveA3 && !haveB3 && falseOpFalseEqFalse || !haveA3 && falseOpValueEqFalse || !haveB3 && valueOpFalseEqFalse) && notFirstBlock && notLastBlock {
 					/*  Do not need level3 block, so remove it, and move on. */
-					if haveA2 {
-						a2[u2] = nil
+					if haveA2 && normalize {
+						bs.dropBlock(a2, u2)
 					}
 				} else {
 					/*  So what is needed is the level3 block. */
-					base3 := a3Block << cShift2
-					limit3 := cLength3
+					base3 := a3Block << g.shift2
+					limit3 := g.length3
 					if !notLastBlock {
 						limit3 = int32(v3)
 					}
@@ -306,14 +324,20 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 						a3IsSpare = true
 					}
 					if !haveB3 {
-						b3 = iZeroBlock
+						b3 = g.zeroBlock
+					}
+					if haveA3 && !readsOnly &&
+						!(valueOpFalseEqValue && !haveB3 && notFirstBlock && notLastBlock) {
+						/*  The strategy is to write to the block, so a block
+						shared through the interner is copied first. */
+						a3 = bs.writable(a2, u2)
 					}
 					isZero := false
 					if notFirstBlock && notLastBlock {
 						if valueOpFalseEqValue && !haveB3 {
 							isZero = isZeroBlock(a3)
 						} else {
-							isZero = op.block(base3, 0, cLength3, a3, b3)
+							isZero = op.block(base3, 0, g.length3, a3, b3)
 						}
 					} else {
 						/*  Partial block to process. */
@@ -321,7 +345,7 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 							/*  By implication, this is the last block */
 							isZero = op.block(base3, 0, limit3, a3, b3)
 							//  Do the whole words
-							isZero = isZero && op.word(base3, limit3, a3, b3, vm)
+							isZero = op.word(base3, limit3, a3, b3, vm) && isZero
 							//  And then the final word
 						} else {
 							// u, v are correct if first block
@@ -331,10 +355,10 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 								// Scan starts in this a3 block
 								isZero = op.word(base3, u3, a3, b3, um)
 								//  First word
-								isZero = isZero && op.block(base3, u3+1, limit3, a3, b3)
+								isZero = op.block(base3, u3+1, limit3, a3, b3) && isZero
 								//  Remainder of full words in block
-								if limit3 != cLength3 {
-									isZero = isZero && op.word(base3, limit3, a3, b3, vm)
+								if limit3 != g.length3 {
+									isZero = op.word(base3, limit3, a3, b3, vm) && isZero
 								}
 								//  If there is a partial word left
 							}
@@ -350,13 +374,14 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 						// nested if!
 						/*  If there is an level 2 area make the entry for this
 						level3 block be a null (i.e., remove any a3 block ). */
-						if haveA2 {
-							a2[u2] = nil
+						if haveA2 && normalize {
+							bs.dropBlock(a2, u2)
 						}
 					} else {
 						/*  If the a3 block used was the spare block, put it
 						into current level2 area; get a new spare block. */
 						if a3IsSpare {
+							bs.reserveAdoption(i, a2 == nil)
 							if int32(i) >= bs.bitsLength { //Check that the set is large
 								//  enough to take the new block
 								bs.resize(i) //  Make it large enough
@@ -364,13 +389,13 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 								aLength1 = int32(len(a1))
 							}
 							if a2 == nil { //  Ensure a level 2 area
-								a2 = make(b2DimType, cLength2)
+								a2 = bs.newArea()
 								a1[u1] = a2
 								haveA2 = true //  Ensure know level2 not empty
 							}
 							a2[u2] = a3 //  Insert the level3 block
 							a3IsSpare = false
-							bs.spare = make(b1DimType, cLength3) // Replace the spare
+							bs.spare = bs.newBlock() // Replace the spare
 
 						}
 						a3CountLocal++ // Count the level 3 block
@@ -382,17 +407,21 @@ func (bs *BitSet) setScanner(i, j int32, b *BitSet, op strateger) {
 			} /* end while ( u2 != limit2 ) */
 			/*  If the loop finishes without completing the level 2, it may
 			be left with a reference but still be all null--this is OK. */
-			if u2 == cLength2 && a2IsEmpty && u1 < aLength1 {
-				a1[u1] = nil
+			if u2 == g.length2 && a2IsEmpty && u1 < aLength1 {
+				if normalize {
+					bs.dropArea(u1)
+				}
 			} else {
 				a2CountLocal++ //  Count level 2 areas
 			}
 		}
 		/*  Advance the value of u based on what happened. */
 		u1++
-		u = (u1 << cShift1)
+		u = (u1 << g.shift1)
 		i = u << cShift3
 		u2 = 0 //  u3 = 0
+		/*  The next area is scanned from its start, so may be found empty. */
+		a2IsEmpty = true
 		//  Compute next word and bit index
 		if i < 0 {
 			i = math.MaxInt32 //  Don't go over the end
@@ -435,11 +464,14 @@ func (bs *BitSet) statisticsUpdate() {
  * @return      a clone of this SparseBitSet
  * @since       1.6
  */
+//Clone ...
 //public SparseBitSet clone()
-func (bs *BitSet) clone() (result *BitSet) {
+func (bs *BitSet) Clone() (result *BitSet) {
 	result = new(BitSet)
-
-	reflect.Copy(reflect.ValueOf(*result), reflect.ValueOf(*bs))
+	*result = *bs
+	result.heapBytes = 0
+	result.shared = nil
+	result.txn = nil
 
 	/*  Clear out the shallow copy of the set array (which contains just
 	copies of the references from this set), and then replace these
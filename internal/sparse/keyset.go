@@ -0,0 +1,293 @@
+package sparse
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// ErrDictionaryMismatch is returned by the KeySet operations given sets that
+// do not share a dictionary, the ordinals of which mean different keys.
+var ErrDictionaryMismatch = errors.New("sparse: key sets do not share a dictionary")
+
+/*Dictionary ...
+ *  A dictionary numbers keys of any comparable type with dense int32
+ *  ordinals, in the order the keys are first seen, so that sets of keys can
+ *  be kept as bit sets of ordinals (see KeySet). A key keeps its ordinal for
+ *  the life of the dictionary, also when no set holds it anymore.
+ *  <p>
+ *  A dictionary may be shared by key sets used from several goroutines. It
+ *  is persisted with MarshalBinary (gob) or MarshalJSON, which both write the
+ *  keys in ordinal order; the key type must be encodable by them, e.g. a
+ *  string, a number or a struct of exported fields for a composite key.
+ */
+type Dictionary[K comparable] struct {
+	mu       sync.RWMutex
+	ordinals map[K]int32
+	keys     []K
+}
+
+// NewDictionary creates an empty dictionary.
+func NewDictionary[K comparable]() *Dictionary[K] {
+	return &Dictionary[K]{
+		ordinals: make(map[K]int32),
+	}
+}
+
+// Len returns the number of keys in the dictionary.
+func (d *Dictionary[K]) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.keys)
+}
+
+// Ordinal returns the ordinal of key, and false if the key is not in the
+// dictionary.
+func (d *Dictionary[K]) Ordinal(key K) (int32, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	i, ok := d.ordinals[key]
+	return i, ok
+}
+
+/*Key ...
+ *  Returns the key of the given ordinal.
+ *
+ * @exception   IndexOutOfBoundsException if no key has the ordinal
+ */
+func (d *Dictionary[K]) Key(i int32) K {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if i < 0 || int(i) >= len(d.keys) {
+		panic(fmt.Sprintf("IndexOutOfBoundsException(i=%v)", i))
+	}
+	return d.keys[i]
+}
+
+/*
+ *  Returns the ordinal of key, adding the key to the dictionary if it is not
+ *  there yet.
+ */
+func (d *Dictionary[K]) add(key K) int32 {
+	if i, ok := d.Ordinal(key); ok {
+		return i
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i, ok := d.ordinals[key]; ok {
+		return i //  Added by another goroutine meanwhile
+	}
+	/*  Integer.MAX_VALUE is not a valid bit index, hence not an ordinal. */
+	if len(d.keys) >= math.MaxInt32 {
+		panic(fmt.Sprintf("IndexOutOfBoundsException(i=%v)", len(d.keys)))
+	}
+	i := int32(len(d.keys))
+	d.ordinals[key] = i
+	d.keys = append(d.keys, key)
+	return i
+}
+
+// load replaces the keys of an empty dictionary with the decoded ones.
+func (d *Dictionary[K]) load(keys []K) error {
+	ordinals := make(map[K]int32, len(keys))
+	for i, key := range keys {
+		if _, ok := ordinals[key]; ok {
+			return fmt.Errorf("sparse: duplicate key %v at ordinal %d", key, i)
+		}
+		ordinals[key] = int32(i)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.keys) != 0 {
+		/*  The sets on this dictionary would change meaning. */
+		return errors.New("sparse: dictionary to unmarshal into is not empty")
+	}
+	d.ordinals, d.keys = ordinals, keys
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, writing the keys in
+// ordinal order with encoding/gob.
+func (d *Dictionary[K]) MarshalBinary() ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(d.keys); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. The dictionary
+// must be empty.
+func (d *Dictionary[K]) UnmarshalBinary(data []byte) error {
+	var keys []K
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&keys); err != nil {
+		return err
+	}
+	return d.load(keys)
+}
+
+// MarshalJSON implements json.Marshaler, writing the keys in ordinal order
+// as a JSON array.
+func (d *Dictionary[K]) MarshalJSON() ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.keys == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(d.keys)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The dictionary must be empty.
+func (d *Dictionary[K]) UnmarshalJSON(data []byte) error {
+	var keys []K
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	return d.load(keys)
+}
+
+/*KeySet ...
+ *  A set of keys of any comparable type, kept as the bit set of their
+ *  ordinals in a dictionary that may be shared by many sets. Sets sharing a
+ *  dictionary are combined by Union, Intersect and Difference at the cost of
+ *  the bit set operations, without looking at a single key; e.g. the primary
+ *  keys of a source table missing from its target are
+ *  <pre>
+ *      missing, err := source.Difference(target)
+ *  </pre>
+ *  The membership of a set is persisted with MarshalBinary, and is only
+ *  meaningful together with its dictionary.
+ */
+type KeySet[K comparable] struct {
+	dict *Dictionary[K]
+	bits *BitSet
+}
+
+// NewKeySet creates an empty set of keys numbered by dict, with a bit set
+// configured by the given options.
+func NewKeySet[K comparable](dict *Dictionary[K], options ...Option) *KeySet[K] {
+	return &KeySet[K]{
+		dict: dict,
+		bits: New(options...),
+	}
+}
+
+// Dictionary returns the dictionary numbering the keys of the set.
+func (ks *KeySet[K]) Dictionary() *Dictionary[K] {
+	return ks.dict
+}
+
+// Bits returns the bit set of the ordinals of the keys in the set. The bit
+// set is owned by the key set.
+func (ks *KeySet[K]) Bits() *BitSet {
+	return ks.bits
+}
+
+// Add adds the keys to the set, and to the dictionary where not there yet.
+func (ks *KeySet[K]) Add(keys ...K) {
+	for _, key := range keys {
+		ks.bits.Set(ks.dict.add(key))
+	}
+}
+
+// Remove removes the keys from the set. The dictionary is not changed.
+func (ks *KeySet[K]) Remove(keys ...K) {
+	for _, key := range keys {
+		if i, ok := ks.dict.Ordinal(key); ok {
+			ks.bits.Clear(i)
+		}
+	}
+}
+
+// Contains reports whether key is in the set.
+func (ks *KeySet[K]) Contains(key K) bool {
+	i, ok := ks.dict.Ordinal(key)
+	return ok && ks.bits.GetBit(i)
+}
+
+// Len returns the number of keys in the set.
+func (ks *KeySet[K]) Len() int {
+	return int(ks.bits.Cardinality())
+}
+
+// Keys returns the keys of the set in ordinal order, that is in the order
+// they were first added to the dictionary.
+func (ks *KeySet[K]) Keys() []K {
+	ordinals := ks.bits.ToSlice()
+	keys := make([]K, len(ordinals))
+	ks.dict.mu.RLock()
+	defer ks.dict.mu.RUnlock()
+	for k, i := range ordinals {
+		keys[k] = ks.dict.keys[i]
+	}
+	return keys
+}
+
+// Clone returns a copy of the set on the same dictionary.
+func (ks *KeySet[K]) Clone() *KeySet[K] {
+	return &KeySet[K]{
+		dict: ks.dict,
+		bits: ks.bits.Clone(),
+	}
+}
+
+/*
+ *  Returns a copy of the set combined with other by the given operation, or
+ *  the error of the operation.
+ */
+func (ks *KeySet[K]) combine(other *KeySet[K], op func(bs, b *BitSet) error) (*KeySet[K], error) {
+	if ks.dict != other.dict {
+		return nil, ErrDictionaryMismatch
+	}
+	result := ks.Clone()
+	if err := op(result.bits, other.bits); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Union returns a new set of the keys in either set. Both sets must share a
+// dictionary, or ErrDictionaryMismatch is returned; the error of the bit set
+// operation (see TryOrBitSet) is returned as is.
+func (ks *KeySet[K]) Union(other *KeySet[K]) (*KeySet[K], error) {
+	return ks.combine(other, (*BitSet).TryOrBitSet)
+}
+
+// Intersect returns a new set of the keys in both sets, with the errors of
+// Union.
+func (ks *KeySet[K]) Intersect(other *KeySet[K]) (*KeySet[K], error) {
+	return ks.combine(other, (*BitSet).TryAndBitSet)
+}
+
+// Difference returns a new set of the keys in this set and not in other,
+// with the errors of Union.
+func (ks *KeySet[K]) Difference(other *KeySet[K]) (*KeySet[K], error) {
+	return ks.combine(other, (*BitSet).TryAndNotBitSet)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, writing the ordinals of
+// the keys in the binary form of BitSet.MarshalBinary.
+func (ks *KeySet[K]) MarshalBinary() ([]byte, error) {
+	return ks.bits.MarshalBinary()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. The set must have
+// been created on the dictionary it was marshaled with, loaded first; an
+// ordinal the dictionary has no key for is an error.
+func (ks *KeySet[K]) UnmarshalBinary(data []byte) error {
+	bits := New(ks.bits.options()...)
+	if err := bits.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if n := bits.Length(); n > int32(ks.dict.Len()) {
+		return fmt.Errorf("sparse: ordinal %d not in the dictionary of %d keys", n-1, ks.dict.Len())
+	}
+	ks.bits = bits
+	return nil
+}
@@ -0,0 +1,102 @@
+package sparse
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type compositeKey struct {
+	Schema string
+	ID     int64
+}
+
+func TestKeySetOperations(t *testing.T) {
+	dict := NewDictionary[compositeKey]()
+	source, target := NewKeySet(dict), NewKeySet(dict)
+	for id := int64(0); id < 10; id++ {
+		source.Add(compositeKey{"A", id})
+	}
+	for id := int64(5); id < 15; id++ {
+		target.Add(compositeKey{"A", id})
+	}
+	target.Remove(compositeKey{"A", 7}, compositeKey{"B", 1})
+
+	tests := []struct {
+		name string
+		op   func(*KeySet[compositeKey]) (*KeySet[compositeKey], error)
+		want []int64
+	}{
+		{"Union", source.Union, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}},
+		{"Intersect", source.Intersect, []int64{5, 6, 8, 9}},
+		{"Difference", source.Difference, []int64{0, 1, 2, 3, 4, 7}},
+	}
+	for _, tt := range tests {
+		result, err := tt.op(target)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []int64
+		for _, key := range result.Keys() {
+			got = append(got, key.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if source.Len() != 10 || !source.Contains(compositeKey{"A", 7}) || target.Contains(compositeKey{"A", 7}) {
+		t.Errorf("operands changed: source %v, target %v", source.Keys(), target.Keys())
+	}
+
+	other := NewKeySet(NewDictionary[compositeKey]())
+	if _, err := source.Union(other); !errors.Is(err, ErrDictionaryMismatch) {
+		t.Errorf("Union on another dictionary: err = %v, want ErrDictionaryMismatch", err)
+	}
+}
+
+func TestDictionaryPersistence(t *testing.T) {
+	dict := NewDictionary[string]()
+	ks := NewKeySet(dict)
+	ks.Add("c", "a", "b")
+	ks.Remove("a")
+	members, err := ks.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoders := map[string]struct {
+		marshal   func(*Dictionary[string]) ([]byte, error)
+		unmarshal func(*Dictionary[string], []byte) error
+	}{
+		"binary": {(*Dictionary[string]).MarshalBinary, (*Dictionary[string]).UnmarshalBinary},
+		"json":   {func(d *Dictionary[string]) ([]byte, error) { return json.Marshal(d) }, (*Dictionary[string]).UnmarshalJSON},
+	}
+	for name, enc := range encoders {
+		data, err := enc.marshal(dict)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		loaded := NewDictionary[string]()
+		if err := enc.unmarshal(loaded, data); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if i, ok := loaded.Ordinal("b"); !ok || i != 2 || loaded.Key(0) != "c" {
+			t.Errorf("%s: ordinal of b = %d, %v; key 0 = %q", name, i, ok, loaded.Key(0))
+		}
+		restored := NewKeySet(loaded)
+		if err := restored.UnmarshalBinary(members); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := restored.Keys(); !reflect.DeepEqual(got, []string{"c", "b"}) {
+			t.Errorf("%s: restored keys %v, want [c b]", name, got)
+		}
+		if err := enc.unmarshal(loaded, data); err == nil {
+			t.Errorf("%s: unmarshal into a non-empty dictionary succeeded", name)
+		}
+	}
+
+	if err := NewKeySet(NewDictionary[string]()).UnmarshalBinary(members); err == nil {
+		t.Error("UnmarshalBinary with ordinals missing from the dictionary succeeded")
+	}
+}
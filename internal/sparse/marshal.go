@@ -0,0 +1,291 @@
+package sparse
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Encoding selects the form in which MarshalText and MarshalJSON write a
+// bit set. UnmarshalText and UnmarshalJSON accept either form.
+type Encoding int
+
+const (
+	// RangeEncoding is the set notation of String, e.g. "{2..4,10}".
+	RangeEncoding Encoding = iota
+	// Base64Encoding is the binary form of MarshalBinary in standard base64,
+	// shorter than the set notation for large sets with few long runs.
+	Base64Encoding
+)
+
+// WithEncoding selects the form MarshalText and MarshalJSON write the set in.
+func WithEncoding(e Encoding) Option {
+	return func(bs *BitSet) {
+		bs.encoding = e
+	}
+}
+
+// ErrInvalidEncoding is the error every ParseError unwraps to.
+var ErrInvalidEncoding = errors.New("sparse: invalid bit set encoding")
+
+// ParseError reports malformed input to UnmarshalText, UnmarshalJSON or
+// UnmarshalBinary, or an index in it outside 0..math.MaxInt32-1.
+type ParseError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v: %s at offset %d", ErrInvalidEncoding, e.Msg, e.Offset)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidEncoding
+}
+
+/*
+ *  The binary form: a version byte, the number of non-zero words, and for
+ *  each word, in increasing order of word index, the gap from the previous
+ *  word index (less one, the first counting from -1) as a uvarint followed by
+ *  the word as 8 bytes little endian.
+ */
+const cBinaryVersion byte = 1
+
+/*
+ *  The word index of the last word of the set, the highest bit of which
+ *  would be the bit Integer.MAX_VALUE, which may not be set.
+ */
+const cMaxWord = int32(math.MaxInt32 >> cShift3)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (bs *BitSet) MarshalBinary() ([]byte, error) {
+	g := bs.geo
+	var scratch [binary.MaxVarintLen64]byte
+	buf := []byte{cBinaryVersion}
+	buf = append(buf, scratch[:binary.PutUvarint(scratch[:], uint64(bs.countWords()))]...)
+	prev := int32(-1)
+	for w1, a2 := range bs.bits {
+		for w2, a3 := range a2 {
+			for w3, word := range a3 {
+				if word == 0 {
+					continue
+				}
+				w := (int32(w1) << g.shift1) + (int32(w2) << g.shift2) + int32(w3)
+				buf = append(buf, scratch[:binary.PutUvarint(scratch[:], uint64(w-prev-1))]...)
+				binary.LittleEndian.PutUint64(scratch[:], uint64(word))
+				buf = append(buf, scratch[:8]...)
+				prev = w
+			}
+		}
+	}
+	return buf, nil
+}
+
+// countWords returns the number of non-zero words, without normalizing the
+// set as the statistics update would.
+func (bs *BitSet) countWords() (count int) {
+	for _, a2 := range bs.bits {
+		for _, a3 := range a2 {
+			for _, word := range a3 {
+				if word != 0 {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+/*UnmarshalBinary ...
+ *  Implements encoding.BinaryUnmarshaler. The set is left unchanged if the
+ *  data is malformed; it may be left partly filled if it goes over the memory
+ *  limit.
+ */
+func (bs *BitSet) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != cBinaryVersion {
+		return &ParseError{Offset: 0, Msg: "unknown binary format version"}
+	}
+	off := 1
+	count, n := binary.Uvarint(data[off:])
+	if n <= 0 {
+		return &ParseError{Offset: off, Msg: "truncated or overlong word count"}
+	}
+	off += n
+	/*  Each word takes at least 9 bytes, which bounds a sane count. */
+	if count > uint64(len(data)-off)/9 {
+		return &ParseError{Offset: off, Msg: "word count larger than the data"}
+	}
+	indexes := make([]int32, count)
+	words := make([]wordType, count)
+	w := int64(-1)
+	for k := range words {
+		gap, n := binary.Uvarint(data[off:])
+		if n <= 0 {
+			return &ParseError{Offset: off, Msg: "truncated or overlong word index"}
+		}
+		/*  Checked before adding, as a large gap overflows the index. */
+		if gap > uint64(cMaxWord)-uint64(w+1) {
+			return &ParseError{Offset: off, Msg: "word index out of range"}
+		}
+		w += int64(gap) + 1
+		off += n
+		if len(data)-off < 8 {
+			return &ParseError{Offset: off, Msg: "truncated word"}
+		}
+		word := wordType(binary.LittleEndian.Uint64(data[off:]))
+		if int32(w) == cMaxWord && word>>cLength4Size != 0 {
+			return &ParseError{Offset: off, Msg: "index out of range"}
+		}
+		indexes[k], words[k] = int32(w), word
+		off += 8
+	}
+	if off != len(data) {
+		return &ParseError{Offset: off, Msg: "trailing data"}
+	}
+	bs.ensureInitialized()
+	return bs.checked(func() {
+		bs.ClearAll()
+		for k, w := range indexes {
+			bs.setWord(w, words[k])
+		}
+	})
+}
+
+// setWord sets the word at word index w to the given value.
+func (bs *BitSet) setWord(w int32, word wordType) {
+	bs.blockFor(w << cShift3)[w&bs.geo.mask3] = word
+	bs.cache.hash = 0 //  Invalidate size, etc., values
+}
+
+// MarshalText implements encoding.TextMarshaler, in the form selected by
+// WithEncoding.
+func (bs *BitSet) MarshalText() ([]byte, error) {
+	if bs.encoding == Base64Encoding {
+		data, err := bs.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		text := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+		base64.StdEncoding.Encode(text, data)
+		return text, nil
+	}
+	var sb strings.Builder
+	bs.writeRanges(&sb, bs.compactionCount)
+	return []byte(sb.String()), nil
+}
+
+/*UnmarshalText ...
+ *  Implements encoding.TextUnmarshaler, accepting the set notation (a list of
+ *  indexes and inclusive "first..last" ranges in braces, in any order, e.g.
+ *  "{2..4,10}") and the base64 form. The set is left unchanged if the text is
+ *  malformed or holds an index outside 0..math.MaxInt32-1; it may be left
+ *  partly filled if it goes over the memory limit.
+ */
+func (bs *BitSet) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if !strings.HasPrefix(s, "{") {
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return &ParseError{Offset: 0, Msg: "neither set notation nor base64"}
+		}
+		return bs.UnmarshalBinary(data)
+	}
+	ranges, err := parseRanges(s)
+	if err != nil {
+		return err
+	}
+	bs.ensureInitialized()
+	return bs.checked(func() {
+		bs.ClearAll()
+		for k := 0; k < len(ranges); k += 2 {
+			bs.SetRange(ranges[k], ranges[k+1])
+		}
+	})
+}
+
+/*
+ *  Parses the set notation into pairs of first index and index after the
+ *  last, ready for SetRange.
+ */
+func parseRanges(s string) ([]int32, error) {
+	var ranges []int32
+	off := 1
+	body := s[1:]
+	if !strings.HasSuffix(body, "}") {
+		return nil, &ParseError{Offset: len(s), Msg: "missing closing brace"}
+	}
+	body = body[:len(body)-1]
+	if strings.TrimSpace(body) == "" {
+		return ranges, nil
+	}
+	for _, item := range strings.Split(body, ",") {
+		first, last := item, item
+		if k := strings.Index(item, ".."); k >= 0 {
+			first, last = item[:k], item[k+2:]
+		}
+		i, err := parseIndex(first, off)
+		if err != nil {
+			return nil, err
+		}
+		j, err := parseIndex(last, off)
+		if err != nil {
+			return nil, err
+		}
+		if j < i {
+			return nil, &ParseError{Offset: off, Msg: fmt.Sprintf("range %q is reversed", item)}
+		}
+		ranges = append(ranges, i, j+1)
+		off += len(item) + 1
+	}
+	return ranges, nil
+}
+
+func parseIndex(s string, off int) (int32, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, &ParseError{Offset: off, Msg: "missing index"}
+	}
+	i, err := strconv.ParseUint(s, 10, 32)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, &ParseError{Offset: off, Msg: fmt.Sprintf("invalid index %q", s)}
+	}
+	if err != nil || i >= math.MaxInt32 {
+		return 0, &ParseError{Offset: off, Msg: fmt.Sprintf("index %s out of range", s)}
+	}
+	return int32(i), nil
+}
+
+// MarshalJSON implements json.Marshaler, writing MarshalText as a string.
+func (bs *BitSet) MarshalJSON() ([]byte, error) {
+	text, err := bs.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a string in either
+// form of UnmarshalText. A JSON null leaves the set unchanged.
+func (bs *BitSet) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &ParseError{Offset: 0, Msg: "not a JSON string"}
+	}
+	return bs.UnmarshalText([]byte(s))
+}
+
+// ensureInitialized makes a zero BitSet, such as encoding/json allocates
+// for a *BitSet field, a usable empty set.
+func (bs *BitSet) ensureInitialized() {
+	if bs.geo == nil {
+		*bs = *New()
+	}
+}
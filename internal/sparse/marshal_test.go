@@ -0,0 +1,141 @@
+package sparse
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	want := []int32{0, 2, 3, 4, 63, 64, 3000, 1 << 20, 1<<20 + 1, math.MaxInt32 - 1}
+	for _, g := range geometries {
+		for _, e := range []Encoding{RangeEncoding, Base64Encoding} {
+			bs := New(WithGeometry(g), WithEncoding(e))
+			for _, i := range want {
+				bs.Set(i)
+			}
+
+			data, err := bs.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := New(WithGeometry(g))
+			b.Set(7) //  Replaced by the unmarshalled bits
+			if err := b.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.ToSlice(); !reflect.DeepEqual(got, want) {
+				t.Errorf("%v: binary round trip %v", g, got)
+			}
+
+			text, err := bs.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b = New(WithGeometry(g))
+			if err := b.UnmarshalText(text); err != nil {
+				t.Fatalf("%v: UnmarshalText(%s): %v", g, text, err)
+			}
+			if got := b.ToSlice(); !reflect.DeepEqual(got, want) {
+				t.Errorf("%v: text round trip of %s gave %v", g, text, got)
+			}
+			if err := b.Validate(); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	bs := New()
+	bs.SetRange(2, 5)
+	bs.Set(10)
+	if text, _ := bs.MarshalText(); string(text) != "{2..4,10}" {
+		t.Errorf("MarshalText = %s", text)
+	}
+	b := New()
+	if err := b.UnmarshalText([]byte(" { 10, 2 .. 4 } ")); err != nil || !b.Equals(bs) {
+		t.Errorf("UnmarshalText gave %v, %v", b, err)
+	}
+	if err := b.UnmarshalText([]byte("{}")); err != nil || !b.IsEmpty() {
+		t.Errorf("UnmarshalText({}) gave %v, %v", b, err)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	type record struct {
+		Keys *BitSet
+	}
+	keys := New()
+	keys.SetRange(2, 5)
+	data, err := json.Marshal(record{keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Keys":"{2..4}"}` {
+		t.Errorf("json.Marshal = %s", data)
+	}
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Keys.Equals(keys) {
+		t.Errorf("json.Unmarshal gave %v", r.Keys)
+	}
+	if err := json.Unmarshal([]byte(`{"Keys":null}`), &r); err != nil || r.Keys != nil {
+		t.Errorf("null gave %v, %v", r.Keys, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Keys":5}`), &r); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("a number gave %v", err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		offset int
+	}{
+		{"{1,2", 4},
+		{"{ 1, x}", 4},
+		{"{1,,2}", 3},
+		{"{5..3}", 1},
+		{"{2147483647}", 1},
+		{"{99999999999}", 1},
+		{"not base64!", 0},
+	} {
+		bs := New()
+		bs.Set(7)
+		err := bs.UnmarshalText([]byte(test.text))
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("UnmarshalText(%s): err = %v, want a ParseError", test.text, err)
+			continue
+		}
+		if parseErr.Offset != test.offset {
+			t.Errorf("UnmarshalText(%s): offset %d, want %d", test.text, parseErr.Offset, test.offset)
+		}
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{7}) {
+			t.Errorf("UnmarshalText(%s) changed the set to %v", test.text, got)
+		}
+	}
+
+	bs := New()
+	bs.Set(math.MaxInt32 - 1)
+	valid, _ := bs.MarshalBinary()
+	outOfRange := append([]byte(nil), valid...)
+	outOfRange[len(outOfRange)-1] = 0xff //  Sets the bit math.MaxInt32
+	for name, data := range map[string][]byte{
+		"empty":        {},
+		"version":      {2, 0},
+		"count":        {cBinaryVersion, 5, 0},
+		"truncated":    valid[:len(valid)-1],
+		"trailing":     append(append([]byte(nil), valid...), 0),
+		"out of range": outOfRange,
+		/*  A gap of 1 << 63 words, which would wrap the index around. */
+		"overflow": {cBinaryVersion, 1, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 1, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		if err := New().UnmarshalBinary(data); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("UnmarshalBinary(%s): err = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package sparse
+
+import "fmt"
+
+/*BitMatrix ...
+ *  A square sparse bit matrix kept as one BitSet per row. It is meant to hold
+ *  the adjacency relation of a directed graph: the bit (r, c) is set when
+ *  there is an edge from node r to node c. Rows are only allocated for nodes
+ *  that have outgoing edges.
+ */
+type BitMatrix struct {
+	rows []*BitSet
+	size int32
+}
+
+// NewBitMatrix creates an empty matrix with room for n rows. The matrix
+// grows as bits beyond n are set.
+func NewBitMatrix(n int32) *BitMatrix {
+	if n < 0 {
+		panic(fmt.Sprintf("NegativeArraySizeException(n=%v)", n))
+	}
+	return &BitMatrix{
+		rows: make([]*BitSet, n),
+		size: n,
+	}
+}
+
+// Size returns the number of nodes of the matrix, i.e. one more than the
+// highest row or column index ever set.
+func (m *BitMatrix) Size() int32 {
+	return m.size
+}
+
+// Set sets the bit (r, c).
+func (m *BitMatrix) Set(r, c int32) {
+	if r < 0 || c < 0 {
+		panic(fmt.Sprintf("IndexOutOfBoundsException(r=%v, c=%v)", r, c))
+	}
+	if r >= m.size {
+		m.size = r + 1
+	}
+	if c >= m.size {
+		m.size = c + 1
+	}
+	if n := int32(len(m.rows)); r >= n {
+		m.rows = append(m.rows, make([]*BitSet, m.size-n)...)
+	}
+	row := m.rows[r]
+	if row == nil {
+		row = New()
+		m.rows[r] = row
+	}
+	row.Set(c)
+}
+
+// Clear clears the bit (r, c).
+func (m *BitMatrix) Clear(r, c int32) {
+	if r < 0 || c < 0 {
+		panic(fmt.Sprintf("IndexOutOfBoundsException(r=%v, c=%v)", r, c))
+	}
+	if r < int32(len(m.rows)) && m.rows[r] != nil {
+		m.rows[r].Clear(c)
+	}
+}
+
+// Get returns the value of the bit (r, c).
+func (m *BitMatrix) Get(r, c int32) bool {
+	if r < 0 || c < 0 {
+		panic(fmt.Sprintf("IndexOutOfBoundsException(r=%v, c=%v)", r, c))
+	}
+	if r >= int32(len(m.rows)) || m.rows[r] == nil {
+		return false
+	}
+	return m.rows[r].GetBit(c)
+}
+
+// Row returns the bits of row r. The returned set is shared with the matrix
+// and must not be modified; an empty set is returned for a row without bits.
+func (m *BitMatrix) Row(r int32) *BitSet {
+	if r < 0 {
+		panic(fmt.Sprintf("IndexOutOfBoundsException(r=%v)", r))
+	}
+	if r >= int32(len(m.rows)) || m.rows[r] == nil {
+		return New()
+	}
+	return m.rows[r]
+}
+
+// Transpose returns a new matrix with the bit (c, r) set for every bit (r, c)
+// of this matrix.
+func (m *BitMatrix) Transpose() *BitMatrix {
+	result := NewBitMatrix(m.size)
+	for r, row := range m.rows {
+		if row == nil {
+			continue
+		}
+		for c := row.NextSetBit(0); c >= 0; c = row.NextSetBit(c + 1) {
+			result.Set(c, int32(r))
+		}
+	}
+	return result
+}
+
+/*TransitiveClosure ...
+ *  Replaces the matrix by its transitive closure, so that afterwards the bit
+ *  (r, c) is set if and only if there is a path from r to c. Reachability and
+ *  ancestor questions are then answered by a single Get.
+ *  <p>
+ *  This is Warshall's algorithm with whole rows: for every node k, each row
+ *  that reaches k is or-ed with the row of k. The rows reaching k are taken
+ *  from a transposed copy that is kept up to date with every bit the or-ing
+ *  adds, so the work done is bounded by the size of the closure rather than
+ *  by the square of the number of nodes.
+ */
+func (m *BitMatrix) TransitiveClosure() {
+	cols := m.Transpose()
+	for k := int32(0); k < int32(len(m.rows)); k++ {
+		rowK := m.rows[k]
+		if rowK == nil || k >= int32(len(cols.rows)) || cols.rows[k] == nil {
+			continue
+		}
+		colK := cols.rows[k]
+		for i := colK.NextSetBit(0); i >= 0; i = colK.NextSetBit(i + 1) {
+			if i == k {
+				continue
+			}
+			rowI := m.rows[i]
+			added := AndNot(rowK, rowI)
+			if added.IsEmpty() {
+				continue
+			}
+			rowI.OrBitSet(added)
+			for j := added.NextSetBit(0); j >= 0; j = added.NextSetBit(j + 1) {
+				cols.Set(j, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package sparse
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBitMatrix(t *testing.T) {
+	m := NewBitMatrix(2)
+	m.Set(0, 1)
+	m.Set(5, 3)
+	if m.Size() != 6 {
+		t.Errorf("Size() = %d, want 6", m.Size())
+	}
+	if !m.Get(0, 1) || !m.Get(5, 3) || m.Get(1, 0) || m.Get(100, 0) {
+		t.Error("Get does not match the bits set")
+	}
+	if got := m.Row(5).ToSlice(); !reflect.DeepEqual(got, []int32{3}) {
+		t.Errorf("Row(5) = %v", got)
+	}
+	if !m.Row(2).IsEmpty() || !m.Row(100).IsEmpty() {
+		t.Error("rows without bits are not empty")
+	}
+
+	tr := m.Transpose()
+	if tr.Size() != 6 || !tr.Get(1, 0) || !tr.Get(3, 5) || tr.Get(0, 1) {
+		t.Errorf("Transpose has size %d and bits (1,0) %v, (3,5) %v, (0,1) %v",
+			tr.Size(), tr.Get(1, 0), tr.Get(3, 5), tr.Get(0, 1))
+	}
+	m.Clear(0, 1)
+	m.Clear(50, 50)
+	if m.Get(0, 1) || !tr.Get(1, 0) {
+		t.Error("Clear did not clear only the bit of the matrix")
+	}
+}
+
+func TestTransitiveClosure(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 40
+	var want [n][n]bool
+	m := NewBitMatrix(0)
+	for k := 0; k < 60; k++ {
+		r, c := rng.Int31n(n), rng.Int31n(n)
+		m.Set(r, c)
+		want[r][c] = true
+	}
+	/*  A cycle, so that nodes reach themselves. */
+	for _, e := range [][2]int32{{0, 1}, {1, 2}, {2, 0}} {
+		m.Set(e[0], e[1])
+		want[e[0]][e[1]] = true
+	}
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				want[i][j] = want[i][j] || want[i][k] && want[k][j]
+			}
+		}
+	}
+
+	m.TransitiveClosure()
+	for i := int32(0); i < n; i++ {
+		for j := int32(0); j < n; j++ {
+			if m.Get(i, j) != want[i][j] {
+				t.Fatalf("closure bit (%d, %d) is %v, want %v", i, j, m.Get(i, j), want[i][j])
+			}
+		}
+	}
+	if !m.Get(0, 0) || !m.Get(2, 1) {
+		t.Error("nodes of the cycle do not reach each other")
+	}
+}
@@ -0,0 +1,235 @@
+package sparse
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+/*
+ *  Bytes taken by one entry of the level1 array. The slice headers of an
+ *  array are counted, but not the fixed part of the BitSet itself; the sizes
+ *  of level2 areas and level3 blocks depend on the geometry (see layout).
+ */
+const cLevel1EntryBytes = int64(unsafe.Sizeof(b2DimType(nil)))
+
+// ErrMemoryLimit is the error every MemoryLimitError unwraps to.
+var ErrMemoryLimit = errors.New("sparse: memory limit exceeded")
+
+// MemoryLimitError reports an allocation that would have taken a bit set
+// over the limit given by WithMemoryLimit.
+type MemoryLimitError struct {
+	Limit     int64
+	InUse     int64
+	Requested int64
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("%v: %d bytes in use, %d more requested, limit is %d",
+		ErrMemoryLimit, e.InUse, e.Requested, e.Limit)
+}
+
+func (e *MemoryLimitError) Unwrap() error {
+	return ErrMemoryLimit
+}
+
+// Option configures a BitSet at construction.
+type Option func(bs *BitSet)
+
+// options returns the options that give a new set the configuration of this
+// one, for the sets derived from it.
+func (bs *BitSet) options() []Option {
+	return []Option{
+		WithMemoryLimit(bs.memoryLimit),
+		WithGeometry(bs.Geometry()),
+		WithInterner(bs.interner),
+		WithBlockPool(bs.pool),
+		WithEncoding(bs.encoding),
+	}
+}
+
+// WithMemoryLimit limits the bytes a bit set may hold in its level1 array,
+// level2 areas, level3 blocks and spare block. A limit of zero or less means
+// no limit. Sets derived from a limited set (clones, And, Or, ...) inherit
+// its limit.
+func WithMemoryLimit(bytes int64) Option {
+	return func(bs *BitSet) {
+		if bytes < 0 {
+			bytes = 0
+		}
+		bs.memoryLimit = bytes
+	}
+}
+
+// HeapBytes returns the number of bytes currently held by the arrays of the
+// bit set, including its spare block, so that many sets can be budgeted
+// together.
+func (bs *BitSet) HeapBytes() int64 {
+	return bs.heapBytes
+}
+
+// MemoryLimit returns the limit given by WithMemoryLimit, zero if none.
+func (bs *BitSet) MemoryLimit() int64 {
+	return bs.memoryLimit
+}
+
+/*
+ *  Checks that <i>bytes</i> more may be allocated. If not, the set is left
+ *  as it is and a *MemoryLimitError is panicked with; the checked methods
+ *  turn it into their error result.
+ */
+func (bs *BitSet) reserve(bytes int64) {
+	if bs.memoryLimit > 0 && bs.heapBytes+bytes > bs.memoryLimit {
+		panic(&MemoryLimitError{
+			Limit:     bs.memoryLimit,
+			InUse:     bs.heapBytes,
+			Requested: bytes,
+		})
+	}
+}
+
+/*
+ *  Checks, before the spare block is adopted into the set for the bit i, that
+ *  the replacement spare, a level2 area (if one is needed) and a larger level1
+ *  array (if i is beyond it) may all be allocated. On failure the spare is
+ *  zeroed again, as the scan has already written into it.
+ */
+func (bs *BitSet) reserveAdoption(i int32, needArea bool) {
+	g := bs.geo
+	bytes := g.block3Bytes
+	if needArea {
+		bytes += g.area2Bytes
+	}
+	if i >= bs.bitsLength {
+		bytes += int64(g.length1For(i)-int32(len(bs.bits))) * cLevel1EntryBytes
+	}
+	if bs.memoryLimit > 0 && bs.heapBytes+bytes > bs.memoryLimit {
+		for w := range bs.spare {
+			bs.spare[w] = 0
+		}
+		bs.reserve(bytes)
+	}
+}
+
+// newArea allocates an (empty) level2 area within the memory limit.
+func (bs *BitSet) newArea() b2DimType {
+	g := bs.geo
+	bs.reserve(g.area2Bytes)
+	bs.heapBytes += g.area2Bytes
+	return make(b2DimType, g.length2)
+}
+
+// newBlock allocates a zero level3 block within the memory limit, taking it
+// from the block pool if there is one.
+func (bs *BitSet) newBlock() b1DimType {
+	g := bs.geo
+	bs.reserve(g.block3Bytes)
+	bs.heapBytes += g.block3Bytes
+	if a3 := bs.pool.get(g.length3); a3 != nil {
+		return a3
+	}
+	return make(b1DimType, g.length3)
+}
+
+// dropBlock removes the level3 block a2[w2], if any, giving a private block
+// back to the block pool.
+func (bs *BitSet) dropBlock(a2 b2DimType, w2 int32) {
+	if a3 := a2[w2]; a3 != nil {
+		a2[w2] = nil
+		if bs.isShared(a3) {
+			bs.releaseShared(a3)
+		} else {
+			bs.heapBytes -= bs.geo.block3Bytes
+			bs.pool.put(a3)
+		}
+	}
+}
+
+// dropArea removes the level2 area bits[w1], if any, with all its blocks.
+func (bs *BitSet) dropArea(w1 int32) {
+	a2 := bs.bits[w1]
+	if a2 == nil {
+		return
+	}
+	for w2 := range a2 {
+		bs.dropBlock(a2, int32(w2))
+	}
+	bs.bits[w1] = nil
+	bs.heapBytes -= bs.geo.area2Bytes
+}
+
+/*
+ *  Runs op, returning a *MemoryLimitError or *GeometryError raised by it as
+ *  an error. Any other panic is passed on. An operation stopped by the limit
+ *  may have changed part of the set, but leaves it in a consistent state; a
+ *  geometry mismatch is found before anything is changed.
+ */
+func (bs *BitSet) checked(op func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case *MemoryLimitError:
+				err = e
+			case *GeometryError:
+				err = e
+			default:
+				panic(r)
+			}
+		}
+	}()
+	op()
+	return
+}
+
+// TrySet is Set returning an error instead of going over the memory limit.
+func (bs *BitSet) TrySet(i int32) error {
+	return bs.checked(func() { bs.Set(i) })
+}
+
+// TrySetBit is SetBit returning an error instead of going over the memory
+// limit.
+func (bs *BitSet) TrySetBit(i int32, value bool) error {
+	return bs.checked(func() { bs.SetBit(i, value) })
+}
+
+// TrySetRange is SetRange returning an error instead of going over the
+// memory limit.
+func (bs *BitSet) TrySetRange(i, j int32) error {
+	return bs.checked(func() { bs.SetRange(i, j) })
+}
+
+// TryFlipBit is FlipBit returning an error instead of going over the memory
+// limit.
+func (bs *BitSet) TryFlipBit(i int32) error {
+	return bs.checked(func() { bs.FlipBit(i) })
+}
+
+// TryFlipRange is FlipRange returning an error instead of going over the
+// memory limit.
+func (bs *BitSet) TryFlipRange(i, j int32) error {
+	return bs.checked(func() { bs.FlipRange(i, j) })
+}
+
+// TryAndBitSet is AndBitSet returning an error instead of panicking when b
+// has a different geometry.
+func (bs *BitSet) TryAndBitSet(b *BitSet) error {
+	return bs.checked(func() { bs.AndBitSet(b) })
+}
+
+// TryAndNotBitSet is AndNotBitSet returning an error instead of panicking
+// when b has a different geometry.
+func (bs *BitSet) TryAndNotBitSet(b *BitSet) error {
+	return bs.checked(func() { bs.AndNotBitSet(b) })
+}
+
+// TryOrBitSet is OrBitSet returning an error instead of going over the
+// memory limit.
+func (bs *BitSet) TryOrBitSet(b *BitSet) error {
+	return bs.checked(func() { bs.OrBitSet(b) })
+}
+
+// TryXorBitSet is XorBitSet returning an error instead of going over the
+// memory limit.
+func (bs *BitSet) TryXorBitSet(b *BitSet) error {
+	return bs.checked(func() { bs.XorBitSet(b) })
+}
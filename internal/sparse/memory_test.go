@@ -0,0 +1,108 @@
+package sparse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	g := defaultLayout
+	block := g.length3 * cLength4 //  Bits in a level3 block
+	/*  Room for one level2 area and one level3 block beyond an empty set. */
+	limit := New().HeapBytes() + g.area2Bytes + g.block3Bytes
+	bs := New(WithMemoryLimit(limit))
+	if bs.MemoryLimit() != limit {
+		t.Fatalf("MemoryLimit() = %d, want %d", bs.MemoryLimit(), limit)
+	}
+	if err := bs.TrySet(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := bs.TrySet(1 << 20)
+	var limitErr *MemoryLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("TrySet(1 << 20): err = %v, want a MemoryLimitError", err)
+	}
+	if limitErr.Limit != limit || limitErr.InUse != bs.HeapBytes() || limitErr.Requested <= 0 {
+		t.Errorf("error %+v with %d bytes in use", limitErr, bs.HeapBytes())
+	}
+	if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{1}) {
+		t.Errorf("bits %v after a failed TrySet, want [1]", got)
+	}
+
+	/*  The range is set up to the block that could not be allocated. */
+	if err := bs.TrySetRange(0, 2*block); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("TrySetRange: err = %v, want ErrMemoryLimit", err)
+	}
+	if bs.Cardinality() != block || bs.GetBit(block) {
+		t.Errorf("TrySetRange over the limit left %d bits set", bs.Cardinality())
+	}
+	if err := bs.TryFlipRange(block, block+10); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("TryFlipRange: err = %v, want ErrMemoryLimit", err)
+	}
+	if err := bs.TrySetBit(3*block, true); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("TrySetBit: err = %v, want ErrMemoryLimit", err)
+	}
+	if bs.HeapBytes() > limit {
+		t.Errorf("%d bytes held, over the limit %d", bs.HeapBytes(), limit)
+	}
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	/*  Bits within the blocks held need no memory. */
+	if err := bs.TryFlipRange(0, 10); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryLimitOperations(t *testing.T) {
+	g := defaultLayout
+	limit := New().HeapBytes() + g.area2Bytes + g.block3Bytes
+	far := setOf(DefaultGeometry, [2]int32{1 << 20, 1<<20 + 1})
+	for name, op := range map[string]func(bs *BitSet) error{
+		"TryOrBitSet":  func(bs *BitSet) error { return bs.TryOrBitSet(far) },
+		"TryXorBitSet": func(bs *BitSet) error { return bs.TryXorBitSet(far) },
+	} {
+		bs := New(WithMemoryLimit(limit))
+		bs.Set(7)
+		if err := op(bs); !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("%s: err = %v, want ErrMemoryLimit", name, err)
+		}
+		if !bs.GetBit(7) || bs.HeapBytes() > limit {
+			t.Errorf("%s: %v with %d bytes held", name, bs, bs.HeapBytes())
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMemoryLimitInherited(t *testing.T) {
+	for _, limit := range []int64{0, -5} {
+		bs := New(WithMemoryLimit(limit))
+		if bs.MemoryLimit() != 0 {
+			t.Errorf("MemoryLimit() = %d for the limit %d, want 0", bs.MemoryLimit(), limit)
+		}
+		if err := bs.TrySetRange(0, 1<<20); err != nil {
+			t.Errorf("limit %d: %v", limit, err)
+		}
+	}
+
+	const limit = 1 << 20
+	bs := New(WithMemoryLimit(limit))
+	bs.SetRange(10, 20)
+	other := New(WithMemoryLimit(limit))
+	other.Set(15)
+	for name, derived := range map[string]*BitSet{
+		"Clone":              bs.Clone(),
+		"GetBitSetFromRange": bs.GetBitSetFromRange(0, 15),
+		"And":                And(bs, other),
+		"Or":                 Or(bs, other),
+	} {
+		if derived.MemoryLimit() != limit {
+			t.Errorf("%s: MemoryLimit() = %d, want %d", name, derived.MemoryLimit(), limit)
+		}
+	}
+}
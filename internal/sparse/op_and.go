@@ -61,6 +61,8 @@ func (bs *BitSet) AndRangeBitSet(i, j int32, b *BitSet) {
  */
 //    public void and(SparseBitSet b)
 func (bs *BitSet) AndBitSet(b *BitSet) {
+	/*  Before the optimisation below drops part of this set. */
+	bs.sameGeometry(b)
 	{
 		bmin := len(bs.bits)
 		if len(b.bits) < bmin {
@@ -91,7 +93,7 @@ func (bs *BitSet) AndBitSet(b *BitSet) {
  */
 //public static SparseBitSet and(SparseBitSet a, SparseBitSet b)
 func And(a, b *BitSet) *BitSet {
-	result := a.clone()
+	result := a.Clone()
 	result.AndBitSet(b)
 	return result
 }
@@ -57,6 +57,7 @@ func (bs *BitSet) AndNotRangeBitSet(i, j int32, b *BitSet) {
  */
 //    public void andNot(SparseBitSet b)
 func (bs *BitSet) AndNotBitSet(b *BitSet) {
+	bs.sameGeometry(b)
 	bmin := bs.bitsLength
 	if b.bitsLength < bmin {
 		bmin = b.bitsLength
@@ -81,7 +82,7 @@ func (bs *BitSet) AndNotBitSet(b *BitSet) {
  */
 //public static SparseBitSet andNot(SparseBitSet a, SparseBitSet b){
 func AndNot(a, b *BitSet) *BitSet {
-	result := a.clone()
+	result := a.Clone()
 	result.AndNotBitSet(b)
 	return result
 }
@@ -11,6 +11,7 @@ import "fmt"
  * @since       1.6
  */
 func (bs *BitSet) Clear(i int32) {
+	g := bs.geo
 	/*  In the interests of speed, no check is made here on whether the
 	level3 block goes to all zero. This may be found and corrected
 	in some later operation. */
@@ -21,16 +22,16 @@ func (bs *BitSet) Clear(i int32) {
 		return
 	}
 	w := i >> cShift3
-	a2 := bs.bits[w>>cShift1]
+	a2 := bs.bits[w>>g.shift1]
 	if a2 == nil {
 		return
 	}
-	a3 := a2[(w>>cShift2)&cMask2]
+	a3 := bs.writable(a2, (w>>g.shift2)&g.mask2)
 	if a3 == nil {
 		return
 	}
-	a3[(w & cMask3)] &= ^wordType(uint(1) << remainderOf64(i)) //  Clear the indicated bit
-	bs.cache.hash = 0                                          //  Invalidate size, etc.,
+	a3[(w & g.mask3)] &= ^wordType(uint(1) << remainderOf64(i)) //  Clear the indicated bit
+	bs.cache.hash = 0                                           //  Invalidate size, etc.,
 }
 
 /**
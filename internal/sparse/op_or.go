@@ -58,6 +58,7 @@ func (bs *BitSet) OrRangeBitSet(i, j int32, b *BitSet) {
  */
 //public void or(SparseBitSet b){
 func (bs *BitSet) OrBitSet(b *BitSet) {
+	bs.sameGeometry(b)
 	bs.setScanner(0, b.bitsLength, b, orStrategy)
 }
 
@@ -75,7 +76,7 @@ func (bs *BitSet) OrBitSet(b *BitSet) {
  */
 //public static SparseBitSet or(SparseBitSet a, SparseBitSet b) {
 func Or(a, b *BitSet) *BitSet {
-	result := a.clone()
+	result := a.Clone()
 	result.OrBitSet(b)
 	return result
 }
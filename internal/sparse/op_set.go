@@ -11,28 +11,29 @@ import "fmt"
  * @since       1.6
  */
 func (bs *BitSet) Set(i int32) {
+	g := bs.geo
 	if i < 0 {
 		panic(fmt.Sprintf("IndexOutOfBoundsException(i=%v)", i))
 	}
 	w := i >> cShift3
-	w1 := w >> cShift1
-	w2 := (w >> cShift2) & cMask2
+	w1 := w >> g.shift1
+	w2 := (w >> g.shift2) & g.mask2
 
 	if i >= bs.bitsLength {
 		bs.resize(i)
 	}
 	a2 := bs.bits[w1]
 	if a2 == nil {
-		a2 = make(b2DimType, cLength2)
+		a2 = bs.newArea()
 		bs.bits[w1] = a2
 	}
 
-	a3 := a2[w2]
+	a3 := bs.writable(a2, w2)
 	if a3 == nil {
-		a3 = make(b1DimType, cLength3)
+		a3 = bs.newBlock()
 		a2[w2] = a3
 	}
-	a3[(w & cMask3)] |= wordType(uint(1) << remainderOf64(i))
+	a3[(w & g.mask3)] |= wordType(uint(1) << remainderOf64(i))
 	bs.cache.hash = 0 //Invalidate size, etc., scan
 }
 
@@ -74,6 +74,7 @@ func (bs *BitSet) XorRangeBitSet(i, j int32, b *BitSet) {
  */
 //public void xor(SparseBitSet b) {
 func (bs *BitSet) XorBitSet(b *BitSet) {
+	bs.sameGeometry(b)
 	bs.setScanner(0, b.bitsLength, b, xorStrategy)
 }
 
@@ -96,7 +97,7 @@ func (bs *BitSet) XorBitSet(b *BitSet) {
  */
 //public static SparseBitSet xor(SparseBitSet a, SparseBitSet b){
 func Xor(a, b *BitSet) *BitSet {
-	result := a.clone()
+	result := a.Clone()
 	result.XorBitSet(b)
 	return result
 }
@@ -0,0 +1,122 @@
+package sparse
+
+import "sync"
+
+/*BlockPool ...
+ *  A free list of level3 blocks, shared by any number of bit sets. A set made
+ *  with WithBlockPool(p) takes the blocks it needs from the pool before
+ *  allocating new ones, and gives back the blocks it removes (by ClearAll,
+ *  Compact, or when an operation leaves a block all zero), so that sets which
+ *  are built up and torn down repeatedly stop producing garbage.
+ *  <p>
+ *  Blocks of every geometry may be kept in the same pool. A pool holds at
+ *  most the number of blocks given to NewBlockPool; further blocks given back
+ *  are left to the garbage collector. Blocks in the pool are not counted by
+ *  the HeapBytes (nor the memory limit) of any set.
+ *  <p>
+ *  The pool may be used by sets in different goroutines.
+ */
+type BlockPool struct {
+	mu sync.Mutex
+	/*  The free blocks, all zero, by block length. */
+	free      map[int32][]b1DimType
+	count     int
+	maxBlocks int
+}
+
+// NewBlockPool returns an empty pool keeping at most maxBlocks blocks.
+func NewBlockPool(maxBlocks int) *BlockPool {
+	return &BlockPool{
+		free:      make(map[int32][]b1DimType),
+		maxBlocks: maxBlocks,
+	}
+}
+
+// WithBlockPool makes a set take its level3 blocks from the pool and give
+// them back to it. Sets derived from it (clones, And, Or, ...) use the same
+// pool.
+func WithBlockPool(p *BlockPool) Option {
+	return func(bs *BitSet) {
+		bs.pool = p
+	}
+}
+
+// Len returns the number of blocks in the pool.
+func (p *BlockPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.count
+}
+
+/*
+ *  Returns a zero block of the given length from the pool, or nil if there
+ *  is none (or no pool at all).
+ */
+func (p *BlockPool) get(length int32) b1DimType {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	free := p.free[length]
+	if len(free) == 0 {
+		return nil
+	}
+	a3 := free[len(free)-1]
+	free[len(free)-1] = nil
+	p.free[length] = free[:len(free)-1]
+	p.count--
+	return a3
+}
+
+/*
+ *  Gives the block a3, which nothing refers to any more, back to the pool.
+ *  Without a pool, or if the pool is full, the block is left to the garbage
+ *  collector.
+ */
+func (p *BlockPool) put(a3 b1DimType) {
+	if p == nil {
+		return
+	}
+	for w3 := range a3 {
+		a3[w3] = 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.count >= p.maxBlocks {
+		return
+	}
+	length := int32(len(a3))
+	p.free[length] = append(p.free[length], a3)
+	p.count++
+}
+
+/*Compact ...
+ *  Removes the all zero level3 blocks and empty level2 areas that earlier
+ *  operations may have left in the set (giving the blocks back to the pool,
+ *  if there is one), and shrinks the level1 array to the smallest size that
+ *  holds the set. The value of the set is not changed.
+ */
+func (bs *BitSet) Compact() {
+	bs.cache.hash = 0 //  Force a full scan, even if the statistics are valid
+	bs.statisticsUpdate()
+	/*  The scan drops the zero blocks, but may leave areas with no blocks. */
+	for w1, a2 := range bs.bits {
+		empty := a2 != nil
+		for _, a3 := range a2 {
+			if a3 != nil {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			bs.dropArea(int32(w1))
+			bs.cache.a2Count-- //  The scan counted it
+		}
+	}
+	last := bs.cache.length - 1
+	if last < 0 {
+		last = 0
+	}
+	bs.resize(last)
+}
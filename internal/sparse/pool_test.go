@@ -0,0 +1,34 @@
+package sparse
+
+import "testing"
+
+// benchmarkAndOr rebuilds a set from two others by Or and And, then clears
+// it, as a caller reusing one result set in a loop would.
+func benchmarkAndOr(b *testing.B, options ...Option) {
+	x, y := New(options...), New(options...)
+	for i := int32(0); i < 1<<20; i += 3 {
+		x.Set(i)
+	}
+	for i := int32(0); i < 1<<20; i += 5 {
+		y.Set(i)
+	}
+	result := New(options...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		result.OrBitSet(x)
+		result.AndBitSet(y)
+		if result.Cardinality() == 0 {
+			b.Fatal("empty result")
+		}
+		result.ClearAll()
+	}
+}
+
+func BenchmarkAndOr(b *testing.B) {
+	benchmarkAndOr(b)
+}
+
+func BenchmarkAndOrPooled(b *testing.B) {
+	benchmarkAndOr(b, WithBlockPool(NewBlockPool(1<<12)))
+}
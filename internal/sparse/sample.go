@@ -0,0 +1,120 @@
+package sparse
+
+import (
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+/*RandomSetBit ...
+ *  Returns the index of a set bit chosen uniformly at random with rng, or -1
+ *  if the set is empty. The bit is found through the block ranks kept with
+ *  the statistics, so only the words of one level3 block are counted; the
+ *  same rng state on the same set gives the same bit.
+ */
+func (bs *BitSet) RandomSetBit(rng *rand.Rand) int32 {
+	ranks, cardinality := bs.blockRanks()
+	if cardinality == 0 {
+		return -1
+	}
+	return bs.selectBit(ranks, rng.Int31n(cardinality))
+}
+
+/*Sample ...
+ *  Returns the indexes of n distinct set bits chosen uniformly at random with
+ *  rng, in increasing order, or all the set bits if there are no more than
+ *  n. As with RandomSetBit, the bits are found through the block ranks,
+ *  without enumerating the set, and the same rng state on the same set gives
+ *  the same sample.
+ */
+func (bs *BitSet) Sample(n int, rng *rand.Rand) []int32 {
+	ranks, cardinality := bs.blockRanks()
+	if n <= 0 {
+		return []int32{}
+	}
+	if int64(n) >= int64(cardinality) {
+		return bs.ToSlice()
+	}
+	/*  Floyd's algorithm draws n distinct ranks with n calls to rng. */
+	chosen := make(map[int32]bool, n)
+	drawn := make([]int32, 0, n)
+	for j := cardinality - int32(n); j < cardinality; j++ {
+		r := rng.Int31n(j + 1)
+		if chosen[r] {
+			r = j
+		}
+		chosen[r] = true
+		drawn = append(drawn, r)
+	}
+	sort.Slice(drawn, func(i, j int) bool { return drawn[i] < drawn[j] })
+	result := make([]int32, n)
+	for k, r := range drawn {
+		result[k] = bs.selectBit(ranks, r)
+	}
+	return result
+}
+
+/*
+ *  Returns the block ranks of the set and its cardinality. The blocks are
+ *  ranked on the first call after the statistics were updated, rather than
+ *  by the update itself, as most sets are never sampled. While a transaction
+ *  is open the statistics describe the set at Begin, so the blocks are ranked
+ *  afresh on every call, and the ranks are not kept.
+ */
+func (bs *BitSet) blockRanks() ([]blockRank, int32) {
+	if bs.txn != nil {
+		return bs.rankBlocks()
+	}
+	bs.statisticsUpdate()
+	if bs.cache.ranks == nil && bs.cache.cardinality != 0 {
+		bs.cache.ranks, _ = bs.rankBlocks()
+	}
+	return bs.cache.ranks, bs.cache.cardinality
+}
+
+/*
+ *  Ranks the non-empty level3 blocks of the set in increasing order,
+ *  returning the ranks and the number of bits set. The set is not changed.
+ */
+func (bs *BitSet) rankBlocks() (ranks []blockRank, cardinality int32) {
+	g := bs.geo
+	for w1, a2 := range bs.bits {
+		for w2, a3 := range a2 {
+			count := int32(0)
+			for _, word := range a3 {
+				count += int32(bits.OnesCount64(uint64(word)))
+			}
+			if count != 0 {
+				ranks = append(ranks, blockRank{int32(w1)<<g.Level2 + int32(w2), cardinality})
+				cardinality += count
+			}
+		}
+	}
+	return
+}
+
+/*
+ *  Returns the index of the set bit of the given rank (the count of set bits
+ *  before it) among the ranked blocks; the rank must be less than the
+ *  cardinality of the set.
+ */
+func (bs *BitSet) selectBit(ranks []blockRank, rank int32) int32 {
+	g := bs.geo
+	/*  The block of the bit is the last one with fewer bits before it. */
+	k := sort.Search(len(ranks), func(k int) bool { return ranks[k].before > rank }) - 1
+	rank -= ranks[k].before
+	w := ranks[k].block << g.shift2
+	a3 := bs.bits[w>>g.shift1][(w>>g.shift2)&g.mask2]
+	for w3, word := range a3 {
+		count := int32(bits.OnesCount64(uint64(word)))
+		if rank >= count {
+			rank -= count
+			continue
+		}
+		for ; rank > 0; rank-- {
+			word &= word - 1 //  Drop the lowest set bit
+		}
+		return (w+int32(w3))<<cShift3 + int32(bits.TrailingZeros64(uint64(word)))
+	}
+	panic("sparse: block ranks out of date")
+}
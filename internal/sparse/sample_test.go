@@ -0,0 +1,104 @@
+package sparse
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	for _, g := range []Geometry{DefaultGeometry, VerySparseGeometry, ClusteredGeometry} {
+		bs := New(WithGeometry(g))
+		bs.SetRange(100, 300)
+		bs.SetRange(1<<20, 1<<20+5000)
+		for i := int32(1 << 24); i < 1<<28; i += 1 << 18 {
+			bs.Set(i)
+		}
+		all := bs.ToSlice()
+		member := make(map[int32]bool, len(all))
+		for _, i := range all {
+			member[i] = true
+		}
+
+		for _, n := range []int{0, 1, 50, len(all) - 1, len(all), len(all) + 10} {
+			got := bs.Sample(n, rand.New(rand.NewSource(7)))
+			if again := bs.Sample(n, rand.New(rand.NewSource(7))); !reflect.DeepEqual(got, again) {
+				t.Fatalf("%v: Sample(%d) not reproducible", g, n)
+			}
+			want := n
+			if want > len(all) {
+				want = len(all)
+			}
+			if len(got) != want || !sort.SliceIsSorted(got, func(i, j int) bool { return got[i] < got[j] }) {
+				t.Fatalf("%v: Sample(%d) gave %d bits, sorted %v", g, n, len(got),
+					sort.SliceIsSorted(got, func(i, j int) bool { return got[i] < got[j] }))
+			}
+			for k, i := range got {
+				if !member[i] || (k > 0 && got[k-1] == i) {
+					t.Fatalf("%v: Sample(%d) gave %d, not set or repeated", g, n, i)
+				}
+			}
+		}
+
+		/*  Each of the 3 runs of bits should be drawn about in proportion to
+		its size: 200, 5000 and 1024 bits of 6224. */
+		rng := rand.New(rand.NewSource(1))
+		var hits [3]int
+		const draws = 20000
+		for k := 0; k < draws; k++ {
+			i := bs.RandomSetBit(rng)
+			switch {
+			case !member[i]:
+				t.Fatalf("%v: RandomSetBit gave %d, not set", g, i)
+			case i < 1<<20:
+				hits[0]++
+			case i < 1<<24:
+				hits[1]++
+			default:
+				hits[2]++
+			}
+		}
+		for k, size := range []int{200, 5000, 1024} {
+			expected := draws * size / len(all)
+			if d := hits[k] - expected; d*d > 25*expected {
+				t.Errorf("%v: run %d drawn %d times, expected about %d", g, k, hits[k], expected)
+			}
+		}
+		if err := bs.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if i := New().RandomSetBit(rand.New(rand.NewSource(1))); i != -1 {
+		t.Errorf("RandomSetBit of an empty set = %d, want -1", i)
+	}
+}
+
+func TestBlockRanksLazy(t *testing.T) {
+	bs := New()
+	bs.SetRange(100, 300)
+	bs.Set(1 << 20)
+	if bs.Cardinality(); bs.cache.ranks != nil {
+		t.Fatal("blocks ranked by the statistics update")
+	}
+	rng := rand.New(rand.NewSource(1))
+	bs.RandomSetBit(rng)
+	if want := []blockRank{{0, 0}, {(1 << 20) >> cShift3 >> cShift2, 200}}; !reflect.DeepEqual(bs.cache.ranks, want) {
+		t.Fatalf("ranks %v, want %v", bs.cache.ranks, want)
+	}
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	bs.Set(5)
+	if bs.Cardinality(); bs.cache.ranks != nil {
+		t.Fatal("ranks kept after the set changed")
+	}
+	if got := bs.Sample(3, rng); len(got) != 3 {
+		t.Fatalf("Sample(3) = %v", got)
+	}
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package sparse
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+/*FromSorted ...
+ *  Creates a bit set with the bits of the given indexes set. The indexes are
+ *  expected in increasing order (duplicates are allowed): the set is then
+ *  sized once, and each level3 block is looked up once and filled in a single
+ *  visit. Indexes in any other order give the same set, only more slowly.
+ *
+ * @exception   IndexOutOfBoundsException if an index is negative or equal
+ *              to Integer.MAX_VALUE
+ */
+func FromSorted(keys []int32, options ...Option) *BitSet {
+	/*  Size the set for the last index, unless it is not valid, in which
+	case setSorted will fail on it. */
+	capacity := int32(1)
+	if n := len(keys); n != 0 && keys[n-1] > 0 && keys[n-1] < math.MaxInt32 {
+		capacity = keys[n-1] + 1
+	}
+	result := newWithSizeAndCompactionCount(capacity, DefaultCompactionCount(), options...)
+	result.setSorted(keys)
+	return result
+}
+
+/*FromUnsorted ...
+ *  Creates a bit set with the bits of the given indexes, in any order, set.
+ *  The indexes are sorted (in a copy, the slice given is not changed) and
+ *  then set as by FromSorted.
+ */
+func FromUnsorted(keys []int32, options ...Option) *BitSet {
+	sorted := make([]int32, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return FromSorted(sorted, options...)
+}
+
+/*
+ *  Sets the bits of the given indexes, visiting the level3 block of a run of
+ *  indexes falling into the same block once.
+ */
+func (bs *BitSet) setSorted(keys []int32) {
+	g := bs.geo
+	var a3 b1DimType
+	block := int32(-1) //  Block index (word index shifted by SHIFT2) of a3
+	for _, i := range keys {
+		if (i + 1) < 1 {
+			panic(fmt.Sprintf("IndexOutOfBoundsException(i=%v)", i))
+		}
+		w := i >> cShift3
+		if w>>g.shift2 != block {
+			block = w >> g.shift2
+			a3 = bs.blockFor(i)
+		}
+		a3[w&g.mask3] |= wordType(uint(1) << remainderOf64(i))
+	}
+	bs.cache.hash = 0 //  Invalidate size, etc., values
+}
+
+/*
+ *  Returns the level3 block holding bit i ready to be written to, creating it
+ *  (and its level2 area, and room in the level1 array) if need be.
+ */
+func (bs *BitSet) blockFor(i int32) b1DimType {
+	g := bs.geo
+	w := i >> cShift3
+	w1 := w >> g.shift1
+	w2 := (w >> g.shift2) & g.mask2
+	if i >= bs.bitsLength {
+		bs.resize(i)
+	}
+	a2 := bs.bits[w1]
+	if a2 == nil {
+		a2 = bs.newArea()
+		bs.bits[w1] = a2
+	}
+	a3 := bs.writable(a2, w2)
+	if a3 == nil {
+		a3 = bs.newBlock()
+		a2[w2] = a3
+	}
+	return a3
+}
+
+/*AppendTo ...
+ *  Appends the indexes of the set bits, in increasing order, to dst and
+ *  returns the extended slice.
+ */
+func (bs *BitSet) AppendTo(dst []int32) []int32 {
+	g := bs.geo
+	for w1, a2 := range bs.bits {
+		for w2, a3 := range a2 {
+			for w3, word := range a3 {
+				base := ((int32(w1) << g.shift1) + (int32(w2) << g.shift2) + int32(w3)) << cShift3
+				for word != 0 {
+					dst = append(dst, base+int32(bits.TrailingZeros64(uint64(word))))
+					word &= word - 1
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// ToSlice returns the indexes of the set bits in increasing order.
+func (bs *BitSet) ToSlice() []int32 {
+	return bs.AppendTo(make([]int32, 0, bs.Cardinality()))
+}
@@ -0,0 +1,64 @@
+package sparse
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFromSorted(t *testing.T) {
+	want := []int32{0, 3, 64, 3000, 3001, 1 << 20, math.MaxInt32 - 1}
+	for _, g := range geometries {
+		withDuplicates := []int32{0, 3, 3, 64, 3000, 3001, 3001, 1 << 20, math.MaxInt32 - 1}
+		bs := FromSorted(withDuplicates, WithGeometry(g))
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: FromSorted gave %v", g, got)
+		}
+		if bs.Geometry() != g || bs.Cardinality() != int32(len(want)) {
+			t.Errorf("%v: geometry %v, cardinality %d", g, bs.Geometry(), bs.Cardinality())
+		}
+
+		shuffled := []int32{3001, 1 << 20, 0, 3000, math.MaxInt32 - 1, 64, 3, 3000}
+		keep := append([]int32(nil), shuffled...)
+		if u := FromUnsorted(shuffled, WithGeometry(g)); !u.Equals(bs) {
+			t.Errorf("%v: FromUnsorted gave %v", g, u)
+		}
+		if !reflect.DeepEqual(shuffled, keep) {
+			t.Errorf("%v: FromUnsorted changed its argument to %v", g, shuffled)
+		}
+		/*  Indexes out of order still give the same set. */
+		if s := FromSorted(shuffled, WithGeometry(g)); !s.Equals(bs) {
+			t.Errorf("%v: FromSorted of unsorted indexes gave %v", g, s)
+		}
+		for _, s := range []*BitSet{bs, FromUnsorted(shuffled, WithGeometry(g))} {
+			if err := s.Validate(); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	if bs := FromSorted(nil); !bs.IsEmpty() {
+		t.Errorf("FromSorted(nil) = %v", bs)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("FromSorted of a negative index did not panic")
+		}
+	}()
+	FromSorted([]int32{1, -1})
+}
+
+func TestAppendTo(t *testing.T) {
+	bs := FromSorted([]int32{2, 63, 64, 1 << 20})
+	dst := []int32{-1, -2}
+	got := bs.AppendTo(dst)
+	if want := []int32{-1, -2, 2, 63, 64, 1 << 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendTo = %v, want %v", got, want)
+	}
+	if got := New().ToSlice(); got == nil || len(got) != 0 {
+		t.Errorf("ToSlice of an empty set = %#v", got)
+	}
+	if got := New().AppendTo(dst); !reflect.DeepEqual(got, dst) {
+		t.Errorf("AppendTo of an empty set = %v", got)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"math"
 	"math/bits"
 	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 type wordType = uint64
@@ -12,14 +14,6 @@ type b3DimType [][][]wordType
 type b2DimType [][]wordType
 type b1DimType []wordType
 
-/** An empty level 3 block is kept for use when scanning. When a source block
- *  is needed, and there is not already one in the corresponding bit set, the
- *  ZERO_BLOCK is used (as a read-only block). It is a source of zero values
- *  so that code does not have to test for a null level3 block. This is a
- *  static block shared everywhere.
- */
-var iZeroBlock = make(b1DimType, cLength3)
-
 //BitSet ....
 type BitSet struct {
 	/**
@@ -56,6 +50,53 @@ type BitSet struct {
 	 */
 	bitsLength int32
 
+	/**
+	 *  The number of bytes held by the level1 array, the level2 areas, the
+	 *  level3 blocks and the spare block.
+	 * @see #HeapBytes()
+	 */
+	heapBytes int64
+
+	/**
+	 *  The upper bound for heapBytes, or zero if the set is not limited.
+	 * @see #WithMemoryLimit(int64)
+	 */
+	memoryLimit int64
+
+	/**
+	 *  The widths of the level2 and level3 addresses, and the values derived
+	 *  from them, shared by all the sets of the same geometry.
+	 * @see #WithGeometry(Geometry)
+	 */
+	geo *layout
+
+	/**
+	 *  The interner the blocks of this set are shared through, if any, and
+	 *  the shared blocks the set refers to, with the number of references to
+	 *  each (a set may hold equal blocks in more than one place).
+	 * @see #WithInterner(Interner)
+	 */
+	interner *Interner
+	shared   map[*wordType]int32
+
+	/**
+	 *  The pool level3 blocks are taken from and given back to, if any.
+	 * @see #WithBlockPool(BlockPool)
+	 */
+	pool *BlockPool
+
+	/**
+	 *  The form produced by MarshalText and MarshalJSON.
+	 * @see #WithEncoding(Encoding)
+	 */
+	encoding Encoding
+
+	/**
+	 *  The transaction open on the set, if any.
+	 * @see #Begin()
+	 */
+	txn *Txn
+
 	/**
 	 *  Word and block <b>equals</b> strategy.
 	 */
@@ -135,6 +176,24 @@ type cacheType struct {
 	*  <i>hash</i> value is must be zero for all values to be updated.
 	 */
 	a3Count int32
+
+	/**
+	*  <i>ranks</i> is reset by the <i>statisticsUpdate</i>() method, and
+	*  built by <i>blockRanks</i>() the first time a sample is drawn after
+	*  the update: an entry for each non-empty level3 block, in increasing
+	*  order, with the number of bits set before the block. It is replaced,
+	*  never changed in place, as clones share it.
+	 */
+	ranks []blockRank
+}
+
+/*
+ *  The rank of a level3 block: its index (word index shifted by SHIFT2) and
+ *  the number of bits set in the blocks before it.
+ */
+type blockRank struct {
+	block  int32
+	before int32
 }
 
 //=============================================================================
@@ -148,8 +207,8 @@ type cacheType struct {
  * @since       1.6
  */
 //    public SparseBitSet()
-func New() *BitSet {
-	return newWithSizeAndCompactionCount(1, compactionCountDefault)
+func New(options ...Option) *BitSet {
+	return newWithSizeAndCompactionCount(1, DefaultCompactionCount(), options...)
 }
 
 /**
@@ -169,8 +228,27 @@ func New() *BitSet {
 * @since       1.
  */
 // public SparseBitSet(int nbits) throws NegativeArraySizeException
-func NewWithSize(capacity uint32) *BitSet {
-	return newWithSizeAndCompactionCount(1, compactionCountDefault)
+func NewWithSize(capacity uint32, options ...Option) *BitSet {
+	if capacity > math.MaxInt32 {
+		capacity = math.MaxInt32
+	}
+	return newWithSizeAndCompactionCount(int32(capacity), DefaultCompactionCount(), options...)
+}
+
+// WithCapacity sizes a new set to hold bits 0 through nbits-1 without
+// resizing, as NewWithSize does.
+func WithCapacity(nbits int32) Option {
+	return func(bs *BitSet) {
+		bs.bitsLength = nbits
+	}
+}
+
+// WithCompactionCount gives a new set its own compaction count instead of
+// the package default.
+func WithCompactionCount(count int32) Option {
+	return func(bs *BitSet) {
+		bs.compactionCount = count
+	}
 }
 
 /**
@@ -191,12 +269,24 @@ func NewWithSize(capacity uint32) *BitSet {
  * @since       1.6
  */
 //    protected SparseBitSet(int capacity, int compactionCount) throws NegativeArraySizeException
-func newWithSizeAndCompactionCount(capacity int32, compactionCount int32) *BitSet {
+func newWithSizeAndCompactionCount(capacity int32, compactionCount int32, options ...Option) *BitSet {
+	if capacity < 0 {
+		panic(fmt.Sprintf("NegativeArraySizeException(requested capacity=%v) < 0", capacity))
+	}
+	/*  Until the set is first resized, bitsLength holds the capacity asked
+	for, so that WithCapacity may change it. */
 	result := &BitSet{
 		compactionCount: compactionCount,
+		bitsLength:      capacity,
+		geo:             defaultLayout,
+	}
+	for _, f := range options {
+		f(result)
 	}
-	result.resize(capacity - 1) //  Resize takes last usable index
-	result.compactionCount = compactionCount
+	if result.bitsLength < 0 {
+		panic(fmt.Sprintf("NegativeArraySizeException(requested capacity=%v) < 0", result.bitsLength))
+	}
+	result.resize(result.bitsLength - 1) //  Resize takes last usable index
 	/*  Ensure there is a spare level 3 block for the use of the set scanner.*/
 	result.constructorHelper()
 	result.statisticsUpdate()
@@ -216,17 +306,18 @@ func newWithSizeAndCompactionCount(capacity int32, compactionCount int32) *BitSe
  * @since       1.6
  */
 func (bs *BitSet) GetBit(i int32) bool {
+	g := bs.geo
 	if (i + 1) < 1 {
 		panic(fmt.Sprintf("IndexOutOfBoundsException: i=%v", i))
 	}
 
 	w := i >> cShift3
 	if i < bs.bitsLength {
-		a2 := bs.bits[w>>cShift1]
+		a2 := bs.bits[w>>g.shift1]
 		if a2 != nil {
-			a3 := a2[(w>>cShift2)&(cMask2)]
+			a3 := a2[(w>>g.shift2)&(g.mask2)]
 			if a3 != nil {
-				result := a3[(w&cMask3)] & (wordType(uint(1) << remainderOf64(i)))
+				result := a3[(w&g.mask3)] & (wordType(uint(1) << remainderOf64(i)))
 				return result != 0
 			}
 		}
@@ -250,7 +341,7 @@ func (bs *BitSet) GetBit(i int32) bool {
 
 //GetBitSetFromRange ...
 func (bs *BitSet) GetBitSetFromRange(i, j int32) *BitSet {
-	result := newWithSizeAndCompactionCount(j, bs.compactionCount)
+	result := newWithSizeAndCompactionCount(j, bs.compactionCount, bs.options()...)
 	result.setScanner(i, j, bs, copyStrategy)
 	return result
 }
@@ -284,12 +375,55 @@ func (bs *BitSet) GetBitSetFromRange(i, j int32) *BitSet {
  * @see         Object#equals(Object)
  * @see         java.util.Hashtable
  */
-/*@Override
- func(this *BitSet)  int hashCode()
- {
-	 statisticsUpdate();
-	 return cache.hash;
- }*/
+
+//HashCode ...
+//public int hashCode()
+func (bs *BitSet) HashCode() int32 {
+	bs.statisticsUpdate()
+	return int32(bs.cache.hash)
+}
+
+/**
+ *  Compares this object against the specified object. The result is
+ *  <code>true</code> if and only if the argument is not <code>null</code>
+ *  and is a <code>SparseBitSet</code> object that has exactly the same bits
+ *  set to <code>true</code> as this bit set. That is, for every nonnegative
+ *  <code>i</code> indexing a bit in the set,
+ *  <pre>((SparseBitSet)obj).get(i) == this.get(i)</pre>
+ *  must be true. Sets of different geometries are compared bit by bit.
+ *
+ * @param       b the SparseBitSet with which to compare
+ * @return      <code>true</code> if the objects are equivalent;
+ *              <code>false</code> otherwise.
+ * @since       1.6
+ */
+//Equals ...
+//public boolean equals(Object obj)
+func (bs *BitSet) Equals(b *BitSet) bool {
+	/*  Sanity and quick checks. */
+	if b == nil {
+		return false
+	}
+	if bs == b {
+		return true // Identity
+	}
+	if bs.geo != b.geo {
+		i, j := bs.NextSetBit(0), b.NextSetBit(0)
+		for i == j && i >= 0 {
+			i, j = bs.NextSetBit(i+1), b.NextSetBit(j+1)
+		}
+		return i == j
+	}
+
+	/*  Do the real work.  */
+	bmax := bs.bitsLength
+	if b.bitsLength > bmax {
+		bmax = b.bitsLength
+	}
+	s := new(equalsStrategyType)
+	bs.setScanner(0, bmax, b, s)
+	return s.result
+}
 
 /**
  *  Returns true if the specified <code>SparseBitSet</code> has any bits
@@ -385,6 +519,7 @@ func (bs *BitSet) Size() int32 {
  * @since       1.6
  */
 func (bs *BitSet) NextClearBit(i int32) int32 {
+	g := bs.geo
 	/*  The index of this method is permitted to be Integer.MAX_VALUE, as this
 	is needed to make this method work together with the method
 	nextSetBit()--as might happen if a search for the next clear bit is
@@ -398,9 +533,9 @@ func (bs *BitSet) NextClearBit(i int32) int32 {
 
 	/*  This is the word from which the search begins. */
 	w := i >> cShift3
-	w3 := w & cMask3
-	w2 := (w >> cShift2) & cMask2
-	w1 := w >> cShift1
+	w3 := w & g.mask3
+	w2 := (w >> g.shift2) & g.mask2
+	w1 := w >> g.shift1
 
 	nword := wordType(^uint64(0) << remainderOf64(i))
 	aLength := int32(len(bs.bits))
@@ -414,21 +549,22 @@ func (bs *BitSet) NextClearBit(i int32) int32 {
 			if a3 := a2[w2]; a3 != nil {
 				if nword = ^a3[w3] & wordType(^uint64(0)<<remainderOf64(i)); nword == 0 {
 					w++
-					w3 = w & cMask3
-					w2 = (w >> cShift2) & cMask2
-					w1 = w >> cShift1
+					w3 = w & g.mask3
+					w2 = (w >> g.shift2) & g.mask2
+					w1 = w >> g.shift1
 					nword = ^wordType(0)
 				loop:
 					for ; w1 != aLength; w1++ {
 						if a2 = bs.bits[w1]; a2 == nil {
 							break
 						}
-						for ; w2 != cLength2; w2++ {
+						for ; w2 != g.length2; w2++ {
 							if a3 = a2[w2]; a3 == nil {
 								break loop
 							}
-							for ; w3 != cLength3; w3++ {
-								if nword = ^a3[w3]; nword != 0 {
+							for ; w3 != g.length3; w3++ {
+								if word := ^a3[w3]; word != 0 {
+									nword = word
 									break loop
 								}
 							}
@@ -445,7 +581,7 @@ func (bs *BitSet) NextClearBit(i int32) int32 {
 		complemented value). */
 
 	}
-	result := (((w1 << cShift1) + (w2 << cShift2) + w3) << cShift3) + int32(bits.TrailingZeros(uint(nword)))
+	result := (((w1 << g.shift1) + (w2 << g.shift2) + w3) << cShift3) + int32(bits.TrailingZeros(uint(nword)))
 	if result == math.MaxInt32 {
 		return -1
 	}
@@ -473,6 +609,7 @@ func (bs *BitSet) NextClearBit(i int32) int32 {
  */
 
 func (bs *BitSet) NextSetBit(i int32) int32 {
+	g := bs.geo
 	/*  The index value (i) of this method is permitted to be Integer.MAX_VALUE,
 	as this is needed to make the loop defined above work: just in case the
 	bit labelled Integer.MAX_VALUE-1 is set. This case is not optimised:
@@ -484,9 +621,9 @@ func (bs *BitSet) NextSetBit(i int32) int32 {
 	}
 	/*  This is the word from which the search begins. */
 	w := i >> cShift3
-	w3 := w & cMask3
-	w2 := (w >> cShift2) & cMask2
-	w1 := w >> cShift1
+	w3 := w & g.mask3
+	w2 := (w >> g.shift2) & g.mask2
+	w1 := w >> g.shift1
 
 	word := wordType(0)
 	aLength := int32(len(bs.bits))
@@ -508,31 +645,31 @@ func (bs *BitSet) NextSetBit(i int32) int32 {
 		if result {
 			/*  So now start a search though the rest of the entries for a bit. */
 			w++
-			w3 = w & cMask3
-			w2 = (w >> cShift2) & cMask2
-			w1 = w >> cShift1
+			w3 = w & g.mask3
+			w2 = (w >> g.shift2) & g.mask2
+			w1 = w >> g.shift1
 		major:
 			for ; w1 != aLength; w1++ {
 				if a2 = bs.bits[w1]; a2 != nil {
-					for ; w2 != cLength2; w2++ {
+					for ; w2 != g.length2; w2++ {
 						if a3 = a2[w2]; a3 != nil {
-							for ; w3 != cLength3; w3++ {
+							for ; w3 != g.length3; w3++ {
 								if word = a3[w3]; word != 0 {
 									break major
 								}
 							}
-							w3 = 0
 						}
+						w3 = 0
 					}
-					w2, w3 = 0, 0
 				}
+				w2, w3 = 0, 0
 			}
 		}
 	}
 	if w1 >= aLength {
 		return -1
 	}
-	return (((w1 << cShift1) + (w2 << cShift2) + w3) << cShift3) + int32(bits.TrailingZeros(uint(word)))
+	return (((w1 << g.shift1) + (w2 << g.shift2) + w3) << cShift3) + int32(bits.TrailingZeros(uint(word)))
 
 }
 
@@ -551,6 +688,7 @@ func (bs *BitSet) NextSetBit(i int32) int32 {
  * @see java.util.BitSet#previousClearBit
  */
 func (bs *BitSet) PreviousClearBit(i int32) int32 {
+	g := bs.geo
 	if i < 0 {
 		panic(fmt.Sprintf("IndexOutOfBoundsException(i=%v)", i))
 	}
@@ -559,9 +697,9 @@ func (bs *BitSet) PreviousClearBit(i int32) int32 {
 	aSize := int32(len(bs.bits) - 1)
 
 	w := i >> cShift3
-	w3 := w & cMask3
-	w2 := (w >> cShift2) & cMask2
-	w1 := w >> cShift1
+	w3 := w & g.mask3
+	w2 := (w >> g.shift2) & g.mask2
+	w1 := w >> g.shift1
 	if w1 > aSize {
 		return i
 	}
@@ -577,26 +715,26 @@ func (bs *BitSet) PreviousClearBit(i int32) int32 {
 
 	for ; w1 >= 0; w1-- {
 		if a2 = bits[w1]; a2 == nil {
-			return (((w1 << cShift1) + (w2 << cShift2) + w3) << cShift3) + w4
+			return (((w1 << g.shift1) + (w2 << g.shift2) + w3) << cShift3) + w4
 		}
 		for ; w2 >= 0; w2-- {
 			if a3 = a2[w2]; a3 == nil {
-				return (((w1 << cShift1) + (w2 << cShift2) + w3) << cShift3) + w4
+				return (((w1 << g.shift1) + (w2 << g.shift2) + w3) << cShift3) + w4
 			}
 			for ; w3 >= 0; w3-- {
 				if word = a3[w3]; word == 0 {
-					return (((w1 << cShift1) + (w2 << cShift2) + w3) << cShift3) + w4
+					return (((w1 << g.shift1) + (w2 << g.shift2) + w3) << cShift3) + w4
 				}
 				for bitIdx := w4; bitIdx >= 0; bitIdx-- {
 					if t := word & (1 << uint(bitIdx)); t == 0 {
-						return (((w1 << cShift1) + (w2 << cShift2) + w3) << cShift3) + bitIdx
+						return (((w1 << g.shift1) + (w2 << g.shift2) + w3) << cShift3) + bitIdx
 					}
 				}
 				w4 = cLength4Size
 			}
-			w3 = cLength3Size
+			w3 = g.length3Size
 		}
-		w2 = cLength2Size
+		w2 = g.length2Size
 	}
 	return -1
 }
@@ -617,6 +755,7 @@ func (bs *BitSet) PreviousClearBit(i int32) int32 {
  */
 
 func (bs *BitSet) PreviousSetBit(i int32) int32 {
+	g := bs.geo
 	if i < 0 {
 		panic(fmt.Sprintf("IndexOutOfBoundsException(i=%v)", i))
 	}
@@ -625,17 +764,17 @@ func (bs *BitSet) PreviousSetBit(i int32) int32 {
 
 	/*  This is the word from which the search begins. */
 	w := i >> cShift3
-	w1 := w >> cShift1
+	w1 := w >> g.shift1
 	var w2, w3, w4 int32
 	/*  But if its off the end of the array, start from the very end. */
 	if w1 > aSize {
 		w1 = aSize
-		w2 = cLength2Size
-		w3 = cLength3Size
+		w2 = g.length2Size
+		w3 = g.length3Size
 		w4 = cLength4Size
 	} else {
-		w2 = (w >> cShift2) & cMask2
-		w3 = w & cMask3
+		w2 = (w >> g.shift2) & g.mask2
+		w3 = w & g.mask3
 		w4 = i % cLength4
 	}
 	word := wordType(0)
@@ -650,19 +789,19 @@ func (bs *BitSet) PreviousSetBit(i int32) int32 {
 						if word = a3[w3]; word != 0 {
 							for bitIdx := w4; bitIdx >= 0; bitIdx-- {
 								if t := word & (1 << remainderOf64(bitIdx)); t != 0 {
-									return (((w1 << cShift1) + (w2 << cShift2) + w3) << cShift3) + bitIdx
+									return (((w1 << g.shift1) + (w2 << g.shift2) + w3) << cShift3) + bitIdx
 								}
 							}
 						}
 						w4 = cLength4Size
 					}
 				}
-				w3 = cLength3Size
+				w3 = g.length3Size
 				w4 = cLength4Size
 			}
 		}
-		w2 = cLength2Size
-		w3 = cLength3Size
+		w2 = g.length2Size
+		w3 = g.length3Size
 		w4 = cLength4Size
 	}
 	return -1
@@ -710,47 +849,66 @@ func (bs *BitSet) ClearAll() {
  * @see         #toStringCompaction(int length)
  * @since       1.6
  */
-func (bs BitSet) String() string {
-	var p = "{"
-	i := bs.NextSetBit(0)
-	/*  Loop so long as there is another bit to append to the String. */
-	for i >= 0 {
-		/*  Append that next bit */
-		p += fmt.Sprintf("%v", i)
-		/*  Find the position of the next bit to show. */
-		j := bs.NextSetBit(i + 1)
-		if bs.compactionCount > 0 {
-			/*  Give up if there is no next bit to show. */
-			if j < 0 {
-				break
-			}
-			/*  Find the next clear bit is after the current bit, i.e., i */
-			last := bs.NextClearBit(i)
-			/*  Compute the position of the next clear bit after the current
-			subsequence of set bits. */
-			if last < 0 {
-				last = math.MaxInt32
-			}
-			/*  If the subsequence is more than the specified bits long, then
-			collapse the subsequence into one entry in the String. */
-			if (i + bs.compactionCount) < last {
-				p += fmt.Sprintf("..%v", last-1)
-				/*  Having accounted for a subsequence of bits that are all set,
-				recompute the label of the next bit to show. */
-				j = bs.NextSetBit(last)
-			}
-		}
-		/*  If there is another set bit, put a comma and a space after the
-		last entry in the String.  */
-		if j >= 0 {
-			p += ","
-		}
-		/*  Transfer to i the index of the next set bit. */
-		i = j
+func (bs *BitSet) String() string {
+	var sb strings.Builder
+	bs.writeRanges(&sb, bs.compactionCount)
+	return sb.String()
+}
+
+/** Sequences of set bits longer than this value are shown by
+ *  {@link #toString()} as a "sub-sequence," in the form <code>a..b</code>.
+ *  Setting this value to zero causes each set bit to be listed individually.
+ *  The default default value is 2 (which means sequences of three or more
+ *  bits set are shown as a subsequence, and all other set bits are listed
+ *  individually).
+ *  <p>
+ *  Note: this value will be passed to <code>SparseBitSet</code>s that
+ *  may be created within or as a result of the operations on this bit set,
+ *  or, for static methods, from the value belonging to the first parameter.
+ *
+ * @param       count the maximum count of a run of bits that are shown as
+ *              individual entries in a <code>toString</code>() conversion.
+ *              If 0, all bits are shown individually.
+ * @since       1.6
+ * @see         #toString()
+ */
+//ToStringCompaction ...
+//public void toStringCompaction(int count)
+func (bs *BitSet) ToStringCompaction(count int32) {
+	bs.compactionCount = count
+}
+
+/**
+ *  If <i>change</i> is <code>true</code>, the current value of the
+ *  <i>toStringCompaction</i>() value is made the default value for all
+ *  <code>SparseBitSet</code>s created from this point onward in this
+ *  process.
+ *
+ * @param       change if true, change the default value
+ * @since       1.6
+ */
+//ToStringCompactionBool ...
+//public void toStringCompaction(boolean change)
+func (bs *BitSet) ToStringCompactionBool(change bool) {
+	if change {
+		SetDefaultCompactionCount(bs.compactionCount)
 	}
-	/*  Terminate the representational String, and return it. */
-	p += "}"
-	return p
+}
+
+// DefaultCompactionCount returns the compaction count given to new sets.
+func DefaultCompactionCount() int32 {
+	return atomic.LoadInt32(&compactionCountDefault)
+}
+
+// SetDefaultCompactionCount sets the compaction count given to sets created
+// from now on without WithCompactionCount. Existing sets keep their count.
+func SetDefaultCompactionCount(count int32) {
+	atomic.StoreInt32(&compactionCountDefault, count)
+}
+
+// CompactionCount returns the compaction count of the set.
+func (bs *BitSet) CompactionCount() int32 {
+	return bs.compactionCount
 }
 
 /**
@@ -797,6 +955,7 @@ func (bs *BitSet) StatisticsAll() string {
  * @since       1.6
  */
 func (bs *BitSet) Statistics(values []string) string {
+	g := bs.geo
 	bs.statisticsUpdate() //  Ensure statistics are up-to-date
 	v := make([]string, Statistics_Values_Length)
 	/*  Assign the statistics values to the appropriate entry. The order
@@ -807,26 +966,24 @@ func (bs *BitSet) Statistics(values []string) string {
 	v[Cardinality] = strconv.Itoa(int(bs.Cardinality()))
 	v[Total_words] = strconv.Itoa(int(bs.cache.count))
 	v[Set_array_length] = strconv.Itoa(len(bs.bits))
-	v[Set_array_max_length] = strconv.Itoa(int(cMaxLength1))
+	v[Set_array_max_length] = strconv.Itoa(int(g.maxLength1))
 	v[Level2_areas] = strconv.Itoa(int(bs.cache.a2Count))
-	v[Level2_area_length] = strconv.Itoa(int(cLength2))
+	v[Level2_area_length] = strconv.Itoa(int(g.length2))
 	v[Level3_blocks] = strconv.Itoa(int(bs.cache.a3Count))
-	v[Level3_block_length] = strconv.Itoa(int(cLength3))
+	v[Level3_block_length] = strconv.Itoa(int(g.length3))
 	v[Compaction_count_value] = strconv.Itoa(int(bs.compactionCount))
+	distinct, refs := bs.sharedBlocks()
+	v[Level3_physical_blocks] = strconv.Itoa(int(bs.cache.a3Count - refs + distinct))
 
-	/*  Determine the longest label, so that the equal signs may be lined-up. */
-	for i := range values {
-		if i < len(v) {
-			values[i] = v[i]
-		}
-	}
+	/*  Pass the individual values to the caller, if wanted. */
+	copy(values, v)
 
 	/*  Build a String that has for each statistic, the name of the statistic,
 	padding, and equals sign, and the value. The "Load_factor_value",
 	"Average_length_value", and "Average_chain_length" are printed as
 	floating point values. */
 	var kvs string
-	for i, s := range values {
+	for i, s := range v {
 		st := StatisticsType(i)
 		kvs = kvs + st.String() + " = " + s + "\n"
 	}
@@ -0,0 +1,240 @@
+package sparse
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+var geometries = []Geometry{DefaultGeometry, VerySparseGeometry, ClusteredGeometry}
+
+func setOf(g Geometry, ranges ...[2]int32) *BitSet {
+	bs := New(WithGeometry(g))
+	for _, r := range ranges {
+		bs.SetRange(r[0], r[1])
+	}
+	return bs
+}
+
+func rangeSlice(i, j int32) (result []int32) {
+	for ; i < j; i++ {
+		result = append(result, i)
+	}
+	return
+}
+
+// TestScannerPartialBlocks covers scans that start or end inside a block,
+// where every word of the block must be visited.
+func TestScannerPartialBlocks(t *testing.T) {
+	for _, g := range geometries {
+		/*  Or into an empty set: the first word is not zero, and the words
+		after it must still be or'ed. */
+		bs := New(WithGeometry(g))
+		bs.OrRangeBitSet(0, 100, setOf(g, [2]int32{0, 200}))
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, rangeSlice(0, 100)) {
+			t.Errorf("%v: OrRangeBitSet(0, 100) = %v", g, bs)
+		}
+		if bs = setOf(g, [2]int32{10, 1000}); bs.Cardinality() != 990 {
+			t.Errorf("%v: SetRange(10, 1000) = %v", g, bs)
+		}
+		/*  A range ending on a word boundary includes the whole last word. */
+		bs = setOf(g, [2]int32{0, 200})
+		bs.ClearRange(0, 128)
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, rangeSlice(128, 200)) {
+			t.Errorf("%v: ClearRange(0, 128) = %v", g, bs)
+		}
+		bs = setOf(g, [2]int32{0, 200})
+		bs.FlipRange(64, 192)
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, append(rangeSlice(0, 64), rangeSlice(192, 200)...)) {
+			t.Errorf("%v: FlipRange(64, 192) = %v", g, bs)
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestAndStrategy(t *testing.T) {
+	for _, g := range geometries {
+		bs := setOf(g, [2]int32{1, 4}, [2]int32{100, 101})
+		bs.AndRangeBitSet(0, 3, setOf(g, [2]int32{2, 3}))
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{2, 3, 100}) {
+			t.Errorf("%v: AndRangeBitSet(0, 3) = %v, want {2, 3, 100}", g, bs)
+		}
+		/*  Areas of the receiver the argument has none of are dropped. */
+		bs = setOf(g, [2]int32{1, 4}, [2]int32{1 << 20, 1<<20 + 3})
+		bs.AndBitSet(setOf(g, [2]int32{2, 5}, [2]int32{1 << 24, 1<<24 + 1}))
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{2, 3}) {
+			t.Errorf("%v: AndBitSet = %v, want {2, 3}", g, bs)
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestXorStrategy(t *testing.T) {
+	for _, g := range geometries {
+		bs := setOf(g, [2]int32{5, 6}, [2]int32{1 << 20, 1<<20 + 1})
+		bs.XorBitSet(bs.Clone())
+		/*  The blocks that became zero are dropped by the operation itself,
+		before any statistics update. */
+		if heap := int64(len(bs.bits))*cLevel1EntryBytes + layoutOf(g).block3Bytes; bs.HeapBytes() != heap {
+			t.Errorf("%v: %d bytes held after xor, want %d", g, bs.HeapBytes(), heap)
+		}
+		if !bs.IsEmpty() || bs.Length() != 0 {
+			t.Errorf("%v: a set xor'ed with itself is %v", g, bs)
+		}
+		var values [Statistics_Values_Length]string
+		bs.Statistics(values[:])
+		if values[Level3_blocks] != "0" || values[Level2_areas] != "0" {
+			t.Errorf("%v: %s level3 blocks, %s level2 areas left", g, values[Level3_blocks], values[Level2_areas])
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestResize sets bits far beyond the initial level1 array.
+func TestResize(t *testing.T) {
+	for _, g := range geometries {
+		bs := New(WithGeometry(g))
+		want := []int32{0, 1 << 16, 1 << 20, 1 << 30, math.MaxInt32 - 1}
+		for _, i := range want {
+			bs.Set(i)
+		}
+		for _, i := range want {
+			if !bs.GetBit(i) {
+				t.Errorf("%v: bit %d not set", g, i)
+			}
+		}
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: bits %v, want %v", g, got, want)
+		}
+		if bs.Length() != math.MaxInt32 {
+			t.Errorf("%v: Length() = %d", g, bs.Length())
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+
+		/*  The level1 array grows to the next power of two holding the bit. */
+		bs = New(WithGeometry(g))
+		bs.Set(1 << 20)
+		if want := 2 * (1 << 20) / layoutOf(g).unit; int32(len(bs.bits)) != want {
+			t.Errorf("%v: level1 length %d for bit 1 << 20, want %d", g, len(bs.bits), want)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	for _, g := range geometries {
+		bs := setOf(g, [2]int32{3, 300}, [2]int32{1 << 20, 1<<20 + 5})
+		c := bs.Clone()
+		if !c.Equals(bs) || c.Geometry() != g {
+			t.Fatalf("%v: clone %v of %v", g, c, bs)
+		}
+		c.Set(7000)
+		c.Clear(3)
+		if bs.GetBit(7000) || !bs.GetBit(3) {
+			t.Errorf("%v: changing the clone changed the original to %v", g, bs)
+		}
+		for _, s := range []*BitSet{bs, c} {
+			if err := s.Validate(); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestFlipBitNewArea(t *testing.T) {
+	for _, g := range geometries {
+		bs := New(WithGeometry(g))
+		bs.FlipBit(1 << 20)
+		if !bs.GetBit(1<<20) || bs.Cardinality() != 1 {
+			t.Errorf("%v: FlipBit(1 << 20) gave %v", g, bs)
+		}
+		bs.FlipBit(1 << 20)
+		if !bs.IsEmpty() {
+			t.Errorf("%v: flipped twice gave %v", g, bs)
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestNextBits(t *testing.T) {
+	for _, g := range geometries {
+		l := layoutOf(g)
+		block := l.length3 * cLength4 //  Bits in a level3 block
+		/*  Bits separated by missing blocks, and by a missing area. */
+		want := []int32{5, 3*block + 7, l.unit + 1}
+		bs := New(WithGeometry(g))
+		for _, i := range want {
+			bs.Set(i)
+		}
+		var got []int32
+		for i := bs.NextSetBit(0); i >= 0; i = bs.NextSetBit(i + 1) {
+			got = append(got, i)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: NextSetBit walk %v, want %v", g, got, want)
+		}
+
+		/*  A full block followed by a missing one. */
+		bs = setOf(g, [2]int32{0, block})
+		if i := bs.NextClearBit(0); i != block {
+			t.Errorf("%v: NextClearBit(0) = %d, want %d", g, i, block)
+		}
+		bs = setOf(g, [2]int32{0, 2 * block}, [2]int32{2*block + 1, 3 * block})
+		if i := bs.NextClearBit(10); i != 2*block {
+			t.Errorf("%v: NextClearBit(10) = %d, want %d", g, i, 2*block)
+		}
+	}
+}
+
+func TestWithCapacity(t *testing.T) {
+	for _, g := range geometries {
+		l := layoutOf(g)
+		bs := New(WithGeometry(g), WithCapacity(1<<20))
+		sized := NewWithSize(1<<20, WithGeometry(g))
+		if len(bs.bits) != len(sized.bits) || int32(len(bs.bits))*l.unit < 1<<20 {
+			t.Errorf("%v: level1 length %d, NewWithSize gives %d", g, len(bs.bits), len(sized.bits))
+		}
+		/*  The last bit of the capacity needs no larger level1 array. */
+		length := len(bs.bits)
+		bs.Set(1<<20 - 1)
+		if len(bs.bits) != length {
+			t.Errorf("%v: level1 resized from %d to %d within the capacity", g, length, len(bs.bits))
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestWithCompactionCount(t *testing.T) {
+	defer SetDefaultCompactionCount(DefaultCompactionCount())
+	SetDefaultCompactionCount(0)
+	bs := New(WithCompactionCount(3))
+	listed := New()
+	for _, s := range []*BitSet{bs, listed} {
+		s.SetRange(1, 5)
+		s.SetRange(6, 9)
+	}
+	if bs.CompactionCount() != 3 || listed.CompactionCount() != 0 {
+		t.Errorf("compaction counts %d and %d", bs.CompactionCount(), listed.CompactionCount())
+	}
+	if got := bs.String(); got != "{1..4,6,7,8}" {
+		t.Errorf("String() = %s with a compaction count of 3", got)
+	}
+	if got := listed.String(); got != "{1,2,3,4,6,7,8}" {
+		t.Errorf("String() = %s with a compaction count of 0", got)
+	}
+	SetDefaultCompactionCount(2)
+	if listed.CompactionCount() != 0 || bs.GetBitSetFromRange(0, 10).CompactionCount() != 3 {
+		t.Error("the compaction count of a set changed with the default")
+	}
+}
@@ -57,7 +57,8 @@ const (
 	 */
 	Level2_area_length // 7
 	/**
-	 *  The total number of level3 blocks in use.
+	 *  The total number of level3 blocks in use (the logical count, counting
+	 *  a block shared through an interner once for every place it is used).
 	 */
 	Level3_blocks // 8
 	/**
@@ -70,6 +71,12 @@ const (
 	 * @see         #toStringCompaction(int)
 	 */
 	Compaction_count_value // 10
+	/**
+	 *  The number of distinct level3 blocks the set refers to (the physical
+	 *  count): the private blocks, and the shared blocks counted once each.
+	 * @see         #Intern()
+	 */
+	Level3_physical_blocks // 11
 	//
 	Statistics_Values_Length
 )
@@ -98,6 +105,8 @@ func (st StatisticsType) String() string {
 		return "Level3-block-length"
 	case Compaction_count_value:
 		return "Compaction-count-value"
+	case Level3_physical_blocks:
+		return "Level3-physical-blocks"
 	default:
 		panic(fmt.Sprintf("Unknown statistics value %d", st))
 	}
@@ -66,7 +66,7 @@ var xorStrategy = new(xorStrategyType)
 type andStrategyType struct{}
 
 func (st andStrategyType) properties() int32 {
-	return cFalseOpFalseEqFalse + cValueOpFalseEqFalse + cValueOpFalseEqFalse
+	return cFalseOpFalseEqFalse + cFalseOpValueEqFalse + cValueOpFalseEqFalse
 }
 
 func (st andStrategyType) start(b *BitSet) bool {
@@ -77,7 +77,7 @@ func (st andStrategyType) start(b *BitSet) bool {
 }
 
 func (st andStrategyType) word(base, u3 int32, a3, b3 b1DimType, mask wordType) bool {
-	a3[u3] = a3[u3]&b3[u3] | ^mask
+	a3[u3] = a3[u3] & (b3[u3] | ^mask)
 	return a3[u3] == 0
 }
 
@@ -165,7 +165,7 @@ func (st clearStrategyType) word(base, u3 int32, a3, b3 b1DimType, mask wordType
 }
 
 func (st clearStrategyType) block(base, u3, v3 int32, a3, b3 b1DimType) (isZero bool) {
-	if u3 != 0 || v3 != cLength3 {
+	if u3 != 0 || v3 != int32(len(a3)) {
 		for w3 := u3; w3 != v3; w3 = w3 + 1 {
 			a3[w3] = 0
 		}
@@ -225,7 +225,7 @@ type equalsStrategyType struct {
 }
 
 func (st equalsStrategyType) properties() int32 {
-	return cFalseOpFalseEqFalse
+	return cFalseOpFalseEqFalse + cReadsOnly
 }
 
 func (st *equalsStrategyType) start(b *BitSet) bool {
@@ -309,7 +309,7 @@ type intersectsStrategyType struct {
 }
 
 func (st intersectsStrategyType) properties() int32 {
-	return cFalseOpFalseEqFalse + cFalseOpValueEqFalse
+	return cFalseOpFalseEqFalse + cFalseOpValueEqFalse + cReadsOnly
 }
 
 func (st *intersectsStrategyType) start(b *BitSet) bool {
@@ -480,7 +480,7 @@ type updateStrategyType struct {
 }
 
 func (st updateStrategyType) properties() int32 {
-	return cFalseOpFalseEqFalse + cFalseOpValueEqFalse
+	return cFalseOpFalseEqFalse + cFalseOpValueEqFalse + cReadsOnly
 }
 
 func (st *updateStrategyType) start(b *BitSet) bool {
@@ -522,6 +522,7 @@ func (st *updateStrategyType) finish(cache *cacheType, a2Count, a3Count int32) {
 	cache.length = (st.wMax+1)*cLength4 - int32(bits.LeadingZeros(uint(st.wordMax)))
 	cache.size = cache.length - st.wMin*cLength4 - int32(bits.LeadingZeros(uint(st.wordMin)))
 	cache.hash = ((st.hash >> cIntegerSize) ^ st.hash)
+	cache.ranks = nil //  Ranked again when next needed
 }
 
 func (st *updateStrategyType) compute(index int32, word wordType) {
@@ -567,11 +568,11 @@ func (st xorStrategyType) word(base, u3 int32, a3, b3 b1DimType, mask wordType)
 }
 
 func (st xorStrategyType) block(base, u3, v3 int32, a3, b3 b1DimType) (isZero bool) {
+	isZero = true
 	for w3 := u3; w3 != v3; w3 = w3 + 1 {
 		a3[w3] = a3[w3] ^ b3[w3]
 		isZero = isZero && a3[w3] == 0
 	}
-	isZero = false
 	return
 }
 func (st xorStrategyType) finish(cache *cacheType, a2Count, a3Count int32) {}
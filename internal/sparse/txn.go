@@ -0,0 +1,191 @@
+package sparse
+
+import "fmt"
+
+/*Txn ...
+ *  A group of single-bit changes to a BitSet that is applied as a whole or
+ *  not at all. The changes are made directly in the set, but before a level3
+ *  block is written to for the first time a copy of it is kept (copy on first
+ *  write), so that Rollback can put the original blocks back.
+ *  <p>
+ *  While a transaction is open the set must be changed only through it. Reads
+ *  see the pending bits, but the cached statistics (cardinality, length, etc.)
+ *  keep describing the set as it was at Begin; they are invalidated once, by
+ *  Commit, instead of once per changed bit. Sample and RandomSetBit rank the
+ *  blocks afresh while a transaction is open, so they draw from the pending
+ *  bits.
+ *  <p>
+ *  Set, SetBit and FlipBit panic with a *MemoryLimitError when the set would
+ *  go over its memory limit; TrySet, TrySetBit and TryFlipBit return it
+ *  instead. Either way the bit is left as it was, and the transaction may go
+ *  on.
+ */
+type Txn struct {
+	bs *BitSet
+	/*  Original contents of the touched level3 blocks, keyed by block index
+	(the word index shifted by SHIFT2). A nil value records a block that
+	did not exist before the transaction. */
+	blocks map[int32]b1DimType
+	/*  Level1 indexes of the level2 areas created by the transaction. */
+	areas []int32
+	done  bool
+}
+
+// Begin starts a transaction on the bit set.
+func (bs *BitSet) Begin() *Txn {
+	/*  Bring the statistics up to date now, so that no scan normalizes the
+	set (dropping blocks the transaction refers to) until it finishes. */
+	bs.statisticsUpdate()
+	bs.txn = &Txn{
+		bs:     bs,
+		blocks: make(map[int32]b1DimType),
+	}
+	return bs.txn
+}
+
+/*
+ *  Returns the level3 block holding bit i, saving its original content the
+ *  first time it is touched. When create is false nil is returned for a
+ *  block that does not exist, otherwise the block (and its level2 area, and
+ *  room in the level1 array) is created.
+ */
+func (tx *Txn) block(i int32, create bool) b1DimType {
+	if tx.done {
+		panic("sparse: transaction has already been committed or rolled back")
+	}
+	if i < 0 {
+		panic(fmt.Sprintf("IndexOutOfBoundsException(i=%v)", i))
+	}
+	bs := tx.bs
+	g := bs.geo
+	w := i >> cShift3
+	w1 := w >> g.shift1
+	w2 := (w >> g.shift2) & g.mask2
+	if i >= bs.bitsLength {
+		if !create {
+			return nil
+		}
+		bs.resize(i)
+	}
+	a2 := bs.bits[w1]
+	if a2 == nil {
+		if !create {
+			return nil
+		}
+		a2 = bs.newArea()
+		bs.bits[w1] = a2
+		tx.areas = append(tx.areas, w1)
+	}
+	a3 := a2[w2]
+	if a3 == nil && !create {
+		return nil
+	}
+	key := w >> g.shift2
+	if _, seen := tx.blocks[key]; !seen {
+		var saved b1DimType
+		if a3 != nil {
+			saved = make(b1DimType, g.length3)
+			copy(saved, a3)
+		}
+		tx.blocks[key] = saved
+	}
+	if a3 != nil {
+		a3 = bs.writable(a2, w2)
+	} else {
+		a3 = bs.newBlock()
+		a2[w2] = a3
+	}
+	return a3
+}
+
+// Set sets the bit at the specified index within the transaction.
+func (tx *Txn) Set(i int32) {
+	g := tx.bs.geo
+	a3 := tx.block(i, true)
+	a3[(i>>cShift3)&g.mask3] |= wordType(uint(1) << remainderOf64(i))
+}
+
+// Clear clears the bit at the specified index within the transaction.
+func (tx *Txn) Clear(i int32) {
+	g := tx.bs.geo
+	if a3 := tx.block(i, false); a3 != nil {
+		a3[(i>>cShift3)&g.mask3] &= ^wordType(uint(1) << remainderOf64(i))
+	}
+}
+
+// SetBit sets the bit at the specified index to value within the transaction.
+func (tx *Txn) SetBit(i int32, value bool) {
+	if value {
+		tx.Set(i)
+	} else {
+		tx.Clear(i)
+	}
+}
+
+// FlipBit complements the bit at the specified index within the transaction.
+func (tx *Txn) FlipBit(i int32) {
+	g := tx.bs.geo
+	a3 := tx.block(i, true)
+	a3[(i>>cShift3)&g.mask3] ^= wordType(uint(1) << remainderOf64(i))
+}
+
+// TrySet is Set returning an error instead of going over the memory limit.
+func (tx *Txn) TrySet(i int32) error {
+	return tx.bs.checked(func() { tx.Set(i) })
+}
+
+// TrySetBit is SetBit returning an error instead of going over the memory
+// limit.
+func (tx *Txn) TrySetBit(i int32, value bool) error {
+	return tx.bs.checked(func() { tx.SetBit(i, value) })
+}
+
+// TryFlipBit is FlipBit returning an error instead of going over the memory
+// limit.
+func (tx *Txn) TryFlipBit(i int32) error {
+	return tx.bs.checked(func() { tx.FlipBit(i) })
+}
+
+// Commit keeps the changes made within the transaction and invalidates the
+// statistics of the set. Committing a finished transaction does nothing.
+func (tx *Txn) Commit() {
+	if tx.done {
+		return
+	}
+	tx.done = true
+	tx.bs.txn = nil
+	if len(tx.blocks) != 0 {
+		tx.bs.cache.hash = 0 //  Invalidate size, etc., values once
+	}
+	tx.blocks = nil
+	tx.areas = nil
+}
+
+// Rollback puts back the original contents of every block changed within
+// the transaction. Rolling back a finished transaction does nothing, so it
+// may be deferred right after Begin.
+func (tx *Txn) Rollback() {
+	if tx.done {
+		return
+	}
+	tx.done = true
+	bs := tx.bs
+	bs.txn = nil
+	g := bs.geo
+	for key, saved := range tx.blocks {
+		a2 := bs.bits[key>>g.Level2]
+		bs.dropBlock(a2, key&g.mask2)
+		if saved != nil {
+			a2[key&g.mask2] = saved
+			bs.heapBytes += g.block3Bytes
+		}
+	}
+	for _, w1 := range tx.areas {
+		bs.dropArea(w1)
+	}
+	/*  The content is the same as at Begin, so the statistics computed then
+	are still valid. The level1 array may have been enlarged; it is left
+	so, as that does not change the value of the set. */
+	tx.blocks = nil
+	tx.areas = nil
+}
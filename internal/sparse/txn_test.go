@@ -0,0 +1,92 @@
+package sparse
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTxnMemoryLimit(t *testing.T) {
+	g := defaultLayout
+	/*  Room for one level2 area and one level3 block beyond an empty set. */
+	limit := New().HeapBytes() + g.area2Bytes + g.block3Bytes
+	bs := New(WithMemoryLimit(limit))
+
+	tx := bs.Begin()
+	if err := tx.TrySet(1); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int32{1 << 20, 5000} {
+		if err := tx.TrySet(i); !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("TrySet(%d): err = %v, want ErrMemoryLimit", i, err)
+		}
+	}
+	if err := tx.TrySetBit(3, true); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{1, 3}) {
+		t.Fatalf("committed bits %v, want [1 3]", got)
+	}
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx = bs.Begin()
+	tx.Clear(1)
+	if err := tx.TryFlipBit(5000); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("TryFlipBit: err = %v, want ErrMemoryLimit", err)
+	}
+	tx.Rollback()
+	if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{1, 3}) {
+		t.Fatalf("bits %v after rollback, want [1 3]", got)
+	}
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxnSample(t *testing.T) {
+	bs := New()
+	bs.Set(5)
+	bs.RandomSetBit(rand.New(rand.NewSource(1))) //  Ranks the block of bit 5
+
+	tx := bs.Begin()
+	tx.Clear(5)
+	tx.Set(1 << 20)
+	rng := rand.New(rand.NewSource(1))
+	if i := bs.RandomSetBit(rng); i != 1<<20 {
+		t.Errorf("RandomSetBit = %d within the transaction, want %d", i, 1<<20)
+	}
+	if got := bs.Sample(5, rng); !reflect.DeepEqual(got, []int32{1 << 20}) {
+		t.Errorf("Sample = %v within the transaction, want [%d]", got, 1<<20)
+	}
+	tx.Rollback()
+	if i := bs.RandomSetBit(rng); i != 5 {
+		t.Errorf("RandomSetBit = %d after rollback, want 5", i)
+	}
+	if err := bs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTxnRollbackAfterRead clears the only bit of a block, and so of its
+// area, and reads the set before rolling back.
+func TestTxnRollbackAfterRead(t *testing.T) {
+	for _, g := range geometries {
+		bs := setOf(g, [2]int32{5, 6}, [2]int32{1 << 20, 1<<20 + 1})
+		tx := bs.Begin()
+		tx.Clear(5)
+		if !bs.Equals(setOf(g, [2]int32{1 << 20, 1<<20 + 1})) {
+			t.Errorf("%v: %v within the transaction, want {%d}", g, bs, 1<<20)
+		}
+		tx.Rollback()
+		if got := bs.ToSlice(); !reflect.DeepEqual(got, []int32{5, 1 << 20}) {
+			t.Errorf("%v: bits %v after rollback, want [5 %d]", g, got, 1<<20)
+		}
+		if err := bs.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package sparse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidStructure is the error every failure reported by Validate wraps.
+var ErrInvalidStructure = errors.New("sparse: invalid bit set structure")
+
+/*Validate ...
+ *  Checks the structural invariants of the bit set, returning an error that
+ *  wraps ErrInvalidStructure and describes the first one found broken:
+ *  <ul>
+ *  <li>the level1 array has a power of two length, and bitsLength matches it
+ *  <li>every level2 area and level3 block, and the spare block, has the length
+ *      of the geometry, and the spare block is all zero
+ *  <li>HeapBytes matches a recount of the arrays held by the set
+ *  <li>the shared block references match the blocks shared through the
+ *      interner
+ *  <li>if the statistics are up to date, they match a recount, and there are
+ *      no all zero blocks nor empty areas left (the update normalizes them)
+ *  </ul>
+ *  The set is not changed. Validate is meant for tests and diagnostics; it
+ *  walks the whole set, and gives no meaningful result while a transaction
+ *  on the set is open.
+ */
+func (bs *BitSet) Validate() error {
+	g := bs.geo
+	if g == nil {
+		return fmt.Errorf("%w: no geometry (zero BitSet)", ErrInvalidStructure)
+	}
+	length1 := int32(len(bs.bits))
+	if length1 == 0 || length1&(length1-1) != 0 || length1 > g.maxLength1 {
+		return fmt.Errorf("%w: level1 length %d", ErrInvalidStructure, length1)
+	}
+	bitsLength := int32(math.MaxInt32)
+	if length1 != g.maxLength1 {
+		bitsLength = length1 * g.unit
+	}
+	if bs.bitsLength != bitsLength {
+		return fmt.Errorf("%w: bitsLength %d for a level1 length of %d",
+			ErrInvalidStructure, bs.bitsLength, length1)
+	}
+	if int32(len(bs.spare)) != g.length3 || !isZeroBlock(bs.spare) {
+		return fmt.Errorf("%w: spare block is not a zero block of length %d",
+			ErrInvalidStructure, g.length3)
+	}
+
+	heapBytes := int64(length1)*cLevel1EntryBytes + g.block3Bytes
+	shared := make(map[*wordType]int32)
+	st := new(updateStrategyType)
+	st.start(nil)
+	var areas, blocks, zeroBlocks, emptyAreas int32
+	for w1, a2 := range bs.bits {
+		if a2 == nil {
+			continue
+		}
+		if int32(len(a2)) != g.length2 {
+			return fmt.Errorf("%w: level2[%d] has length %d", ErrInvalidStructure, w1, len(a2))
+		}
+		areas++
+		heapBytes += g.area2Bytes
+		empty := true
+		for w2, a3 := range a2 {
+			if a3 == nil {
+				continue
+			}
+			if int32(len(a3)) != g.length3 {
+				return fmt.Errorf("%w: level3[%d][%d] has length %d", ErrInvalidStructure, w1, w2, len(a3))
+			}
+			empty = false
+			blocks++
+			if bs.isShared(a3) {
+				shared[&a3[0]]++
+			} else {
+				heapBytes += g.block3Bytes
+			}
+			if isZeroBlock(a3) {
+				zeroBlocks++
+			}
+			base := (int32(w1) << g.shift1) + (int32(w2) << g.shift2)
+			for w3, word := range a3 {
+				if word != 0 {
+					st.compute(base+int32(w3), word)
+				}
+			}
+		}
+		if empty {
+			emptyAreas++
+		}
+	}
+	if bs.heapBytes != heapBytes {
+		return fmt.Errorf("%w: HeapBytes is %d, recount is %d", ErrInvalidStructure, bs.heapBytes, heapBytes)
+	}
+	if len(shared) != len(bs.shared) {
+		return fmt.Errorf("%w: %d shared blocks referenced, %d recorded",
+			ErrInvalidStructure, len(shared), len(bs.shared))
+	}
+	for key, n := range shared {
+		if bs.shared[key] != n {
+			return fmt.Errorf("%w: shared block referenced %d times, recorded %d",
+				ErrInvalidStructure, n, bs.shared[key])
+		}
+	}
+
+	if bs.cache.hash == 0 {
+		return nil //  The statistics are stale, and need not match
+	}
+	if zeroBlocks != 0 || emptyAreas != 0 {
+		return fmt.Errorf("%w: %d zero blocks and %d empty areas after the statistics update",
+			ErrInvalidStructure, zeroBlocks, emptyAreas)
+	}
+	var recount cacheType
+	st.finish(&recount, areas, blocks)
+	c := &bs.cache
+	if recount.hash != c.hash || recount.size != c.size || recount.cardinality != c.cardinality ||
+		recount.length != c.length || recount.count != c.count ||
+		recount.a2Count != c.a2Count || recount.a3Count != c.a3Count {
+		return fmt.Errorf("%w: statistics %+v, recount %+v", ErrInvalidStructure, *c, recount)
+	}
+	if c.ranks == nil {
+		return nil //  The blocks have not been ranked since the update
+	}
+	ranks, _ := bs.rankBlocks()
+	if len(ranks) != len(c.ranks) {
+		return fmt.Errorf("%w: %d block ranks, recount %d", ErrInvalidStructure, len(c.ranks), len(ranks))
+	}
+	for k, r := range ranks {
+		if c.ranks[k] != r {
+			return fmt.Errorf("%w: block rank %+v, recount %+v", ErrInvalidStructure, c.ranks[k], r)
+		}
+	}
+	return nil
+}
@@ -18,6 +18,8 @@ type db struct {
 type schema struct {
 	schemaName string
 	tables     []*table
+	sequences  []*sequence
+	views      []*view
 }
 
 type table struct {
@@ -38,6 +40,10 @@ type column struct {
 	defValue   string
 	numScale   int
 	numPrec    int
+	identity   *identity
+	/*  Whether the fractional second digits of a time type were reported
+	in numScale, which is 0 both for none reported and for TIME(0). */
+	scaleReported bool
 }
 
 type IResultSet interface {
@@ -45,24 +51,6 @@ type IResultSet interface {
 	Scan(...interface{}) error
 }
 
-func (h2 db) grabSchema(schemaName string) (s *schema, err error) {
-	s = &schema{
-		schemaName: schemaName,
-	}
-	s.tables, err = h2.grabTables(schemaName)
-	for _, t := range s.tables {
-		t.cons, err = h2.grabConstraints(t)
-		if err != nil {
-			return
-		}
-		t.indexes, err = h2.grabIndexes(t)
-		if err != nil {
-			return
-		}
-	}
-	return
-}
-
 func collectTables(rs IResultSet) (result []*table, err error) {
 	var curTab *table
 	for rs.Next() {
@@ -103,56 +91,27 @@ func collectTables(rs IResultSet) (result []*table, err error) {
 	return
 }
 
-func (h2 *db) grabTables(schemaName string) (result []*table, err error) {
-	query := `
-	SELECT 
-        C.TABLE_SCHEMA
-        ,C.TABLE_NAME
-        ,C.COLUMN_NAME 
-		,C.ORDINAL_POSITION 
-		,C.COLUMN_DEFAULT 
-		,C.NULLABLE 
-		,C.TYPE_NAME 
-		,C.CHARACTER_MAXIMUM_LENGTH 
-		,C.NUMERIC_PRECISION 
-		,C.NUMERIC_SCALE
-	FROM INFORMATION_SCHEMA.COLUMNS C
-  		INNER JOIN INFORMATION_SCHEMA.TABLES  T
-		ON T.TABLE_CATALOG = C.TABLE_CATALOG
-		AND T.TABLE_SCHEMA = C.TABLE_SCHEMA
-		AND T.TABLE_NAME = C.TABLE_NAME
-	WHERE T.TABLE_SCHEMA = '%s'
-		AND T.TABLE_TYPE='TABLE' 
-		AND T.STORAGE_TYPE = 'CACHED' 
-	ORDER BY 
-		C.TABLE_NAME ASC
-		,C.ORDINAL_POSITION ASC
-`
-	query = fmt.Sprintf(query, schemaName)
-
-	rs, err := h2.conn.Query(query)
-	if err != nil {
-		err = errors.Wrapf(err, "could not read H2 table metadata from schema %s", schemaName)
-		return
-	}
-	defer rs.Close()
-	result, err = collectTables(rs)
-	if err != nil {
-		errors.Wrapf(err, "could not read H2 table metadata from schema %s", schemaName)
-		return
-	}
-	return
-}
-
 type constraint struct {
+	schemaName string
+	tableName  string
 	consName   string
-	references string
+	references *reference
 	columList  string
 	typeName   string
 	indexName  string
 	checkExpr  string
 }
 
+type reference struct {
+	consName   string
+	schemaName string
+	tableName  string
+	columns    []string
+	refColumns []string
+	updateRule int
+	deleteRule int
+}
+
 func collectConstraints(rs IResultSet) (result []*constraint, err error) {
 	for rs.Next() {
 		var c = new(constraint)
@@ -171,37 +130,120 @@ func collectConstraints(rs IResultSet) (result []*constraint, err error) {
 	return
 }
 
-func (h2 *db) grabConstraints(t *table) (result []*constraint, err error) {
-
-	query := `
-	SELECT 
-	C.CONSTRAINT_TYPE
-	,C.CONSTRAINT_NAME
-	,C.COLUMN_LIST
-	,C.UNIQUE_INDEX_NAME
-	,C.CHECK_EXPRESSION
-   FROM INFORMATION_SCHEMA.CONSTRAINTS C
-   INNER JOIN INFORMATION_SCHEMA.TABLES T
-	ON T.TABLE_CATALOG = C.TABLE_CATALOG
-   AND T.TABLE_SCHEMA = C.TABLE_SCHEMA
-   AND T.TABLE_NAME = C.TABLE_NAME
-   WHERE T.TABLE_SCHEMA = '%s'
-   AND T.TABLE_TYPE='TABLE' 
-   AND T.STORAGE_TYPE = 'CACHED' 
-   AND T.TABLE_NAME = '%s'
-   `
-	query = fmt.Sprintf(query, t.schemaName, t.tableName)
-	rs, err := h2.conn.Query(query)
-	if err != nil {
-		err = errors.Wrapf(err, "could not open H2 constraint metadata for %s.%s ", t.schemaName, t.tableName)
-		return
+func collectReferences(rs IResultSet) (result map[string]*reference, err error) {
+	result = make(map[string]*reference)
+	for rs.Next() {
+		var r = new(reference)
+		var column, refColumn string
+		err = rs.Scan(
+			&r.consName,
+			&column,
+			&r.schemaName,
+			&r.tableName,
+			&refColumn,
+			&r.updateRule,
+			&r.deleteRule,
+		)
+		if err != nil {
+			return
+		}
+		if prev := result[r.consName]; prev != nil {
+			r = prev
+		} else {
+			result[r.consName] = r
+		}
+		r.columns = append(r.columns, column)
+		r.refColumns = append(r.refColumns, refColumn)
 	}
-	defer rs.Close()
-	result, err = collectConstraints(rs)
-	if err != nil {
-		err = errors.Wrapf(err, "could not read H2 constraint metadata for %s.%s ", t.schemaName, t.tableName)
+	return
+}
+
+// The UPDATE_RULE and DELETE_RULE values of CROSS_REFERENCES, as in
+// java.sql.DatabaseMetaData.
+const (
+	h2RuleCascade    = 0
+	h2RuleRestrict   = 1
+	h2RuleSetNull    = 2
+	h2RuleNoAction   = 3
+	h2RuleSetDefault = 4
+)
+
+func referentialAction(rule int) (result string, err error) {
+	switch rule {
+	case h2RuleCascade:
+		result = "cascade"
+	case h2RuleRestrict:
+		result = "restrict"
+	case h2RuleSetNull:
+		result = "set null"
+	case h2RuleNoAction:
+		result = "no action"
+	case h2RuleSetDefault:
+		result = "set default"
+	default:
+		err = errors.Errorf("unknown H2 referential action %d", rule)
+	}
+	return
+}
+
+func quoteColumnList(columns []string) string {
+	var list []string
+	for _, c := range columns {
+		list = append(list, quoteIdent(strings.TrimSpace(c)))
+	}
+	return strings.Join(list, ",")
+}
+
+func (c *constraint) createDDL() (result string, err error) {
+	var body string
+	switch c.typeName {
+	case "PRIMARY KEY":
+		body = fmt.Sprintf("primary key (%s)", quoteColumnList(strings.Split(c.columList, ",")))
+	case "UNIQUE":
+		body = fmt.Sprintf("unique (%s)", quoteColumnList(strings.Split(c.columList, ",")))
+	case "REFERENTIAL":
+		r := c.references
+		if r == nil {
+			err = errors.Errorf("foreign key %s on %s.%s has no referenced table", c.consName, c.schemaName, c.tableName)
+			return
+		}
+		var onDelete, onUpdate string
+		if onDelete, err = referentialAction(r.deleteRule); err != nil {
+			return
+		}
+		if onUpdate, err = referentialAction(r.updateRule); err != nil {
+			return
+		}
+		body = fmt.Sprintf("foreign key (%s) references %s(%s) on delete %s on update %s",
+			quoteColumnList(r.columns),
+			quoteQualified(r.schemaName, r.tableName),
+			quoteColumnList(r.refColumns),
+			onDelete,
+			onUpdate,
+		)
+	case "CHECK":
+		if strings.TrimSpace(c.checkExpr) == "" {
+			err = errors.Errorf("check constraint %s on %s.%s has no expression", c.consName, c.schemaName, c.tableName)
+			return
+		}
+		body = fmt.Sprintf("check (%s)", c.checkExpr)
+	default:
+		err = errors.Errorf("constraint %s on %s.%s has unknown H2 type %s", c.consName, c.schemaName, c.tableName, c.typeName)
 		return
 	}
+	result = fmt.Sprintf("alter table %s add constraint %s %s;",
+		quoteQualified(c.schemaName, c.tableName),
+		quoteIdent(c.consName),
+		body,
+	)
+	return
+}
+
+func (c *constraint) dropDDL() (result string) {
+	result = fmt.Sprintf("alter table if exists %s drop constraint if exists %s;",
+		quoteQualified(c.schemaName, c.tableName),
+		quoteIdent(c.consName),
+	)
 	return
 }
 
@@ -255,53 +297,14 @@ func collectIndexes(rs IResultSet) (result []*index, err error) {
 
 }
 
-func (h2 *db) grabIndexes(t *table) (result []*index, err error) {
-	query := `
-	SELECT 
-		C.TABLE_SCHEMA
-		,C.TABLE_NAME
-		,C.INDEX_NAME
-		,C.NON_UNIQUE
-		,C.INDEX_TYPE_NAME
-		,C.CONSTRAINT_NAME
-		,C.COLUMN_NAME
-		,C.ORDINAL_POSITION
-		,C.ASC_OR_DESC
-	FROM INFORMATION_SCHEMA.INDEXES C
-		INNER JOIN  INFORMATION_SCHEMA.TABLES T
-			ON T.TABLE_CATALOG = C.TABLE_CATALOG
-			AND T.TABLE_SCHEMA = C.TABLE_SCHEMA
-			AND T.TABLE_NAME = C.TABLE_NAME
-	WHERE T.TABLE_SCHEMA = '%s'
-		AND T.TABLE_TYPE='TABLE' 
-		AND T.STORAGE_TYPE = 'CACHED' 
-		AND T.TABLE_NAME = '%s'
-      ORDER BY C.INDEX_NAME,C.ORDINAL_POSITION asc
-   `
-	query = fmt.Sprintf(query, t.schemaName, t.tableName)
-	rs, err := h2.conn.Query(query)
-	if err != nil {
-		err = errors.Wrapf(err, "could not read H2 index metadata for %s.%s ", t.schemaName, t.tableName)
-		return
-	}
-	defer rs.Close()
-	result, err = collectIndexes(rs)
-	if err != nil {
-		err = errors.Wrapf(err, "could not read H2 index metadata for %s.%s ", t.schemaName, t.tableName)
-		return
-	}
-	return
-}
-
 func (i *index) createDDL() (result string) {
 	var list []string
 	for _, ic := range i.columns {
 		list = append(list, ic.createDDL())
 	}
-	result = fmt.Sprintf("create index if not exists %s on %s.%s(%s);",
-		i.indexName,
-		i.schemaName,
-		i.tableName,
+	result = fmt.Sprintf("create index if not exists %s on %s(%s);",
+		quoteIdent(i.indexName),
+		quoteQualified(i.schemaName, i.tableName),
 		strings.Join(list, ","),
 	)
 	return
@@ -313,50 +316,208 @@ func (ic *indexColumn) createDDL() (result string) {
 	} else if asc == "D" {
 		asc = "DESC"
 	}
-	result = fmt.Sprintf("%s %s", ic.columnName, asc)
+	result = fmt.Sprintf("%s %s", quoteIdent(ic.columnName), asc)
 	return
 }
 
 func (i *index) dropDDL() (result string) {
-	result = fmt.Sprintf("drop index if exists %s.%s;", i.schemaName, i.indexName)
+	result = fmt.Sprintf("drop index if exists %s;", quoteQualified(i.schemaName, i.indexName))
 	return
 }
 
-func (t *table) createDDL() (result string) {
+func (t *table) createDDL(types *typeMap) (result string, err error) {
 	var list []string
 	for _, ic := range t.columns {
-		list = append(list, ic.createDDL())
+		var ddl string
+		if ddl, err = ic.createDDL(types); err != nil {
+			return
+		}
+		list = append(list, ddl)
 	}
-	result = fmt.Sprintf("create table if not exists %s.%s(%s);",
-		t.schemaName,
-		t.tableName,
+	result = fmt.Sprintf("create table if not exists %s(%s);",
+		quoteQualified(t.schemaName, t.tableName),
 		strings.Join(list, ","),
 	)
 	return
 }
 
 func (t *table) dropDDL() (result string) {
-	result = fmt.Sprintf("drop table if exists %s.%s;", t.schemaName, t.tableName)
+	result = fmt.Sprintf("drop table if exists %s;", quoteQualified(t.schemaName, t.tableName))
 	return
 }
 
-func (c *column) createDDL() (result string) {
-	//TODO: accomplish it
-	pgColumn := c
-	switch strings.ToUpper(c.typeName) {
-	case "BIGINT", "BOOLEAN", "INTEGER", "SMALLINT":
-		pgColumn.typeName = strings.ToLower(c.typeName)
-	case "DECIMAL":
-		pgColumn.typeName = "numeric"
-	case "VARCHAR":
-		pgColumn.typeName = "character varying"
-	case "DOUBLE":
-		pgColumn.typeName = "double precision"
-	case "TIMESTAMP":
-		pgColumn.typeName = "timestamp without time zone"
-	case "CLOB", "BLOB", "VARBINARY":
-		pgColumn.typeName = "bytea"
+// quoteIdent quotes a name for PostgreSQL. Every name in the DDL is quoted,
+// so that it keeps the case H2 gives it.
+func quoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteQualified quotes the name of a table, view, index or sequence
+// qualified by its schema.
+func quoteQualified(schemaName, name string) string {
+	return quoteIdent(schemaName) + "." + quoteIdent(name)
+}
+
+func (c *column) createDDL(types *typeMap) (result string, err error) {
+	pgType, err := types.pgType(c)
+	if err != nil {
+		return
 	}
+	result = quoteIdent(c.columnName) + " " + pgType
+	if c.nullable == 0 {
+		result += " not null"
+	}
+	if c.identity != nil {
+		/*  The default of an H2 1.4 identity calls its generated sequence,
+		which is not created. */
+		var clause string
+		if clause, err = c.identity.clause(c, pgType); err != nil {
+			return
+		}
+		result += " " + clause
+	} else if def := c.pgDefault(); def != "" {
+		result += " default " + def
+	}
+	return
+}
 
+// h2DefaultFunctions maps the H2 functions seen in column defaults onto
+// their PostgreSQL equivalents.
+var h2DefaultFunctions = map[string]string{
+	"CURRENT_TIMESTAMP":   "current_timestamp",
+	"CURRENT_TIMESTAMP()": "current_timestamp",
+	"NOW()":               "current_timestamp",
+	"SYSDATE":             "current_timestamp",
+	"SYSDATE()":           "current_timestamp",
+	"SYSTIMESTAMP":        "current_timestamp",
+	"LOCALTIMESTAMP":      "localtimestamp",
+	"LOCALTIMESTAMP()":    "localtimestamp",
+	"CURRENT_DATE":        "current_date",
+	"CURRENT_DATE()":      "current_date",
+	"CURDATE()":           "current_date",
+	"CURRENT_TIME":        "current_time",
+	"CURRENT_TIME()":      "current_time",
+	"CURTIME()":           "current_time",
+	"RANDOM_UUID()":       "gen_random_uuid()",
+	"UUID()":              "gen_random_uuid()",
+}
+
+// unparenthesized strips the parentheses H2 puts around a default
+// expression. They are stripped only when the first one is closed by the
+// last, so that "(1) + (2)" is kept as it is.
+func unparenthesized(expr string) (result string) {
+	result = strings.TrimSpace(expr)
+	for strings.HasPrefix(result, "(") && closingParen(result) == len(result)-1 {
+		result = strings.TrimSpace(result[1 : len(result)-1])
+	}
 	return
 }
+
+// closingParen returns the index of the parenthesis closing the one the
+// expression starts with, skipping quoted strings and names, or -1.
+func closingParen(expr string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0 //  A doubled quote opens the string again
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// pgDefault translates the H2 default expression of the column, returning
+// an empty string when the column has no default. Literals are kept as they
+// are; sequence values become nextval calls, the sequence name quoted like
+// the tables.
+func (c *column) pgDefault() string {
+	def := unparenthesized(c.defValue)
+	upper := strings.ToUpper(def)
+	switch {
+	case def == "" || upper == "NULL":
+		return ""
+	case strings.HasPrefix(upper, "NEXT VALUE FOR "):
+		if schemaName, seqName, ok := c.defaultSequence(); ok {
+			return "nextval(" + quoteLiteral(quoteQualified(schemaName, seqName)) + ")"
+		}
+		seq := strings.TrimSpace(def[len("NEXT VALUE FOR "):])
+		return "nextval(" + quoteLiteral(seq) + ")"
+	}
+	if pg, ok := h2DefaultFunctions[upper]; ok {
+		return pg
+	}
+	return def
+}
+
+// defaultSequence returns the sequence of a NEXT VALUE FOR default, in the
+// schema of the column when not qualified.
+func (c *column) defaultSequence() (schemaName, seqName string, ok bool) {
+	def := unparenthesized(c.defValue)
+	if !strings.HasPrefix(strings.ToUpper(def), "NEXT VALUE FOR ") {
+		return
+	}
+	names, ok := splitQualifiedName(def[len("NEXT VALUE FOR "):])
+	switch {
+	case !ok:
+	case len(names) == 1:
+		schemaName, seqName = c.schemaName, names[0]
+	default:
+		schemaName, seqName = names[len(names)-2], names[len(names)-1]
+	}
+	return
+}
+
+// splitQualifiedName splits an H2 name such as "PUBLIC"."SEQ" or PUBLIC.SEQ
+// into its unquoted parts, unquoted identifiers in upper case.
+func splitQualifiedName(s string) (result []string, ok bool) {
+	s = strings.TrimSpace(s)
+	for {
+		if strings.HasPrefix(s, `"`) {
+			var sb strings.Builder
+			k := 1
+			for ; k < len(s); k++ {
+				if s[k] == '"' {
+					if k+1 < len(s) && s[k+1] == '"' {
+						k++
+					} else {
+						break
+					}
+				}
+				sb.WriteByte(s[k])
+			}
+			if k >= len(s) {
+				return nil, false
+			}
+			result = append(result, sb.String())
+			s = s[k+1:]
+		} else {
+			k := 0
+			for k < len(s) && isWordPart(s[k]) {
+				k++
+			}
+			if k == 0 {
+				return nil, false
+			}
+			result = append(result, strings.ToUpper(s[:k]))
+			s = s[k:]
+		}
+		if s == "" {
+			return result, true
+		}
+		if s[0] != '.' {
+			return nil, false
+		}
+		s = s[1:]
+	}
+}
@@ -121,3 +121,353 @@ func TestCollectIndexes(t *testing.T) {
 	}
 	return
 }
+
+func TestColumnCreateDDL(t *testing.T) {
+	tests := []struct {
+		c    column
+		want string
+	}{
+		{column{columnName: "ID", typeName: "BIGINT", nullable: 0}, `"ID" bigint not null`},
+		{column{columnName: "NAME", typeName: "varchar", charLength: 50, nullable: 1}, `"NAME" character varying(50)`},
+		{column{columnName: "NOTE", typeName: "VARCHAR", charLength: 2147483647, nullable: 1}, `"NOTE" character varying`},
+		{column{columnName: "CODE", typeName: "CHAR", charLength: 3, nullable: 1, defValue: "'USD'"}, `"CODE" character(3) default 'USD'`},
+		{column{columnName: "AMOUNT", typeName: "DECIMAL", numPrec: 18, numScale: 2, nullable: 0, defValue: "0"}, `"AMOUNT" numeric(18,2) not null default 0`},
+		{column{columnName: "RATE", typeName: "DECIMAL", numPrec: 65535, nullable: 1}, `"RATE" numeric`},
+		{column{columnName: "STD", typeName: "float", nullable: 1}, `"STD" double precision`},
+		{column{columnName: "FLAG", typeName: "TINYINT", nullable: 1, defValue: "NULL"}, `"FLAG" smallint`},
+		{column{columnName: "ADDED", typeName: "TIMESTAMP", nullable: 1, defValue: "CURRENT_TIMESTAMP()"}, `"ADDED" timestamp without time zone default current_timestamp`},
+		{column{columnName: "SEQ", typeName: "INTEGER", nullable: 0, defValue: "(NEXT VALUE FOR PUBLIC.SYSTEM_SEQUENCE_1)"}, `"SEQ" integer not null default nextval('"PUBLIC"."SYSTEM_SEQUENCE_1"')`},
+		{column{columnName: `a"b`, typeName: "BOOLEAN", nullable: 1, defValue: "TRUE"}, `"a""b" boolean default TRUE`},
+		{column{columnName: "SUM", typeName: "INTEGER", nullable: 1, defValue: "(1) + (2)"}, `"SUM" integer default (1) + (2)`},
+		{column{columnName: "ZERO", typeName: "INTEGER", nullable: 1, defValue: "((0))"}, `"ZERO" integer default 0`},
+		{column{columnName: "TAG", typeName: "VARCHAR", charLength: 5, nullable: 1, defValue: "('(') || (')')"}, `"TAG" character varying(5) default ('(') || (')')`},
+	}
+	for _, tt := range tests {
+		got, err := tt.c.createDDL(nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.c.columnName, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.c.columnName, got, tt.want)
+		}
+	}
+
+	c := column{schemaName: "PUBLIC", tableName: "T", columnName: "G", typeName: "CURSOR"}
+	if _, err := c.createDDL(nil); err == nil {
+		t.Error("unknown H2 type CURSOR was accepted")
+	}
+}
+
+func TestTableCreateDDL(t *testing.T) {
+	tb := &table{
+		schemaName: "public",
+		tableName:  "table_info",
+		columns: []*column{
+			{columnName: "id", typeName: "bigint", nullable: 0},
+			{columnName: "name", typeName: "varchar", charLength: 50, nullable: 1},
+		},
+	}
+	got, err := tb.createDDL(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `create table if not exists "public"."table_info"("id" bigint not null,"name" character varying(50));`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if tb.columns[1].typeName != "varchar" {
+		t.Error("createDDL changed the column it was given")
+	}
+
+	tb.columns = append(tb.columns, &column{columnName: "shape", typeName: "CURSOR"})
+	if _, err = tb.createDDL(nil); err == nil {
+		t.Error("table with a column of unknown type was accepted")
+	}
+}
+
+func TestTypeMap(t *testing.T) {
+	types := newTypeMap()
+	types.overrideColumn("public", "orders", "status", typeRule{"order_status", noModifier, 0})
+	types.overrideColumn("PUBLIC", "ORDERS", "NOTE", typeRule{"character varying", lengthModifier, 100})
+
+	tests := []struct {
+		c    column
+		want string
+	}{
+		{column{typeName: "CLOB"}, "text"},
+		{column{typeName: "VARCHAR_IGNORECASE", charLength: 20}, "citext"},
+		{column{typeName: "TINYINT"}, "smallint"},
+		{column{typeName: "REAL"}, "real"},
+		{column{typeName: "DATE"}, "date"},
+		{column{typeName: "TIME", numScale: 0, scaleReported: true}, "time(0) without time zone"},
+		{column{typeName: "TIME"}, "time without time zone"},
+		{column{typeName: "TIMESTAMP", numScale: 6, scaleReported: true}, "timestamp without time zone"},
+		{column{typeName: "TIMESTAMP WITH TIME ZONE", numScale: 3, scaleReported: true}, "timestamp(3) with time zone"},
+		{column{typeName: "TIMESTAMP WITH TIME ZONE"}, "timestamp with time zone"},
+		{column{typeName: "UUID"}, "uuid"},
+		{column{typeName: "ARRAY"}, "text[]"},
+		{column{typeName: "INTEGER ARRAY", charLength: 10}, "integer[]"},
+		{column{typeName: "ENUM"}, "text"},
+		{column{typeName: "INTERVAL DAY TO SECOND"}, "interval day to second"},
+		{column{typeName: "JSON"}, "jsonb"},
+		{column{typeName: "GEOMETRY"}, "geometry"},
+		{column{typeName: "OTHER"}, "bytea"},
+		{column{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "STATUS", typeName: "ENUM"}, "order_status"},
+		{column{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "NOTE", typeName: "CLOB", charLength: 80}, "character varying(80)"},
+		{column{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "NOTE", typeName: "CLOB", charLength: 800}, "character varying"},
+	}
+	for _, tt := range tests {
+		got, err := types.pgType(&tt.c)
+		if err != nil {
+			t.Errorf("%s: %v", tt.c.typeName, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: got %s, want %s", tt.c.columnName, tt.c.typeName, got, tt.want)
+		}
+	}
+
+	for _, h2Type := range []string{"CURSOR", "ROW ARRAY"} {
+		if _, err := types.pgType(&column{typeName: h2Type}); err == nil {
+			t.Errorf("unknown H2 type %s was accepted", h2Type)
+		}
+	}
+}
+
+/*
+R.FK_NAME
+,R.FKCOLUMN_NAME
+,R.PKTABLE_SCHEMA
+,R.PKTABLE_NAME
+,R.PKCOLUMN_NAME
+,R.UPDATE_RULE
+,R.DELETE_RULE
+*/
+func TestCollectReferences(t *testing.T) {
+	rs := &testRS{
+		data: [][]interface{}{
+			{"FK_LINE_ORDER", "ORDER_ID", "PUBLIC", "ORDERS", "ID", h2RuleNoAction, h2RuleCascade},
+			{"FK_LINE_PRODUCT", "PRODUCT_ID", "PUBLIC", "PRODUCTS", "ID", h2RuleCascade, h2RuleSetNull},
+			{"FK_LINE_PRODUCT", "PRODUCT_VERSION", "PUBLIC", "PRODUCTS", "VERSION", h2RuleCascade, h2RuleSetNull},
+		},
+	}
+	result, err := collectReferences(rs)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "Error while scanning cross reference values"))
+	}
+	want := map[string]*reference{
+		"FK_LINE_ORDER": &reference{
+			consName:   "FK_LINE_ORDER",
+			schemaName: "PUBLIC",
+			tableName:  "ORDERS",
+			columns:    []string{"ORDER_ID"},
+			refColumns: []string{"ID"},
+			updateRule: h2RuleNoAction,
+			deleteRule: h2RuleCascade,
+		},
+		"FK_LINE_PRODUCT": &reference{
+			consName:   "FK_LINE_PRODUCT",
+			schemaName: "PUBLIC",
+			tableName:  "PRODUCTS",
+			columns:    []string{"PRODUCT_ID", "PRODUCT_VERSION"},
+			refColumns: []string{"ID", "VERSION"},
+			updateRule: h2RuleCascade,
+			deleteRule: h2RuleSetNull,
+		},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Error(errors.New("cross reference collection is wrong"))
+	}
+}
+
+func TestConstraintDDL(t *testing.T) {
+	tests := []struct {
+		c    constraint
+		want string
+	}{
+		{
+			constraint{schemaName: "PUBLIC", tableName: "LINES", consName: "PK_LINES", typeName: "PRIMARY KEY", columList: "ORDER_ID,LINE_NO"},
+			`alter table "PUBLIC"."LINES" add constraint "PK_LINES" primary key ("ORDER_ID","LINE_NO");`,
+		},
+		{
+			constraint{schemaName: "PUBLIC", tableName: "LINES", consName: "UQ_LINES", typeName: "UNIQUE", columList: "CODE"},
+			`alter table "PUBLIC"."LINES" add constraint "UQ_LINES" unique ("CODE");`,
+		},
+		{
+			constraint{schemaName: "PUBLIC", tableName: "LINES", consName: "FK_LINE_ORDER", typeName: "REFERENTIAL", columList: "ORDER_ID",
+				references: &reference{schemaName: "PUBLIC", tableName: "ORDERS", columns: []string{"ORDER_ID"}, refColumns: []string{"ID"},
+					updateRule: h2RuleRestrict, deleteRule: h2RuleCascade}},
+			`alter table "PUBLIC"."LINES" add constraint "FK_LINE_ORDER" foreign key ("ORDER_ID") references "PUBLIC"."ORDERS"("ID") on delete cascade on update restrict;`,
+		},
+		{
+			constraint{schemaName: "PUBLIC", tableName: "LINES", consName: "CK_QTY", typeName: "CHECK", checkExpr: "QTY > 0"},
+			`alter table "PUBLIC"."LINES" add constraint "CK_QTY" check (QTY > 0);`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.c.createDDL()
+		if err != nil {
+			t.Errorf("%s: %v", tt.c.consName, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.c.consName, got, tt.want)
+		}
+	}
+	if got, want := tests[0].c.dropDDL(), `alter table if exists "PUBLIC"."LINES" drop constraint if exists "PK_LINES";`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	for _, c := range []constraint{
+		{consName: "FK", typeName: "REFERENTIAL"},
+		{consName: "CK", typeName: "CHECK"},
+		{consName: "X", typeName: "EXCLUDE"},
+		{consName: "FK", typeName: "REFERENTIAL", references: &reference{deleteRule: 9}},
+	} {
+		if _, err := c.createDDL(); err == nil {
+			t.Errorf("%s %s: invalid constraint was accepted", c.typeName, c.consName)
+		}
+	}
+}
+
+func TestH2Version(t *testing.T) {
+	tests := []struct {
+		version string
+		major   int
+	}{
+		{"1.4.200", 1},
+		{"1.4.197 (2018-03-18)", 1},
+		{"2.1.214", 2},
+		{"2.2.224 (2023-09-17)", 2},
+	}
+	for _, tt := range tests {
+		major, err := parseH2Version(tt.version)
+		if err != nil || major != tt.major {
+			t.Errorf("parseH2Version(%q) = %d, %v; want %d", tt.version, major, err, tt.major)
+			continue
+		}
+		if q, err := catalogFor(major); err != nil || q.major != major {
+			t.Errorf("catalogFor(%d) = %v, %v", major, q, err)
+		}
+	}
+	if _, err := parseH2Version("unknown"); err == nil {
+		t.Error("parseH2Version accepted a version without a number")
+	}
+	if _, err := catalogFor(3); err == nil {
+		t.Error("catalogFor accepted H2 version 3")
+	}
+}
+
+func TestH2v2TypeNames(t *testing.T) {
+	tests := []struct {
+		c    column
+		want string
+	}{
+		{column{typeName: "CHARACTER VARYING", charLength: 40}, "character varying(40)"},
+		{column{typeName: "CHARACTER VARYING", charLength: 1000000000}, "character varying"},
+		{column{typeName: "CHARACTER LARGE OBJECT"}, "text"},
+		{column{typeName: "BINARY LARGE OBJECT"}, "bytea"},
+		{column{typeName: "DOUBLE PRECISION"}, "double precision"},
+		{column{typeName: "TIMESTAMP", numScale: 9, scaleReported: true}, "timestamp without time zone"},
+		{column{typeName: "CHARACTER VARYING ARRAY"}, "character varying[]"},
+	}
+	for _, tt := range tests {
+		got, err := (*typeMap)(nil).pgType(&tt.c)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %s, %v; want %s", tt.c.typeName, got, err, tt.want)
+		}
+	}
+}
+
+// testCatalog is a CatalogReader serving a fixed schema.
+type testCatalog struct {
+	tables    map[string][]*column
+	cons      map[string][]*constraint
+	indexes   map[string][]*index
+	sequences []*sequence
+	views     []*view
+}
+
+func (c *testCatalog) Schemas() ([]string, error) {
+	return []string{"PUBLIC"}, nil
+}
+func (c *testCatalog) Tables(schemaName string) (result []*table, err error) {
+	for _, name := range []string{"ORDERS", "LINES"} {
+		result = append(result, &table{schemaName: schemaName, tableName: name})
+	}
+	return
+}
+func (c *testCatalog) Columns(t *table) ([]*column, error) {
+	return c.tables[t.tableName], nil
+}
+func (c *testCatalog) Constraints(t *table) ([]*constraint, error) {
+	return c.cons[t.tableName], nil
+}
+func (c *testCatalog) Indexes(t *table) ([]*index, error) {
+	return c.indexes[t.tableName], nil
+}
+func (c *testCatalog) Sequences(schemaName string) ([]*sequence, error) {
+	return c.sequences, nil
+}
+func (c *testCatalog) Views(schemaName string) ([]*view, error) {
+	return c.views, nil
+}
+
+func TestReadSchema(t *testing.T) {
+	cat := &testCatalog{
+		tables: map[string][]*column{
+			"ORDERS": {{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "ID", typeName: "BIGINT"}},
+			"LINES":  {{schemaName: "PUBLIC", tableName: "LINES", columnName: "ORDER_ID", typeName: "BIGINT"}},
+		},
+		cons: map[string][]*constraint{
+			"ORDERS": {{schemaName: "PUBLIC", tableName: "ORDERS", consName: "PK_ORDERS", typeName: "PRIMARY KEY", columList: "ID"}},
+		},
+		indexes: map[string][]*index{
+			"LINES": {{schemaName: "PUBLIC", tableName: "LINES", indexName: "IX_LINES", typeName: "INDEX"}},
+		},
+		sequences: []*sequence{{schemaName: "PUBLIC", seqName: "SEQ_ORDERS", nextValue: 1, increment: 1}},
+		views:     []*view{{schemaName: "PUBLIC", viewName: "V_ORDERS", definition: "SELECT ID FROM PUBLIC.ORDERS"}},
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &schema{
+		schemaName: "PUBLIC",
+		tables: []*table{
+			{schemaName: "PUBLIC", tableName: "ORDERS", columns: cat.tables["ORDERS"], cons: cat.cons["ORDERS"]},
+			{schemaName: "PUBLIC", tableName: "LINES", columns: cat.tables["LINES"], indexes: cat.indexes["LINES"]},
+		},
+		sequences: cat.sequences,
+		views:     cat.views,
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Error(errors.New("schema read through the catalog reader is wrong"))
+	}
+}
+
+func TestCollectSequencesAndViews(t *testing.T) {
+	rs := &testRS{
+		data: [][]interface{}{
+			{"PUBLIC", "SEQ_ORDERS", int64(101), int64(1), int64(1), int64(9223372036854775807), false, int64(32), false},
+			{"PUBLIC", "SYSTEM_SEQUENCE_1", int64(5), int64(1), int64(1), int64(2147483647), true, int64(32), true},
+		},
+	}
+	sequences, err := collectSequences(rs)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "Error while scanning sequence values"))
+	}
+	if len(sequences) != 2 || *sequences[1] != (sequence{"PUBLIC", "SYSTEM_SEQUENCE_1", 5, 1, 1, 2147483647, true, 32, true}) {
+		t.Error(errors.New("sequence collection is wrong"))
+	}
+
+	rs = &testRS{data: [][]interface{}{{"PUBLIC", "V_ORDERS", "SELECT ID FROM PUBLIC.ORDERS"}}}
+	views, err := collectViews(rs)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "Error while scanning view values"))
+	}
+	if len(views) != 1 || *views[0] != (view{"PUBLIC", "V_ORDERS", "SELECT ID FROM PUBLIC.ORDERS"}) {
+		t.Error(errors.New("view collection is wrong"))
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tableOrder orders tables by their foreign keys, referenced tables first,
+// for the tables to be created and loaded with their foreign keys in place.
+// A foreign key closing a cycle of references cannot be in place during the
+// load; it is deferred, and added by the post-load DDL.
+type tableOrder struct {
+	tables   []*table      //  Referenced tables first
+	levels   [][]*table    //  Tables referencing tables of earlier levels only
+	deferred []*constraint //  Foreign keys added after the load
+	cycles   [][]*table    //  Cycles of references found, as they were broken
+}
+
+// orderTables sorts the tables topologically, keeping their given order
+// where references don't decide it. References to tables outside the given
+// ones are not followed.
+func orderTables(tables []*table) (result *tableOrder) {
+	result = new(tableOrder)
+	byKey := make(map[string]*table)
+	for _, t := range tables {
+		byKey[tableKey(t.schemaName, t.tableName)] = t
+	}
+	/*  parents[child][parent] holds the foreign keys from child to parent. */
+	parents := make(map[*table]map[*table][]*constraint)
+	children := make(map[*table][]*table)
+	for _, t := range tables {
+		parents[t] = make(map[*table][]*constraint)
+		for _, c := range t.cons {
+			if c.typeName != "REFERENTIAL" || c.references == nil {
+				continue
+			}
+			parent := byKey[tableKey(c.references.schemaName, c.references.tableName)]
+			switch {
+			case parent == nil:
+			case parent == t:
+				result.deferred = append(result.deferred, c)
+				result.cycles = append(result.cycles, []*table{t})
+			default:
+				if parents[t][parent] == nil {
+					children[parent] = append(children[parent], t)
+				}
+				parents[t][parent] = append(parents[t][parent], c)
+			}
+		}
+	}
+
+	level := make(map[*table]int)
+	remaining := tables
+	for len(remaining) > 0 {
+		var next []*table
+		for _, t := range remaining {
+			if len(parents[t]) != 0 {
+				next = append(next, t)
+				continue
+			}
+			for _, c := range children[t] {
+				if _, ok := parents[c][t]; ok {
+					delete(parents[c], t)
+					if level[c] < level[t]+1 {
+						level[c] = level[t] + 1
+					}
+				}
+			}
+			result.add(t, level[t])
+		}
+		if len(next) == len(remaining) {
+			/*  Every remaining table references another one: follow the
+			references from the first until a table repeats, and break the
+			cycle at the reference back to it. */
+			var path []*table
+			on := make(map[*table]int)
+			t := next[0]
+			for {
+				if k, ok := on[t]; ok {
+					path = path[k:]
+					break
+				}
+				on[t] = len(path)
+				path = append(path, t)
+				t = firstParent(parents[t], next)
+			}
+			child := path[len(path)-1]
+			result.deferred = append(result.deferred, parents[child][path[0]]...)
+			result.cycles = append(result.cycles, path)
+			delete(parents[child], path[0])
+		}
+		remaining = next
+	}
+	return
+}
+
+// firstParent returns the parent of a table that comes first in tables.
+func firstParent(parents map[*table][]*constraint, tables []*table) *table {
+	for _, t := range tables {
+		if _, ok := parents[t]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+func (o *tableOrder) add(t *table, level int) {
+	o.tables = append(o.tables, t)
+	for len(o.levels) <= level {
+		o.levels = append(o.levels, nil)
+	}
+	o.levels[level] = append(o.levels[level], t)
+}
+
+func (o *tableOrder) isDeferred(c *constraint) bool {
+	for _, d := range o.deferred {
+		if d == c {
+			return true
+		}
+	}
+	return false
+}
+
+// cycleReport describes the cycles of references broken, one per line:
+// "PUBLIC.A -> PUBLIC.B -> PUBLIC.A".
+func (o *tableOrder) cycleReport() (result []string) {
+	for _, cycle := range o.cycles {
+		var names []string
+		for _, t := range cycle {
+			names = append(names, t.schemaName+"."+t.tableName)
+		}
+		result = append(result, strings.Join(append(names, names[0]), " -> "))
+	}
+	return
+}
+
+// createDDL creates the tables in order, each with its constraints but the
+// deferred ones and with its indexes. The indexes of primary keys and unique
+// constraints are left to PostgreSQL.
+func (o *tableOrder) createDDL(types *typeMap) (result []string, err error) {
+	for _, t := range o.tables {
+		var ddl string
+		if ddl, err = t.createDDL(types); err != nil {
+			return
+		}
+		result = append(result, ddl)
+		keys := make(map[string]bool)
+		var fks []*constraint
+		for _, c := range t.cons {
+			switch {
+			case c.typeName == "REFERENTIAL":
+				if !o.isDeferred(c) {
+					fks = append(fks, c)
+				}
+				continue
+			case c.typeName == "PRIMARY KEY" || c.typeName == "UNIQUE":
+				keys[c.consName] = true
+			}
+			if ddl, err = c.createDDL(); err != nil {
+				return
+			}
+			result = append(result, ddl)
+		}
+		for _, c := range fks {
+			if ddl, err = c.createDDL(); err != nil {
+				return
+			}
+			result = append(result, ddl)
+		}
+		for _, i := range t.indexes {
+			if !keys[i.consName] {
+				result = append(result, i.createDDL())
+			}
+		}
+	}
+	return
+}
+
+// postLoadDDL adds the deferred foreign keys.
+func (o *tableOrder) postLoadDDL() (result []string, err error) {
+	for _, c := range o.deferred {
+		var ddl string
+		if ddl, err = c.createDDL(); err != nil {
+			return
+		}
+		result = append(result, ddl)
+	}
+	return
+}
+
+// dropDDL drops the deferred foreign keys, then the tables referencing
+// tables first.
+func (o *tableOrder) dropDDL() (result []string) {
+	for _, c := range o.deferred {
+		result = append(result, c.dropDDL())
+	}
+	for k := len(o.tables) - 1; k >= 0; k-- {
+		result = append(result, o.tables[k].dropDDL())
+	}
+	return
+}
+
+// migrationScript is the DDL migrating a schema into PostgreSQL, in the
+// order it runs: preLoad before the tables are loaded, postLoad after.
+type migrationScript struct {
+	tables   *tableOrder
+	views    *viewOrder
+	preLoad  []string //  Sequences, tables with their keys and indexes, views
+	postLoad []string //  Deferred foreign keys, sequences past the keys loaded
+}
+
+// buildMigrationScript orders the tables and views of the schema, and builds
+// its DDL. The cycles of references broken and the views left out are
+// reported by the orders kept in the script.
+func buildMigrationScript(s *schema, types *typeMap) (result *migrationScript, err error) {
+	result = &migrationScript{
+		tables: orderTables(s.tables),
+		views:  orderViews(s.views, s.tables),
+	}
+	var ddl []string
+	if ddl, err = result.tables.createDDL(types); err != nil {
+		return
+	}
+	result.preLoad = append(createSequencesDDL(s.sequences), ddl...)
+	result.preLoad = append(result.preLoad, result.views.createDDL()...)
+	if result.postLoad, err = result.tables.postLoadDDL(); err != nil {
+		return
+	}
+	result.postLoad = append(result.postLoad, setvalDDL(s.tables, s.sequences)...)
+	return
+}
+
+// copyInOrder copies the tables level by level, so that the rows a table
+// references are loaded before it. The tables of a level are copied in
+// parallel; a failed level stops the copy, as the tables referencing its
+// tables would fail too.
+func (e *copyEngine) copyInOrder(ctx context.Context, o *tableOrder) (result []*tableCopySummary, err error) {
+	for k, level := range o.levels {
+		var summaries []*tableCopySummary
+		summaries, err = e.copyTables(ctx, level)
+		result = append(result, summaries...)
+		if err != nil {
+			err = errors.Wrapf(err, "load level %d of %d", k+1, len(o.levels))
+			return
+		}
+	}
+	return
+}
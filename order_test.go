@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func testOrderTable(name string, parents ...string) *table {
+	t := &table{
+		schemaName: "PUBLIC",
+		tableName:  name,
+		columns:    []*column{{schemaName: "PUBLIC", tableName: name, columnName: "ID", typeName: "INT", position: 1}},
+		cons: []*constraint{{schemaName: "PUBLIC", tableName: name, consName: "PK_" + name,
+			typeName: "PRIMARY KEY", columList: "ID"}},
+		indexes: []*index{{schemaName: "PUBLIC", tableName: name, indexName: "PRIMARY_KEY_" + name,
+			consName: "PK_" + name, columns: []*indexColumn{{columnName: "ID", position: 1, asc: "A"}}}},
+	}
+	for _, p := range parents {
+		t.cons = append(t.cons, &constraint{
+			schemaName: "PUBLIC",
+			tableName:  name,
+			consName:   "FK_" + name + "_" + p,
+			typeName:   "REFERENTIAL",
+			columList:  "ID",
+			references: &reference{consName: "FK_" + name + "_" + p, schemaName: "PUBLIC", tableName: p,
+				columns: []string{"ID"}, refColumns: []string{"ID"}, updateRule: h2RuleRestrict, deleteRule: h2RuleRestrict},
+		})
+	}
+	return t
+}
+
+func tableNames(tables []*table) (result []string) {
+	for _, t := range tables {
+		result = append(result, t.tableName)
+	}
+	return
+}
+
+func TestOrderTables(t *testing.T) {
+	/*  The references of dict in artem_task.go, tables in name order. */
+	o := orderTables([]*table{
+		testOrderTable("A"),
+		testOrderTable("B", "A"),
+		testOrderTable("C", "B"),
+		testOrderTable("D", "B", "C"),
+		testOrderTable("E", "D"),
+		testOrderTable("F", "E"),
+		testOrderTable("F1", "E", "OTHER"),
+	})
+	if names := tableNames(o.tables); !reflect.DeepEqual(names, []string{"A", "B", "C", "D", "E", "F", "F1"}) {
+		t.Error(errors.Errorf("order: %v", names))
+	}
+	var levels [][]string
+	for _, level := range o.levels {
+		levels = append(levels, tableNames(level))
+	}
+	if !reflect.DeepEqual(levels, [][]string{{"A"}, {"B"}, {"C"}, {"D"}, {"E"}, {"F", "F1"}}) {
+		t.Error(errors.Errorf("levels: %v", levels))
+	}
+	if len(o.deferred) != 0 || len(o.cycles) != 0 {
+		t.Error(errors.New("cycle found in an acyclic graph"))
+	}
+	drop := o.dropDDL()
+	if drop[0] != `drop table if exists "PUBLIC"."F1";` || drop[len(drop)-1] != `drop table if exists "PUBLIC"."A";` {
+		t.Error(errors.Errorf("drop order: %v", drop))
+	}
+
+	/*  Children listed before their parents. */
+	o = orderTables([]*table{testOrderTable("X", "Y"), testOrderTable("Y", "Z"), testOrderTable("Z")})
+	if names := tableNames(o.tables); !reflect.DeepEqual(names, []string{"Z", "Y", "X"}) {
+		t.Error(errors.Errorf("order: %v", names))
+	}
+}
+
+func TestOrderTablesCycles(t *testing.T) {
+	o := orderTables([]*table{
+		testOrderTable("A", "C"),
+		testOrderTable("B", "A"),
+		testOrderTable("C", "B"),
+		testOrderTable("D", "A"),
+		testOrderTable("S", "S"),
+	})
+	if !reflect.DeepEqual(o.cycleReport(), []string{"PUBLIC.S -> PUBLIC.S", "PUBLIC.A -> PUBLIC.C -> PUBLIC.B -> PUBLIC.A"}) {
+		t.Error(errors.Errorf("cycles: %v", o.cycleReport()))
+	}
+	var deferred []string
+	for _, c := range o.deferred {
+		deferred = append(deferred, c.consName)
+	}
+	if !reflect.DeepEqual(deferred, []string{"FK_S_S", "FK_B_A"}) {
+		t.Error(errors.Errorf("deferred: %v", deferred))
+	}
+	if names := tableNames(o.tables); !reflect.DeepEqual(names, []string{"S", "B", "C", "A", "D"}) {
+		t.Error(errors.Errorf("order: %v", names))
+	}
+	create, err := o.createDDL(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := strings.Join(create, "\n")
+	if strings.Contains(script, "FK_B_A") || strings.Contains(script, "FK_S_S") || !strings.Contains(script, "FK_A_C") {
+		t.Error(errors.Errorf("deferred keys in the create script:\n%s", script))
+	}
+	if strings.Contains(script, "create index") {
+		t.Error(errors.New("primary key index created"))
+	}
+	post, err := o.postLoadDDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(post) != 2 || !strings.Contains(post[1], `add constraint "FK_B_A" foreign key`) {
+		t.Error(errors.Errorf("post-load DDL: %v", post))
+	}
+	drop := o.dropDDL()
+	if drop[0] != `alter table if exists "PUBLIC"."S" drop constraint if exists "FK_S_S";` || drop[2] != `drop table if exists "PUBLIC"."D";` {
+		t.Error(errors.Errorf("drop DDL: %v", drop))
+	}
+}
+
+func TestCopyInOrder(t *testing.T) {
+	o := orderTables([]*table{testOrderTable("C", "P"), testOrderTable("P")})
+	source := testSource{"P": {{int64(1)}}, "C": {{int64(1)}}}
+	target := new(testTarget)
+	if _, err := newCopyEngine(source, target, copyParallelism(4)).copyInOrder(context.Background(), o); err != nil {
+		t.Fatal(err)
+	}
+	if len(target.copies) != 2 || !strings.Contains(target.copies[0].statement, `"PUBLIC"."P" `) {
+		t.Error(errors.New("referenced table not loaded first"))
+	}
+
+	target = &testTarget{fail: "P"}
+	summaries, err := newCopyEngine(source, target).copyInOrder(context.Background(), o)
+	if err == nil || len(summaries) != 1 || len(target.copies) != 0 {
+		t.Error(errors.New("load went on after a failed level"))
+	}
+}
+
+func TestBuildMigrationScript(t *testing.T) {
+	/*  A self reference, deferred to after the load. */
+	script := testH2Script + `ALTER TABLE "PUBLIC"."ORDERS" ADD CONSTRAINT "PUBLIC"."FK_SELF" FOREIGN KEY("ID") REFERENCES "PUBLIC"."ORDERS"("ID") NOCHECK;`
+	cat, err := parseH2Script(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := buildMigrationScript(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.preLoad; !reflect.DeepEqual(got, []string{
+		`create sequence if not exists "PUBLIC"."SEQ_ORDERS" start with 101 increment by 1 minvalue 1 maxvalue 9223372036854775807 cache 10 no cycle;`,
+		`create table if not exists "PUBLIC"."ORDERS"("ID" bigint not null default nextval('"PUBLIC"."SEQ_ORDERS"'),` +
+			`"CODE" character varying(20) not null,"AMOUNT" numeric(18,2) default 0,"CREATED" timestamp without time zone default current_timestamp);`,
+		`alter table "PUBLIC"."ORDERS" add constraint "PK_ORDERS" primary key ("ID");`,
+		`create table if not exists "PUBLIC"."LINES"("ORDER_ID" bigint not null,"QTY" integer);`,
+		`alter table "PUBLIC"."LINES" add constraint "CK_QTY" check ("QTY" > 0);`,
+		`alter table "PUBLIC"."LINES" add constraint "FK_LINES" foreign key ("ORDER_ID") references "PUBLIC"."ORDERS"("ID") on delete cascade on update restrict;`,
+		`create index if not exists "IX_LINES" on "PUBLIC"."LINES"("ORDER_ID" DESC,"QTY" ASC);`,
+		`create or replace view "PUBLIC"."V_ORDERS" as SELECT "ID" FROM "PUBLIC"."ORDERS" WHERE "CODE" <> 'X';`,
+	}) {
+		t.Error(errors.Errorf("pre-load DDL:\n%s", strings.Join(got, "\n")))
+	}
+	if got := m.postLoad; !reflect.DeepEqual(got, []string{
+		`alter table "PUBLIC"."ORDERS" add constraint "FK_SELF" foreign key ("ID") references "PUBLIC"."ORDERS"("ID") on delete restrict on update restrict;`,
+		`select setval('"PUBLIC"."SEQ_ORDERS"', greatest((select max("ID") from "PUBLIC"."ORDERS") + 1, 101), false);`,
+	}) {
+		t.Error(errors.Errorf("post-load DDL:\n%s", strings.Join(got, "\n")))
+	}
+	if len(m.tables.cycles) != 1 || len(m.views.failed) != 0 {
+		t.Error(errors.Errorf("cycles %v, failed views %v", m.tables.cycleReport(), m.views.failureReport()))
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// options returns the options of the sequence in PostgreSQL syntax, starting
+// with the value H2 would give next.
+func (s *sequence) options(minValue, maxValue int64) string {
+	cacheSize := s.cacheSize
+	if cacheSize < 1 {
+		cacheSize = 1
+	}
+	cycle := "no cycle"
+	if s.cycle {
+		cycle = "cycle"
+	}
+	return fmt.Sprintf("start with %d increment by %d minvalue %d maxvalue %d cache %d %s",
+		s.nextValue,
+		s.increment,
+		minValue,
+		maxValue,
+		cacheSize,
+		cycle,
+	)
+}
+
+func (s *sequence) createDDL() (result string) {
+	result = fmt.Sprintf("create sequence if not exists %s %s;",
+		quoteQualified(s.schemaName, s.seqName),
+		s.options(s.minValue, s.maxValue),
+	)
+	return
+}
+
+func (s *sequence) dropDDL() (result string) {
+	result = fmt.Sprintf("drop sequence if exists %s;", quoteQualified(s.schemaName, s.seqName))
+	return
+}
+
+// createSequencesDDL creates the sequences, before the tables whose defaults
+// call them. The sequences H2 generated for identity columns are left out:
+// PostgreSQL generates its own for the identity columns.
+func createSequencesDDL(sequences []*sequence) (result []string) {
+	for _, s := range sequences {
+		if !s.generated {
+			result = append(result, s.createDDL())
+		}
+	}
+	return
+}
+
+// dropSequencesDDL drops the sequences, after the tables.
+func dropSequencesDDL(sequences []*sequence) (result []string) {
+	for _, s := range sequences {
+		if !s.generated {
+			result = append(result, s.dropDDL())
+		}
+	}
+	return
+}
+
+// The ranges of the PostgreSQL types of identity columns.
+var identityRanges = map[string][2]int64{
+	"smallint": {math.MinInt16, math.MaxInt16},
+	"integer":  {math.MinInt32, math.MaxInt32},
+	"bigint":   {math.MinInt64, math.MaxInt64},
+}
+
+// clause returns the GENERATED AS IDENTITY clause of column c of the given
+// PostgreSQL type. H2 bounds an identity by the range of a bigint whatever
+// its column type, which PostgreSQL refuses: the bounds are narrowed to the
+// range of the type.
+func (i *identity) clause(c *column, pgType string) (result string, err error) {
+	bounds, ok := identityRanges[pgBaseType(pgType)]
+	if !ok {
+		err = errors.Errorf("identity column %s.%s.%s is of type %s, not an integer type",
+			c.schemaName, c.tableName, c.columnName, pgType)
+		return
+	}
+	minValue, maxValue := i.minValue, i.maxValue
+	if minValue < bounds[0] {
+		minValue = bounds[0]
+	}
+	if maxValue > bounds[1] {
+		maxValue = bounds[1]
+	}
+	generation := "by default"
+	if i.always {
+		generation = "always"
+	}
+	result = fmt.Sprintf("generated %s as identity (%s)", generation, i.options(minValue, maxValue))
+	return
+}
+
+// nextAfterLoad returns the expression of the next value of a sequence of the
+// given options used by the given columns, once their rows are loaded: past
+// the greatest (least, going down) value loaded, and no sooner than the next
+// value in H2.
+func nextAfterLoad(s *sequence, columns []*column) string {
+	limit, bound := "max", "greatest"
+	if s.increment < 0 {
+		limit, bound = "min", "least"
+	}
+	if len(columns) == 0 {
+		return fmt.Sprint(s.nextValue)
+	}
+	var terms []string
+	for _, c := range columns {
+		terms = append(terms, fmt.Sprintf("(select %s(%s) from %s) + %d",
+			limit,
+			quoteIdent(c.columnName),
+			quoteQualified(c.schemaName, c.tableName),
+			s.increment,
+		))
+	}
+	return fmt.Sprintf("%s(%s)", bound, strings.Join(append(terms, fmt.Sprint(s.nextValue)), ", "))
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// setvalDDL moves the sequences and the identities of the tables past the
+// keys loaded, so that inserts into the new database don't collide with
+// them. A sequence is moved past the values of the columns whose default
+// calls it; a sequence no column calls keeps its H2 value.
+func setvalDDL(tables []*table, sequences []*sequence) (result []string) {
+	users := make(map[string][]*column)
+	for _, t := range tables {
+		for _, c := range t.columns {
+			if c.identity != nil {
+				result = append(result, fmt.Sprintf("select setval(pg_get_serial_sequence(%s, %s), %s, false);",
+					quoteLiteral(quoteQualified(c.schemaName, c.tableName)),
+					quoteLiteral(c.columnName),
+					nextAfterLoad(&c.identity.sequence, []*column{c}),
+				))
+			} else if schemaName, seqName, ok := c.defaultSequence(); ok {
+				key := tableKey(schemaName, seqName)
+				users[key] = append(users[key], c)
+			}
+		}
+	}
+	for _, s := range sequences {
+		if s.generated {
+			continue
+		}
+		result = append(result, fmt.Sprintf("select setval(%s, %s, false);",
+			quoteLiteral(quoteQualified(s.schemaName, s.seqName)),
+			nextAfterLoad(s, users[tableKey(s.schemaName, s.seqName)]),
+		))
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCollectIdentities(t *testing.T) {
+	rs := &testRS{data: [][]interface{}{
+		{"ID", "BY DEFAULT", int64(101), int64(1), int64(1), int64(math.MaxInt64), false, int64(32)},
+		{"NO", "ALWAYS", int64(-1), int64(-1), int64(-100), int64(-1), true, int64(1)},
+	}}
+	identities, err := collectIdentities(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*identity{
+		"ID": {sequence: sequence{nextValue: 101, increment: 1, minValue: 1, maxValue: math.MaxInt64, cacheSize: 32}},
+		"NO": {sequence: sequence{nextValue: -1, increment: -1, minValue: -100, maxValue: -1, cycle: true, cacheSize: 1}, always: true},
+	}
+	if !reflect.DeepEqual(identities, want) {
+		t.Error(errors.New("identities collected are wrong"))
+	}
+}
+
+func TestSequenceDDL(t *testing.T) {
+	seq := &sequence{schemaName: "PUBLIC", seqName: "SEQ_ORDERS", nextValue: 101, increment: 1,
+		minValue: 1, maxValue: math.MaxInt64, cacheSize: 0}
+	generated := &sequence{schemaName: "PUBLIC", seqName: "SYSTEM_SEQUENCE_1", nextValue: 1, increment: 1, generated: true}
+	create := createSequencesDDL([]*sequence{seq, generated})
+	if !reflect.DeepEqual(create, []string{
+		`create sequence if not exists "PUBLIC"."SEQ_ORDERS" start with 101 increment by 1 minvalue 1 maxvalue 9223372036854775807 cache 1 no cycle;`,
+	}) {
+		t.Error(errors.Errorf("create: %v", create))
+	}
+	if drop := dropSequencesDDL([]*sequence{seq, generated}); !reflect.DeepEqual(drop, []string{`drop sequence if exists "PUBLIC"."SEQ_ORDERS";`}) {
+		t.Error(errors.Errorf("drop: %v", drop))
+	}
+
+	id := &column{schemaName: "PUBLIC", tableName: "LINES", columnName: "ID", typeName: "INTEGER", nullable: 0,
+		defValue: "(NEXT VALUE FOR PUBLIC.SYSTEM_SEQUENCE_1)",
+		identity: &identity{sequence: sequence{nextValue: 7, increment: 1, minValue: 1, maxValue: math.MaxInt64, cacheSize: 32}}}
+	ddl, err := id.createDDL(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ddl != `"ID" integer not null generated by default as identity (start with 7 increment by 1 minvalue 1 maxvalue 2147483647 cache 32 no cycle)` {
+		t.Error(errors.Errorf("identity column DDL: %s", ddl))
+	}
+	id.identity.always, id.typeName = true, "DECIMAL"
+	if _, err = id.createDDL(nil); err == nil {
+		t.Error(errors.New("decimal identity column accepted"))
+	}
+	id.typeName = "SMALLINT"
+	if ddl, _ = id.createDDL(nil); !strings.Contains(ddl, "generated always as identity (start with 7 increment by 1 minvalue 1 maxvalue 32767 ") {
+		t.Error(errors.Errorf("identity column DDL: %s", ddl))
+	}
+
+	orderID := &column{schemaName: "PUBLIC", tableName: "ORDERS", columnName: "ID", typeName: "BIGINT",
+		defValue: `(NEXT VALUE FOR "PUBLIC"."SEQ_ORDERS")`}
+	archived := &column{schemaName: "ARCHIVE", tableName: "ORDERS", columnName: "ID", typeName: "BIGINT",
+		defValue: `NEXT VALUE FOR PUBLIC.SEQ_ORDERS`}
+	unused := &sequence{schemaName: "PUBLIC", seqName: "DOWN", nextValue: -5, increment: -1}
+	setval := setvalDDL([]*table{
+		{schemaName: "PUBLIC", tableName: "ORDERS", columns: []*column{orderID}},
+		{schemaName: "ARCHIVE", tableName: "ORDERS", columns: []*column{archived}},
+		{schemaName: "PUBLIC", tableName: "LINES", columns: []*column{id}},
+	}, []*sequence{generated, seq, unused})
+	want := []string{
+		`select setval(pg_get_serial_sequence('"PUBLIC"."LINES"', 'ID'), greatest((select max("ID") from "PUBLIC"."LINES") + 1, 7), false);`,
+		`select setval('"PUBLIC"."SEQ_ORDERS"', greatest((select max("ID") from "PUBLIC"."ORDERS") + 1, (select max("ID") from "ARCHIVE"."ORDERS") + 1, 101), false);`,
+		`select setval('"PUBLIC"."DOWN"', -5, false);`,
+	}
+	if !reflect.DeepEqual(setval, want) {
+		t.Error(errors.Errorf("setval: %q", setval))
+	}
+}
+
+func TestParseH2ScriptIdentities(t *testing.T) {
+	cat, err := parseH2Script(strings.NewReader(`
+CREATE SEQUENCE "PUBLIC"."SYSTEM_SEQUENCE_AB12" START WITH 42 BELONGS_TO_TABLE;
+CREATE CACHED TABLE "PUBLIC"."V1"(
+    "ID" INT DEFAULT (NEXT VALUE FOR "PUBLIC"."SYSTEM_SEQUENCE_AB12") NOT NULL NULL_TO_DEFAULT SEQUENCE "PUBLIC"."SYSTEM_SEQUENCE_AB12"
+);
+CREATE CACHED TABLE "PUBLIC"."V2"(
+    "ID" BIGINT GENERATED ALWAYS AS IDENTITY(START WITH 1 RESTART WITH 5 INCREMENT BY 2) NOT NULL,
+    "TOTAL" INT GENERATED ALWAYS AS ("ID" * 2),
+    "N" INT AUTO_INCREMENT(10, 5)
+);`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSchema(cat, "PUBLIC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.sequences) != 1 || !s.sequences[0].generated {
+		t.Error(errors.New("generated sequence not marked"))
+	}
+	v1, v2 := s.tables[0], s.tables[1]
+	if i := v1.columns[0].identity; i == nil || i.always || i.nextValue != 42 {
+		t.Error(errors.New("H2 1.4 identity is wrong"))
+	}
+	if i := v2.columns[0].identity; i == nil || !i.always || i.nextValue != 5 || i.increment != 2 {
+		t.Error(errors.New("H2 2.x identity is wrong"))
+	}
+	if v2.columns[1].identity != nil {
+		t.Error(errors.New("computed column taken for an identity"))
+	}
+	if i := v2.columns[2].identity; i == nil || i.nextValue != 10 || i.increment != 5 {
+		t.Error(errors.New("AUTO_INCREMENT identity is wrong"))
+	}
+	if create := createSequencesDDL(s.sequences); len(create) != 0 {
+		t.Error(errors.Errorf("generated sequence created: %v", create))
+	}
+}
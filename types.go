@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// typeModifier tells which column attributes make up the type modifier of a
+// PostgreSQL type, the part in parentheses.
+type typeModifier int
+
+const (
+	noModifier             typeModifier = iota
+	lengthModifier                      // (charLength), when 0 < charLength <= max
+	precisionScaleModifier              // (numPrec,numScale), when 0 < numPrec <= max
+	fractionModifier                    // (numScale) fractional second digits, when reported and 0 <= numScale < max
+)
+
+// typeRule maps an H2 type onto a PostgreSQL type. Outside the bounds given
+// by max the modifier is left out, so that the PostgreSQL type falls back to
+// its unbounded or default form: H2 reports e.g. 2147483647 as the length of
+// a VARCHAR declared without one.
+type typeRule struct {
+	pgType   string
+	modifier typeModifier
+	max      int
+}
+
+// h2TypeRules maps the H2 type names of INFORMATION_SCHEMA.COLUMNS.TYPE_NAME
+// in H2 1.4 and of COLUMNS.DATA_TYPE in H2 2.x.
+// INTERVAL types ("INTERVAL DAY TO SECOND", ...) are spelled the same in
+// PostgreSQL and are mapped in pgType, as are H2 2.x typed arrays
+// ("INTEGER ARRAY").
+var h2TypeRules = map[string]typeRule{
+	"BOOLEAN":                  {"boolean", noModifier, 0},
+	"BIT":                      {"boolean", noModifier, 0},
+	"TINYINT":                  {"smallint", noModifier, 0},
+	"SMALLINT":                 {"smallint", noModifier, 0},
+	"INT":                      {"integer", noModifier, 0},
+	"INTEGER":                  {"integer", noModifier, 0},
+	"BIGINT":                   {"bigint", noModifier, 0},
+	"DECIMAL":                  {"numeric", precisionScaleModifier, 1000},
+	"NUMERIC":                  {"numeric", precisionScaleModifier, 1000},
+	"REAL":                     {"real", noModifier, 0},
+	"DOUBLE":                   {"double precision", noModifier, 0},
+	"FLOAT":                    {"double precision", noModifier, 0},
+	"CHAR":                     {"character", lengthModifier, 10485760},
+	"CHARACTER":                {"character", lengthModifier, 10485760},
+	"VARCHAR":                  {"character varying", lengthModifier, 10485760},
+	"VARCHAR_IGNORECASE":       {"citext", noModifier, 0},
+	"CLOB":                     {"text", noModifier, 0},
+	"BINARY":                   {"bytea", noModifier, 0},
+	"VARBINARY":                {"bytea", noModifier, 0},
+	"BLOB":                     {"bytea", noModifier, 0},
+	"DATE":                     {"date", noModifier, 0},
+	"TIME":                     {"time without time zone", fractionModifier, 6},
+	"TIME WITH TIME ZONE":      {"time with time zone", fractionModifier, 6},
+	"TIMESTAMP":                {"timestamp without time zone", fractionModifier, 6},
+	"TIMESTAMP WITH TIME ZONE": {"timestamp with time zone", fractionModifier, 6},
+	"INTERVAL":                 {"interval", noModifier, 0},
+	"UUID":                     {"uuid", noModifier, 0},
+	"JSON":                     {"jsonb", noModifier, 0},
+	"GEOMETRY":                 {"geometry", noModifier, 0}, //  PostGIS
+	"ARRAY":                    {"text[]", noModifier, 0},   //  H2 1.4 arrays are untyped
+	"ENUM":                     {"text", noModifier, 0},     //  Values unchecked; override with a CREATE TYPE ... AS ENUM type
+	"OTHER":                    {"bytea", noModifier, 0},    //  Serialized Java objects
+
+	/*  Names only H2 2.x reports */
+	"CHARACTER VARYING":      {"character varying", lengthModifier, 10485760},
+	"CHARACTER LARGE OBJECT": {"text", noModifier, 0},
+	"BINARY VARYING":         {"bytea", noModifier, 0},
+	"BINARY LARGE OBJECT":    {"bytea", noModifier, 0},
+	"DOUBLE PRECISION":       {"double precision", noModifier, 0},
+	"DECFLOAT":               {"numeric", noModifier, 0},
+	"JAVA_OBJECT":            {"bytea", noModifier, 0},
+}
+
+// typeMap maps the types of H2 columns onto PostgreSQL types by h2TypeRules,
+// or by the rule given for a single column. A nil *typeMap maps by
+// h2TypeRules alone.
+type typeMap struct {
+	overrides map[string]typeRule
+}
+
+func newTypeMap() *typeMap {
+	return &typeMap{
+		overrides: make(map[string]typeRule),
+	}
+}
+
+func columnKey(schemaName, tableName, columnName string) string {
+	return strings.ToUpper(schemaName + "." + tableName + "." + columnName)
+}
+
+// overrideColumn maps the given column by rule instead of by its H2 type.
+func (m *typeMap) overrideColumn(schemaName, tableName, columnName string, rule typeRule) {
+	m.overrides[columnKey(schemaName, tableName, columnName)] = rule
+}
+
+func (m *typeMap) rule(c *column) (rule typeRule, ok bool) {
+	if m != nil {
+		if rule, ok = m.overrides[columnKey(c.schemaName, c.tableName, c.columnName)]; ok {
+			return
+		}
+	}
+	rule, ok = h2TypeRules[strings.ToUpper(strings.TrimSpace(c.typeName))]
+	return
+}
+
+func (m *typeMap) pgType(c *column) (result string, err error) {
+	if rule, ok := m.rule(c); ok {
+		result = rule.apply(c)
+		return
+	}
+	h2Type := strings.ToUpper(strings.TrimSpace(c.typeName))
+	switch {
+	case strings.HasPrefix(h2Type, "INTERVAL "):
+		result = strings.ToLower(h2Type)
+		return
+	case strings.HasSuffix(h2Type, " ARRAY"):
+		element := *c
+		element.typeName = strings.TrimSuffix(h2Type, " ARRAY")
+		if rule, ok := h2TypeRules[element.typeName]; ok {
+			/*  The length of an array is its maximum cardinality, not one of
+			its elements, so the elements get the unbounded type. */
+			result = rule.pgType + "[]"
+			return
+		}
+	}
+	err = errors.Errorf("column %s.%s.%s has H2 type %s that has no PostgreSQL mapping",
+		c.schemaName, c.tableName, c.columnName, c.typeName)
+	return
+}
+
+// apply returns the PostgreSQL type of the rule with the modifier of the
+// column, if any.
+func (r typeRule) apply(c *column) string {
+	switch r.modifier {
+	case lengthModifier:
+		if c.charLength > 0 && c.charLength <= r.max {
+			return fmt.Sprintf("%s(%d)", r.pgType, c.charLength)
+		}
+	case precisionScaleModifier:
+		if c.numPrec > 0 && c.numPrec <= r.max {
+			return fmt.Sprintf("%s(%d,%d)", r.pgType, c.numPrec, c.numScale)
+		}
+	case fractionModifier:
+		if c.scaleReported && c.numScale >= 0 && c.numScale < r.max {
+			/*  The precision goes after the type name proper, before any
+			time zone clause: "timestamp(3) with time zone". */
+			name, zone := r.pgType, ""
+			if k := strings.Index(name, " with"); k >= 0 {
+				name, zone = name[:k], name[k:]
+			}
+			return fmt.Sprintf("%s(%d)%s", name, c.numScale, zone)
+		}
+	}
+	return r.pgType
+}
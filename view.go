@@ -0,0 +1,514 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// viewTranslationError lists the fragments of the definition of a view that
+// have no PostgreSQL translation.
+type viewTranslationError struct {
+	schemaName string
+	viewName   string
+	fragments  []string
+}
+
+func (e *viewTranslationError) Error() string {
+	return fmt.Sprintf("view %s.%s cannot be translated: %s", e.schemaName, e.viewName, strings.Join(e.fragments, "; "))
+}
+
+// viewOrder holds the views translated into PostgreSQL, each after the views
+// it selects from, and the views that could not be translated. The views go
+// into the DDL after the tables.
+type viewOrder struct {
+	views   []*view
+	queries map[*view]string
+	failed  []*viewTranslationError
+}
+
+// h2FunctionNames renames the H2 functions that PostgreSQL has under another
+// name.
+var h2FunctionNames = map[string]string{
+	"IFNULL":   "coalesce",
+	"NVL":      "coalesce",
+	"LCASE":    "lower",
+	"UCASE":    "upper",
+	"TRUNCATE": "trunc",
+	"RAND":     "random",
+}
+
+// h2OnlyFunctions are the H2 functions with no PostgreSQL translation.
+var h2OnlyFunctions = map[string]bool{
+	"DECODE": true, "NVL2": true, "CASEWHEN": true, "DATEDIFF": true, "TIMESTAMPDIFF": true,
+	"FORMATDATETIME": true, "PARSEDATETIME": true, "ROWNUM": true, "IDENTITY": true,
+	"SCOPE_IDENTITY": true, "LOCATE": true, "INSTR": true, "ARRAY_GET": true, "LINK_SCHEMA": true,
+	"CONVERT": true, "SECURE_RAND": true, "HASH": true, "ENCRYPT": true, "DECRYPT": true,
+}
+
+// h2OnlyOperators are the H2 operators with no PostgreSQL translation.
+var h2OnlyOperators = map[string]bool{
+	"REGEXP": true,
+}
+
+// h2ExtractParts are the H2 functions returning a part of a date, as
+// EXTRACT does.
+var h2ExtractParts = map[string]bool{
+	"YEAR": true, "QUARTER": true, "MONTH": true, "WEEK": true, "DAY": true,
+	"HOUR": true, "MINUTE": true, "SECOND": true,
+}
+
+// The date parts of DATEADD and TIMESTAMPADD.
+var h2DateParts = map[string]string{
+	"YEAR": "year", "YEARS": "year", "YY": "year", "YYYY": "year", "SQL_TSI_YEAR": "year",
+	"MONTH": "month", "MONTHS": "month", "MM": "month", "M": "month", "SQL_TSI_MONTH": "month",
+	"WEEK": "week", "WK": "week", "WW": "week", "SQL_TSI_WEEK": "week",
+	"DAY": "day", "DAYS": "day", "DD": "day", "D": "day", "SQL_TSI_DAY": "day",
+	"HOUR": "hour", "HOURS": "hour", "HH": "hour", "SQL_TSI_HOUR": "hour",
+	"MINUTE": "minute", "MINUTES": "minute", "MI": "minute", "N": "minute", "SQL_TSI_MINUTE": "minute",
+	"SECOND": "second", "SECONDS": "second", "SS": "second", "S": "second", "SQL_TSI_SECOND": "second",
+	"MILLISECOND": "millisecond", "MS": "millisecond",
+	"MICROSECOND": "microsecond", "MCS": "microsecond",
+}
+
+// The SQL keywords of view definitions. Any other unquoted word that is not
+// a function is an identifier, quoted like the columns in the DDL.
+var viewKeywords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		SELECT FROM WHERE AND OR NOT AS JOIN INNER LEFT RIGHT FULL OUTER CROSS NATURAL ON USING
+		GROUP BY ORDER HAVING UNION ALL DISTINCT EXCEPT INTERSECT CASE WHEN THEN ELSE END IS NULL
+		IN EXISTS BETWEEN LIKE ILIKE ESCAPE SIMILAR TO ASC DESC NULLS FIRST LAST LIMIT OFFSET FETCH
+		NEXT ROW ROWS ONLY WITH RECURSIVE TRUE FALSE INTERVAL DATE TIME TIMESTAMP ZONE ANY SOME
+		VALUES OVER PARTITION RANGE GROUPS UNBOUNDED PRECEDING FOLLOWING CURRENT FILTER WITHIN
+		LATERAL COLLATE DEFAULT FOR LEADING TRAILING BOTH ARRAY YEAR MONTH WEEK DAY HOUR MINUTE
+		SECOND QUARTER EPOCH DOW DOY MILLISECOND MICROSECOND CURRENT_DATE CURRENT_TIME
+		CURRENT_TIMESTAMP LOCALTIME LOCALTIMESTAMP CURRENT_USER SESSION_USER USER CURRENT_SCHEMA UNKNOWN`) {
+		viewKeywords[w] = true
+	}
+}
+
+// The keywords ending the FROM list of a query.
+var fromListEnds = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "WINDOW": true, "QUALIFY": true, "ORDER": true,
+	"UNION": true, "EXCEPT": true, "INTERSECT": true, "MINUS": true, "LIMIT": true, "OFFSET": true,
+	"FETCH": true, "SELECT": true,
+}
+
+// orderViews translates the views, ordering each after the views it selects
+// from. A view that cannot be translated, or selects from one that cannot,
+// is left out and listed in failed.
+func orderViews(views []*view, tables []*table) (result *viewOrder) {
+	result = &viewOrder{queries: make(map[*view]string)}
+	relations := make(map[string]bool)
+	byKey := make(map[string]*view)
+	for _, t := range tables {
+		relations[tableKey(t.schemaName, t.tableName)] = true
+	}
+	for _, v := range views {
+		relations[tableKey(v.schemaName, v.viewName)] = true
+		byKey[tableKey(v.schemaName, v.viewName)] = v
+	}
+
+	uses := make(map[*view][]*view)
+	failed := make(map[*view]*viewTranslationError)
+	for _, v := range views {
+		tr := &viewTranslator{schemaName: v.schemaName, relations: relations}
+		query := tr.translateView(v.definition)
+		for _, key := range tr.used {
+			if w := byKey[key]; w != nil && w != v {
+				uses[v] = append(uses[v], w)
+			}
+		}
+		if len(tr.fragments) != 0 {
+			failed[v] = &viewTranslationError{v.schemaName, v.viewName, tr.fragments}
+			continue
+		}
+		result.queries[v] = query
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*view]int)
+	var visit func(v *view)
+	visit = func(v *view) {
+		if state[v] != 0 {
+			if state[v] == visiting && failed[v] == nil {
+				failed[v] = &viewTranslationError{v.schemaName, v.viewName, []string{"selects from itself through other views"}}
+			}
+			return
+		}
+		state[v] = visiting
+		for _, w := range uses[v] {
+			visit(w)
+			if failed[w] != nil && failed[v] == nil {
+				failed[v] = &viewTranslationError{v.schemaName, v.viewName,
+					[]string{fmt.Sprintf("selects from view %s.%s that cannot be translated", w.schemaName, w.viewName)}}
+			}
+		}
+		state[v] = done
+		if failed[v] == nil {
+			result.views = append(result.views, v)
+		}
+	}
+	for _, v := range views {
+		visit(v)
+	}
+	for _, v := range views {
+		if e := failed[v]; e != nil {
+			result.failed = append(result.failed, e)
+		}
+	}
+	return
+}
+
+func (o *viewOrder) createDDL() (result []string) {
+	for _, v := range o.views {
+		result = append(result, fmt.Sprintf("create or replace view %s as %s;", quoteQualified(v.schemaName, v.viewName), o.queries[v]))
+	}
+	return
+}
+
+// dropDDL drops the views, the views selecting from others first, before
+// the tables are dropped.
+func (o *viewOrder) dropDDL() (result []string) {
+	for k := len(o.views) - 1; k >= 0; k-- {
+		result = append(result, fmt.Sprintf("drop view if exists %s;", quoteQualified(o.views[k].schemaName, o.views[k].viewName)))
+	}
+	return
+}
+
+// failureReport lists the views left out, one per line.
+func (o *viewOrder) failureReport() (result []string) {
+	for _, e := range o.failed {
+		result = append(result, e.Error())
+	}
+	return
+}
+
+// viewTranslator rewrites the query of a view from H2 into PostgreSQL SQL,
+// token by token, keeping the text between the tokens.
+type viewTranslator struct {
+	p          *scriptParser
+	schemaName string
+	relations  map[string]bool //  Keys of the tables and views
+	used       []string        //  Keys of the tables and views selected from
+	fragments  []string        //  Fragments with no translation
+}
+
+func (tr *viewTranslator) fail(from, to int) {
+	tr.fragments = append(tr.fragments, tr.text(from, to))
+}
+
+// text returns the source text of the tokens from..to, to excluded.
+func (tr *viewTranslator) text(from, to int) string {
+	if from >= to {
+		return ""
+	}
+	return tr.p.src[tr.p.toks[from].start:tr.p.toks[to-1].end]
+}
+
+// isValue reports whether the token at k, within from..to, is a literal or
+// a name.
+func (tr *viewTranslator) isValue(k, from, to int) bool {
+	if k < from || k >= to {
+		return false
+	}
+	switch t := tr.p.toks[k]; t.kind {
+	case numberToken, stringToken, quotedToken:
+		return true
+	case wordToken:
+		return !viewKeywords[t.text]
+	}
+	return false
+}
+
+func (tr *viewTranslator) isSymbol(k int, s string) bool {
+	return k >= 0 && k < len(tr.p.toks) && tr.p.toks[k].kind == symbolToken && tr.p.toks[k].text == s
+}
+
+func (tr *viewTranslator) isWord(k int, w string) bool {
+	return k >= 0 && k < len(tr.p.toks) && tr.p.toks[k].kind == wordToken && tr.p.toks[k].text == w
+}
+
+// closing returns the index of the parenthesis closing the one at k.
+func (tr *viewTranslator) closing(k int) int {
+	depth := 0
+	for ; k < len(tr.p.toks); k++ {
+		switch {
+		case tr.isSymbol(k, "("):
+			depth++
+		case tr.isSymbol(k, ")"):
+			if depth--; depth == 0 {
+				return k
+			}
+		}
+	}
+	return k
+}
+
+// arguments splits the arguments of the call whose parenthesis is at k.
+func (tr *viewTranslator) arguments(k int) (result [][2]int) {
+	end := tr.closing(k)
+	from := k + 1
+	for j := from; j < end; j++ {
+		switch {
+		case tr.isSymbol(j, "("):
+			j = tr.closing(j)
+		case tr.isSymbol(j, ","):
+			result = append(result, [2]int{from, j})
+			from = j + 1
+		}
+	}
+	if from < end {
+		result = append(result, [2]int{from, end})
+	}
+	return
+}
+
+// translateView translates a view definition, which H2 1.4 reports as the
+// whole CREATE VIEW statement and H2 2.x as the query alone.
+func (tr *viewTranslator) translateView(definition string) string {
+	tr.p = &scriptParser{src: definition}
+	for off := 0; ; {
+		tok, next, ok, err := tr.p.lex(off)
+		if err != nil {
+			tr.fragments = append(tr.fragments, err.Error())
+			return ""
+		}
+		if !ok {
+			break
+		}
+		tr.p.toks = append(tr.p.toks, tok)
+		off = next
+	}
+	from := 0
+	if tr.isWord(0, "CREATE") {
+		for from < len(tr.p.toks) && !tr.isWord(from, "AS") {
+			if tr.isSymbol(from, "(") {
+				from = tr.closing(from)
+			}
+			from++
+		}
+		from++
+	}
+	to := len(tr.p.toks)
+	if tr.isSymbol(to-1, ";") {
+		to--
+	}
+	depth := 0
+	for k := from; k < to && depth >= 0; k++ {
+		switch {
+		case tr.isSymbol(k, "("):
+			depth++
+		case tr.isSymbol(k, ")"):
+			depth--
+		}
+	}
+	if depth != 0 {
+		tr.fragments = append(tr.fragments, "unbalanced parentheses")
+		return ""
+	}
+	if from >= to {
+		tr.fragments = append(tr.fragments, "no query in the definition")
+		return ""
+	}
+	return tr.translate(from, to)
+}
+
+// translate translates the tokens from..to, to excluded, a query or the
+// content of parentheses.
+func (tr *viewTranslator) translate(from, to int) string {
+	var sb strings.Builder
+	toks := tr.p.toks
+	prev := toks[from].start
+	emit := func(k int, s string) {
+		sb.WriteString(tr.p.src[prev:toks[k].start])
+		sb.WriteString(s)
+	}
+	var top string    //  TOP n of the SELECT at this level, as a LIMIT
+	fromList := false //  In the FROM list of the query at this level
+	clauseEnd := -1   //  Last token of a TOP or DISTINCT ON, which is no operand
+	for k := from; k < to; {
+		t := toks[k]
+		next := k + 1
+		if t.kind == wordToken && (t.text == "FROM" || fromListEnds[t.text]) {
+			fromList = t.text == "FROM"
+		}
+		switch {
+		case t.kind == symbolToken && t.text == "(":
+			end := tr.closing(k)
+			emit(k, "("+tr.translate(k+1, end)+")")
+			next = end + 1
+		case t.kind == wordToken && t.text == "TOP":
+			if tr.isSymbol(k+1, "(") {
+				next = tr.closing(k+1) + 1
+				top = tr.translate(k+1, next)
+			} else if k+1 < to && toks[k+1].kind == numberToken {
+				next = k + 2
+				top = toks[k+1].text
+			} else {
+				tr.fail(k, k+1)
+			}
+			/*  Dropped with the text before it. */
+			prev, k, clauseEnd = toks[next-1].end, next, next-1
+			continue
+		case t.kind == wordToken && tr.isSymbol(k+1, "("):
+			next = tr.function(k, emit)
+			if t.text == "ON" && tr.isWord(k-1, "DISTINCT") {
+				clauseEnd = next - 1
+			}
+		case t.kind == wordToken && (t.text == "UNION" || t.text == "EXCEPT" || t.text == "INTERSECT" || t.text == "MINUS"):
+			if top != "" {
+				/*  PostgreSQL takes a LIMIT only at the end of the set
+				operation, or in parentheses. */
+				tr.fail(from, k)
+				top = ""
+			}
+			if t.text == "MINUS" {
+				emit(k, "except")
+			} else {
+				emit(k, tr.text(k, k+1))
+			}
+		case t.kind == wordToken && (t.text == "TRUE" || t.text == "FALSE"):
+			emit(k, strings.ToLower(t.text))
+		case t.kind == wordToken && t.text == "UNKNOWN" && !tr.isWord(k-1, "IS") && !tr.isWord(k-1, "NOT"):
+			emit(k, "null")
+		case t.kind == wordToken && h2DefaultFunctions[t.text] != "":
+			emit(k, h2DefaultFunctions[t.text])
+		case t.kind == wordToken && t.text == "ROWNUM":
+			tr.fail(k, k+1)
+		case t.kind == wordToken && !viewKeywords[t.text] &&
+			(h2OnlyOperators[t.text] || k-1 != clauseEnd && tr.isValue(k+1, from, to) &&
+				(tr.isValue(k-1, from, to) || k > from && tr.isSymbol(k-1, ")"))):
+			/*  A bare word between two operands is an H2 operator, not
+			an alias. */
+			tr.fail(k, k+1)
+		case t.kind == wordToken && viewKeywords[t.text]:
+			emit(k, tr.text(k, k+1))
+		case t.kind == wordToken || t.kind == quotedToken:
+			/*  An item of the FROM list follows FROM, JOIN or a comma. */
+			fromItem := fromList && k > from &&
+				(tr.isWord(k-1, "FROM") || tr.isWord(k-1, "JOIN") || tr.isSymbol(k-1, ","))
+			next = tr.name(k, fromItem, emit)
+		default:
+			emit(k, tr.text(k, k+1))
+		}
+		prev = toks[next-1].end
+		k = next
+	}
+	if top != "" {
+		sb.WriteString(" limit " + top)
+	}
+	return sb.String()
+}
+
+// function translates the call of the function named at k, returning the
+// index after it.
+func (tr *viewTranslator) function(k int, emit func(k int, s string)) (next int) {
+	name := tr.p.toks[k].text
+	end := tr.closing(k + 1)
+	next = end + 1
+	args := tr.arguments(k + 1)
+	argument := func(a [2]int) string {
+		return strings.TrimSpace(tr.translate(a[0], a[1]))
+	}
+	switch {
+	case h2OnlyFunctions[name]:
+		tr.fail(k, next)
+	case (name == "DATEADD" || name == "TIMESTAMPADD") && len(args) == 3:
+		part := tr.p.toks[args[0][0]]
+		unit, ok := h2DateParts[strings.ToUpper(part.text)]
+		if !ok || args[0][1] != args[0][0]+1 {
+			tr.fail(k, next)
+			return
+		}
+		emit(k, fmt.Sprintf("(%s + (%s) * interval '1 %s')", argument(args[2]), argument(args[1]), unit))
+	case name == "CAST" && len(args) == 1:
+		/*  CAST(x AS type) with the type mapped as a column type. */
+		as := args[0][0]
+		for as < end && !tr.isWord(as, "AS") {
+			if tr.isSymbol(as, "(") {
+				as = tr.closing(as)
+			}
+			as++
+		}
+		pgType, err := tr.castType(as+1, end)
+		if as >= end || err != nil {
+			tr.fail(k, next)
+			return
+		}
+		emit(k, fmt.Sprintf("cast(%s as %s)", argument([2]int{args[0][0], as}), pgType))
+	case h2ExtractParts[name] && len(args) == 1:
+		emit(k, fmt.Sprintf("extract(%s from %s)", strings.ToLower(name), argument(args[0])))
+	default:
+		pgName := tr.text(k, k+1)
+		if renamed, ok := h2FunctionNames[name]; ok {
+			pgName = renamed
+		} else if pg, ok := h2DefaultFunctions[name+"()"]; ok && len(args) == 0 {
+			emit(k, pg)
+			return
+		}
+		emit(k, pgName+tr.p.src[tr.p.toks[k].end:tr.p.toks[k+1].start]+"("+tr.translate(k+2, end)+")")
+	}
+	return
+}
+
+// castType returns the PostgreSQL type of the H2 type of tokens from..to,
+// to excluded, mapped as the type of a column.
+func (tr *viewTranslator) castType(from, to int) (result string, err error) {
+	p := &scriptParser{src: tr.p.src, toks: tr.p.toks[from:to]}
+	c := &column{columnName: "CAST"}
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(*scriptSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = errors.New(se.msg)
+		}
+	}()
+	p.columnType(c, columnClauses)
+	if p.pos != len(p.toks) {
+		err = errors.Errorf("unexpected %s in a type", p.describe())
+		return
+	}
+	result, err = (*typeMap)(nil).pgType(c)
+	return
+}
+
+// name translates the possibly qualified name at k, returning the index
+// after it; fromItem tells that the name starts an item of a FROM list,
+// where an unqualified name may be a table or view. A table or view is qualified by its schema; every name is quoted
+// as H2 keeps it, as in the DDL.
+func (tr *viewTranslator) name(k int, fromItem bool, emit func(k int, s string)) (next int) {
+	toks := tr.p.toks
+	parts := []string{toks[k].text}
+	next = k + 1
+	for tr.isSymbol(next, ".") && next+1 < len(toks) && (toks[next+1].kind == wordToken || toks[next+1].kind == quotedToken) {
+		parts = append(parts, toks[next+1].text)
+		next += 2
+	}
+	schemaName, relation := "", 0 //  Parts making up the table or view name
+	switch {
+	case len(parts) >= 2 && tr.relations[tableKey(parts[0], parts[1])]:
+		schemaName, relation = parts[0], 2
+	case len(parts) == 1 && fromItem && tr.relations[tableKey(tr.schemaName, parts[0])]:
+		schemaName, relation = tr.schemaName, 1
+	}
+	var out []string
+	if relation != 0 {
+		tr.used = append(tr.used, tableKey(schemaName, parts[relation-1]))
+		out = append(out, quoteQualified(schemaName, parts[relation-1]))
+		parts = parts[relation:]
+	}
+	for _, part := range parts {
+		out = append(out, quoteIdent(part))
+	}
+	emit(k, strings.Join(out, "."))
+	return
+}
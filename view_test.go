@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestOrderViews(t *testing.T) {
+	tables := []*table{{schemaName: "PUBLIC", tableName: "ORDERS"}}
+	views := []*view{
+		{schemaName: "PUBLIC", viewName: "ACTIVE_ORDERS",
+			definition: `SELECT "ID" FROM "PUBLIC"."V_ORDERS" WHERE "ACTIVE" = TRUE`},
+		{schemaName: "PUBLIC", viewName: "BAD", definition: `SELECT DECODE(X, 1, 'a') AS X, ROWNUM FROM PUBLIC.ORDERS`},
+		{schemaName: "PUBLIC", viewName: "ON_BAD", definition: `SELECT X FROM BAD`},
+		{schemaName: "PUBLIC", viewName: "TYPED", definition: "SELECT CAST(ID AS VARCHAR(10)) AS ID, YEAR(CREATED) Y\n" +
+			"FROM ORDERS O WHERE O.FLAG IS NOT UNKNOWN MINUS SELECT IFNULL(CODE, '-'), 0 FROM PUBLIC.ORDERS;"},
+		{schemaName: "PUBLIC", viewName: "V_ORDERS", definition: "CREATE FORCE VIEW PUBLIC.V_ORDERS(ID, CODE, DUE, NOW, ACTIVE) AS\n" +
+			"SELECT TOP 10 ORDERS.ID, IFNULL(ORDERS.CODE, 'none') AS CODE, DATEADD('DAY', 7, ORDERS.CREATED) AS DUE, " +
+			"SYSDATE AS NOW, TRUE AS ACTIVE FROM PUBLIC.ORDERS ORDER BY ORDERS.ID"},
+	}
+	o := orderViews(views, tables)
+	want := []string{
+		`create or replace view "PUBLIC"."V_ORDERS" as SELECT "ORDERS"."ID", coalesce("ORDERS"."CODE", 'none') AS "CODE", ` +
+			`("ORDERS"."CREATED" + (7) * interval '1 day') AS "DUE", current_timestamp AS "NOW", true AS "ACTIVE" ` +
+			`FROM "PUBLIC"."ORDERS" ORDER BY "ORDERS"."ID" limit 10;`,
+		`create or replace view "PUBLIC"."ACTIVE_ORDERS" as SELECT "ID" FROM "PUBLIC"."V_ORDERS" WHERE "ACTIVE" = true;`,
+		"create or replace view \"PUBLIC\".\"TYPED\" as SELECT cast(\"ID\" as character varying(10)) AS \"ID\", extract(year from \"CREATED\") \"Y\"\n" +
+			`FROM "PUBLIC"."ORDERS" "O" WHERE "O"."FLAG" IS NOT UNKNOWN except SELECT coalesce("CODE", '-'), 0 FROM "PUBLIC"."ORDERS";`,
+	}
+	if got := o.createDDL(); !reflect.DeepEqual(got, want) {
+		t.Error(errors.Errorf("create:\n%s", got))
+	}
+	if got := o.dropDDL(); !reflect.DeepEqual(got, []string{
+		`drop view if exists "PUBLIC"."TYPED";`,
+		`drop view if exists "PUBLIC"."ACTIVE_ORDERS";`,
+		`drop view if exists "PUBLIC"."V_ORDERS";`,
+	}) {
+		t.Error(errors.Errorf("drop: %v", got))
+	}
+	if got := o.failureReport(); !reflect.DeepEqual(got, []string{
+		"view PUBLIC.BAD cannot be translated: DECODE(X, 1, 'a'); ROWNUM",
+		"view PUBLIC.ON_BAD cannot be translated: selects from view PUBLIC.BAD that cannot be translated",
+	}) {
+		t.Error(errors.Errorf("failures: %v", got))
+	}
+}
+
+func TestTranslateViewFailures(t *testing.T) {
+	for definition, fragment := range map[string]string{
+		"SELECT TOP 5 ID FROM T UNION SELECT ID FROM U": "SELECT TOP 5 ID FROM T",
+		"SELECT DATEADD(X, 1, D) FROM T":                "DATEADD(X, 1, D)",
+		"SELECT CAST(A AS CURSOR) FROM T":               "CAST(A AS CURSOR)",
+		"SELECT (A FROM T":                              "unbalanced parentheses",
+		"CREATE VIEW V AS":                              "no query in the definition",
+		"SELECT ID FROM T WHERE CODE REGEXP '^A'":       "REGEXP",
+		"SELECT ID FROM T WHERE CODE NOT REGEXP '^A'":   "REGEXP",
+		"SELECT ID FROM T WHERE (CODE) MATCHES 'A'":     "MATCHES",
+	} {
+		tr := &viewTranslator{schemaName: "PUBLIC"}
+		tr.translateView(definition)
+		if !reflect.DeepEqual(tr.fragments, []string{fragment}) {
+			t.Error(errors.Errorf("%s: fragments %q", definition, tr.fragments))
+		}
+	}
+}
+
+func TestTranslateViewFromList(t *testing.T) {
+	tr := &viewTranslator{schemaName: "PUBLIC", relations: map[string]bool{"PUBLIC.ORDERS": true, "PUBLIC.LINES": true}}
+	got := tr.translateView("SELECT O.ID, LINES, SYSDATE() AS AT FROM ORDERS O, LINES L, (SELECT ID FROM ORDERS) X\n" +
+		"WHERE O.ID = L.ORDER_ID AND L.QTY IN (1, 2) ORDER BY O.ID, LINES")
+	want := `SELECT "O"."ID", "LINES", current_timestamp AS "AT" FROM "PUBLIC"."ORDERS" "O", "PUBLIC"."LINES" "L", ` +
+		`(SELECT "ID" FROM "PUBLIC"."ORDERS") "X"` + "\n" +
+		`WHERE "O"."ID" = "L"."ORDER_ID" AND "L"."QTY" IN (1, 2) ORDER BY "O"."ID", "LINES"`
+	if got != want || len(tr.fragments) != 0 {
+		t.Error(errors.Errorf("translation %s, fragments %q", got, tr.fragments))
+	}
+	if !reflect.DeepEqual(tr.used, []string{"PUBLIC.ORDERS", "PUBLIC.LINES", "PUBLIC.ORDERS"}) {
+		t.Error(errors.Errorf("used: %v", tr.used))
+	}
+}
+
+func TestTranslateViewTop(t *testing.T) {
+	for definition, want := range map[string]string{
+		"SELECT TOP (5) ID I, 'x' S FROM T X ORDER BY ID": `SELECT "ID" "I", 'x' "S" FROM "T" "X" ORDER BY "ID" limit (5)`,
+		"SELECT TOP 5 ID I FROM T":                        `SELECT "ID" "I" FROM "T" limit 5`,
+		"SELECT DISTINCT ON (A) B C FROM T":               `SELECT DISTINCT ON ("A") "B" "C" FROM "T"`,
+	} {
+		tr := &viewTranslator{schemaName: "PUBLIC"}
+		if got := tr.translateView(definition); got != want || len(tr.fragments) != 0 {
+			t.Error(errors.Errorf("translation %s, fragments %q", got, tr.fragments))
+		}
+	}
+}